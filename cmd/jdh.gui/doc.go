@@ -39,6 +39,8 @@ Tr.view displays a tree. By default the tree will be fitted to the window.
 If there are more than one tree in the database, you can use space, enter keys
 to move to the next tree, and backspace to move to previous tree.
 
+The tree is updated when the trees are modified by another client.
+
 Options
 
     -p value
@@ -23,7 +23,8 @@ var spNav = &cmdapp.Command{
 	Long: `
 Description
 
-Sp.nav displays the specimens stored in the database.
+Sp.nav displays the specimens stored in the database. The view is updated
+when the taxonomy, or the specimens, are modified by another client.
 
 Options
 
@@ -72,6 +73,7 @@ func spNavRun(c *cmdapp.Command, args []string) {
 		spNavInitTaxList(m, l, db, nil, 0)
 		sparta.Unblock(nil)
 	}()
+	watchDB(db, func() { spNavRefresh(m) }, jdh.Taxonomy, jdh.Specimens, jdh.Datasets)
 
 	sparta.Run()
 }
@@ -362,6 +364,47 @@ func spNavInitSpeList(m, s sparta.Widget) {
 	l.SetProperty(widget.ListList, ls)
 }
 
+// SpNavRefresh reloads the taxon and specimen lists with the current
+// content of the database.
+func spNavRefresh(m sparta.Widget) {
+	d := m.Property(sparta.Data)
+	if d == nil {
+		return
+	}
+	sparta.Block(nil)
+	defer sparta.Unblock(nil)
+	data := d.(*txList).reload()
+	title := fmt.Sprintf("%s: %s [id: %s]", cmd.Name, data.tax.Name, data.tax.Id)
+	m.SetProperty(sparta.Caption, title)
+	m.SetProperty(sparta.Data, data)
+	wnd["taxonList"].SetProperty(widget.ListList, data)
+
+	s := wnd["speList"]
+	sel := ""
+	if sl := s.Property(widget.ListList); sl != nil {
+		if old := sl.(*spList); old.sel >= 0 {
+			sel = old.spe[old.sel].Id
+		}
+	}
+	spNavInitSpeList(m, s)
+	tx := wnd["info"]
+	sl := s.Property(widget.ListList)
+	if (len(sel) == 0) || (sl == nil) {
+		spNavInfo(tx, nil, nil, nil)
+		return
+	}
+	ls := sl.(*spList)
+	for i, spe := range ls.spe {
+		if spe.Id == sel {
+			ls.sel = i
+			s.Update()
+			spNavInfo(tx, ls.db, spe, ls.tax)
+			return
+		}
+	}
+	spNavInfo(tx, nil, nil, nil)
+}
+
 func spNavInfo(tx sparta.Widget, db jdh.DB, spe *jdh.Specimen, tax *jdh.Taxon) {
 	if spe == nil {
 		tx.SetProperty(sparta.Data, nil)
@@ -106,6 +106,29 @@ func newTxList(tax *jdh.Taxon, db jdh.DB, syns bool) *txList {
 	return ls
 }
 
+// Reload returns a new list with the current content of the database,
+// keeping the selected taxa. If the taxon of the list was deleted, the
+// list of the root is returned.
+func (ls *txList) reload() *txList {
+	var tax *jdh.Taxon
+	if ls.tax.Id != "0" {
+		tax = taxon(cmd, ls.db, ls.tax.Id)
+		if len(tax.Id) == 0 {
+			tax = nil
+		}
+	}
+	d := newTxList(tax, ls.db, ls.syns)
+	for _, s := range ls.sels {
+		for i, t := range d.desc {
+			if t.Id == ls.desc[s].Id {
+				d.sels = append(d.sels, i)
+				break
+			}
+		}
+	}
+	return d
+}
+
 func (ls *txList) Len() int {
 	return len(ls.desc)
 }
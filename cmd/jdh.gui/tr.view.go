@@ -29,6 +29,8 @@ Tr.view displays a tree. By default the tree will be fitted to the window.
 If there are more than one tree in the database, you can use space, enter keys
 to move to the next tree, and backspace to move to previous tree.
 
+The tree is updated when the trees are modified by another client.
+
 Options
 
     -p value
@@ -66,6 +68,7 @@ func trViewRun(c *cmdapp.Command, args []string) {
 
 	m.Capture(sparta.Configure, trViewConf)
 	go trViewInitList(m, tv)
+	watchDB(localDB, func() { trViewRefresh(m, tv) }, jdh.Trees, jdh.Nodes, jdh.Taxonomy)
 
 	sparta.Run()
 }
@@ -260,10 +263,20 @@ func trViewNearestNode(pt image.Point, node []*trNode) *trNode {
 }
 
 func trViewInitList(m, tv sparta.Widget) {
+	data := trViewList()
+	if (data == nil) || (len(data.phyLs) == 0) {
+		return
+	}
+	m.SetProperty(sparta.Data, data)
+	trViewInitTree(m, tv)
+}
+
+// TrViewList returns the list of trees of the database.
+func trViewList() *trList {
 	l, err := localDB.List(jdh.Trees, new(jdh.Values))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", cmd.ErrStr(err))
-		return
+		return nil
 	}
 	data := &trList{}
 	for {
@@ -273,18 +286,68 @@ func trViewInitList(m, tv sparta.Widget) {
 				break
 			}
 			fmt.Fprintf(os.Stderr, "%s\n", cmd.ErrStr(err))
-			return
+			return nil
 		}
 		if (len(phy.Id) == 0) || (len(phy.Root) == 0) {
 			continue
 		}
 		data.phyLs = append(data.phyLs, phy)
 	}
+	return data
+}
+
+// TrViewRefresh reloads the tree list, and the current tree, with the
+// current content of the database, keeping the view of the tree.
+func trViewRefresh(m, tv sparta.Widget) {
+	data := trViewList()
+	if data == nil {
+		return
+	}
+	sparta.Block(nil)
+	defer sparta.Unblock(nil)
+	d := m.Property(sparta.Data)
+	if d == nil {
+		if len(data.phyLs) > 0 {
+			m.SetProperty(sparta.Data, data)
+			trViewInitTree(m, tv)
+		}
+		return
+	}
+	old := d.(*trList)
 	if len(data.phyLs) == 0 {
+		m.SetProperty(sparta.Data, data)
+		m.SetProperty(sparta.Caption, cmd.Name)
+		tv.SetProperty(sparta.Data, nil)
+		tv.Update()
 		return
 	}
+	data.pos = old.pos
+	if data.pos >= len(data.phyLs) {
+		data.pos = len(data.phyLs) - 1
+	}
+	if old.pos < len(old.phyLs) {
+		for i, phy := range data.phyLs {
+			if phy.Id == old.phyLs[old.pos].Id {
+				data.pos = i
+				break
+			}
+		}
+	}
 	m.SetProperty(sparta.Data, data)
-	trViewInitTree(m, tv)
+	dt := tv.Property(sparta.Data)
+	if dt == nil {
+		trViewInitTree(m, tv)
+		return
+	}
+	prev := dt.(*trData)
+	title := fmt.Sprintf("%s: %s [id: %s]", cmd.Name, data.phyLs[data.pos].Name, data.phyLs[data.pos].Id)
+	m.SetProperty(sparta.Caption, title)
+	rect := tv.Property(sparta.Geometry).(image.Rectangle)
+	cur := setTree(data.phyLs[data.pos], rect)
+	cur.x, cur.y, cur.pos, cur.aln = prev.x, prev.y, prev.pos, prev.aln
+	cur.putOnScreen()
+	tv.SetProperty(sparta.Data, cur)
+	tv.Update()
 }
 
 func trViewInitTree(m, tv sparta.Widget) {
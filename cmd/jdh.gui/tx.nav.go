@@ -22,7 +22,8 @@ var txNav = &cmdapp.Command{
 	Long: `
 Description
 
-Tx.nav displays the taxonomy stored in the database.
+Tx.nav displays the taxonomy stored in the database. The view is updated
+when the taxonomy is modified by another client.
 
 Options
 
@@ -75,6 +76,7 @@ func txNavRun(c *cmdapp.Command, args []string) {
 	m.Capture(sparta.Command, txNavComm)
 	sparta.Block(nil)
 	go txNavInitList(m, l, db, nil, 0)
+	watchDB(db, func() { txNavRefresh(m) }, jdh.Taxonomy)
 
 	sparta.Run()
 }
@@ -246,6 +248,23 @@ func txNavAncList(m, l sparta.Widget, db jdh.DB, tax *jdh.Taxon) {
 	sparta.Unblock(nil)
 }
 
+// TxNavRefresh reloads the taxon list with the current content of the
+// database.
+func txNavRefresh(m sparta.Widget) {
+	d := m.Property(sparta.Data)
+	if d == nil {
+		return
+	}
+	sparta.Block(nil)
+	data := d.(*txList).reload()
+	title := fmt.Sprintf("%s: %s [id: %s]", cmd.Name, data.tax.Name, data.tax.Id)
+	m.SetProperty(sparta.Caption, title)
+	m.SetProperty(sparta.Data, data)
+	wnd["taxonList"].SetProperty(widget.ListList, data)
+	txNavInfo(wnd["info"], data)
+	sparta.Unblock(nil)
+}
+
 func txNavInfo(tx sparta.Widget, data *txList) {
 	if len(data.sels) == 0 {
 		tx.SetProperty(sparta.Data, nil)
@@ -0,0 +1,101 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// watchDelay is the time in which the events of a watch are collected
+// before a view is updated, so a burst of changes updates the view only
+// once.
+const watchDelay = 250 * time.Millisecond
+
+// watchRetry is the time between the attempts to watch the database again
+// after a watch is lost.
+const watchRetry = 5 * time.Second
+
+// WatchDB calls update each time elements of one of the given tables are
+// modified in the database. The events are read in their own goroutine,
+// and collected for a short time, so update is called only once for a
+// burst of changes. If the watch is lost (e.g. the events were not read
+// fast enough), the database is watched again, and update is called, as
+// some changes could be lost. If the database does not notify its
+// changes, it does nothing.
+func watchDB(db jdh.DB, update func(), tables ...jdh.Table) {
+	w, ok := db.(jdh.Watcher)
+	if !ok {
+		return
+	}
+	var table jdh.Table
+	if len(tables) == 1 {
+		table = tables[0]
+	}
+	l, err := w.Watch(table)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", cmd.ErrStr(err))
+		return
+	}
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	go func() {
+		for range changed {
+			time.Sleep(watchDelay)
+			// the events received while waiting are part of this
+			// update.
+			select {
+			case <-changed:
+			default:
+			}
+			update()
+		}
+	}()
+	go func() {
+		for {
+			scanEvents(l, notify, tables)
+			l.Close()
+			for i := 0; ; i++ {
+				if l, err = w.Watch(table); err == nil {
+					break
+				}
+				if i == 0 {
+					fmt.Fprintf(os.Stderr, "%s\n", cmd.ErrStr(err))
+				}
+				time.Sleep(watchRetry)
+			}
+			notify()
+		}
+	}()
+}
+
+// ScanEvents reads the events of a watch, and calls notify with each
+// event of the given tables, until the watch is finished.
+func scanEvents(l jdh.ListScanner, notify func(), tables []jdh.Table) {
+	for {
+		e := &jdh.Event{}
+		if err := l.Scan(e); err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "%s\n", cmd.ErrStr(err))
+			}
+			return
+		}
+		for _, t := range tables {
+			if e.Table == t {
+				notify()
+				break
+			}
+		}
+	}
+}
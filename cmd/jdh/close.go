@@ -11,14 +11,15 @@ import (
 
 var jdhClose = &cmdapp.Command{
 	Name:     "close",
-	Synopsis: `[-c|--commit] [-p|--port value]`,
+	Synopsis: `[-c|--commit] [--db name] [-p|--port value]`,
 	Short:    "closes the server",
 	IsCommon: true,
 	Long: `
 Description
 
 Close sends a shutdown request to the server. It is up to the server to
-honor this request.
+honor this request. The server only accepts the request from an editor in
+the local host.
 
 Options
 
@@ -26,6 +27,10 @@ Options
     --commit
       If set, the database will be saved into harddisk before closing.
       
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -p value
     --port value
       Sets the port in which the server will be listening. By default the
@@ -36,6 +41,7 @@ Options
 func init() {
 	jdhClose.Flag.BoolVar(&commFlag, "commit", false, "")
 	jdhClose.Flag.BoolVar(&commFlag, "c", false, "")
+	jdhClose.Flag.StringVar(&dbFlag, "db", "", "")
 	jdhClose.Flag.StringVar(&portFlag, "port", "", "")
 	jdhClose.Flag.StringVar(&portFlag, "p", "", "")
 	jdhClose.Run = closeRun
@@ -0,0 +1,75 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/js-arias/cmdapp"
+	ntv "github.com/js-arias/jdh/pkg/driver/native"
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+var dbAttach = &cmdapp.Command{
+	Name:     "db.attach",
+	Synopsis: `[-p|--port value] <name> <path>`,
+	Short:    "attaches a database to the server",
+	Long: `
+Description
+
+Db.attach attaches the database stored in the indicated directory to the
+server, so it can be used by the other commands with the --db option. The
+database is attached again each time the server is restarted, until it is
+detached with the db.detach command.
+
+A database can only be attached by an editor in the local host of the
+server, and its directory must be inside the root directory of the server
+(set with the --root option of the init command). A database can only be
+attached if it is not used by another server, or program.
+
+Options
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    <name>
+      The name of the database. It can only contain letters, digits, and
+      the characters '-', '_' and '.'.
+
+    <path>
+      The directory of the database, in the host of the server. If the
+      path is relative, it is taken from the current directory.
+	`,
+}
+
+func init() {
+	dbAttach.Flag.StringVar(&portFlag, "port", "", "")
+	dbAttach.Flag.StringVar(&portFlag, "p", "", "")
+	dbAttach.Run = dbAttachRun
+}
+
+func dbAttachRun(c *cmdapp.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("expecting database name and path"))
+		c.Usage()
+	}
+	path, err := filepath.Abs(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	openLocal(c)
+	vals := new(jdh.Values)
+	vals.Add(jdh.KeyId, args[0])
+	vals.Add(ntv.KeyPath, path)
+	if _, err := localDB.Exec(ntv.Attach, "", vals); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+}
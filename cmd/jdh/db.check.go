@@ -0,0 +1,82 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/native"
+)
+
+var dbCheck = &cmdapp.Command{
+	Name:     "db.check",
+	Synopsis: `[-d|--dir path] [-r|--repair]`,
+	Short:    "checks the integrity of the database",
+	Long: `
+Description
+
+Db.check reads the table files of a database, and prints the records that
+are invalid, for example, taxons whose parent is not in the database,
+specimens whose taxon is not in the database, or nodes whose tree is not
+in the database. Each invalid record is printed with its file, its line in
+the file, its id, and the problem found, for example:
+
+    specimens:12: [341] taxon 77 [associated with specimen 341] not in database
+
+Invalid records are ignored when the database is opened, so, except for
+invalid nodes, that are kept in the nodes file, they will be lost the next
+time the database is committed. If the -r, --repair option is set, the invalid records will be removed from the table files, and stored
+in the 'quarantine' file of the database directory, so they can be
+corrected, and added again to the database.
+
+Without the -r, --repair option, the database files are only read, so the
+command can be used while the database is used by a server. To repair a
+database, the database can not be used by a server, or another program.
+
+If there are invalid records, and the database is not repaired, the
+command exits with an error status.
+
+Options
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
+    -r
+    --repair
+      If set, the invalid records will be moved into the quarantine file.
+	`,
+}
+
+func init() {
+	dbCheck.Flag.StringVar(&dirFlag, "dir", "", "")
+	dbCheck.Flag.StringVar(&dirFlag, "d", "", "")
+	dbCheck.Flag.BoolVar(&repairFlag, "repair", false, "")
+	dbCheck.Flag.BoolVar(&repairFlag, "r", false, "")
+	dbCheck.Run = dbCheckRun
+}
+
+func dbCheckRun(c *cmdapp.Command, args []string) {
+	probs, err := native.Check(dirFlag, repairFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	for _, p := range probs {
+		fmt.Fprintf(os.Stdout, "%s:%d: [%s] %s\n", p.File, p.Line, p.Id, p.Error)
+	}
+	if len(probs) == 0 {
+		return
+	}
+	if repairFlag {
+		fmt.Fprintf(os.Stdout, "%d records moved into the quarantine file\n", len(probs))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(fmt.Sprintf("%d invalid records", len(probs))))
+	os.Exit(1)
+}
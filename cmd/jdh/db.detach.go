@@ -0,0 +1,60 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/cmdapp"
+	ntv "github.com/js-arias/jdh/pkg/driver/native"
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+var dbDetach = &cmdapp.Command{
+	Name:     "db.detach",
+	Synopsis: `[-p|--port value] <name>`,
+	Short:    "detaches a database from the server",
+	Long: `
+Description
+
+Db.detach detaches a database from the server. The database files are not
+modified: modifications that are not yet committed are kept in the journal
+of the database, and they will be applied when the database is opened
+again. Transactions in progress on the database are lost. The database is
+closed when the requests in progress that use it are answered. Only an
+editor in the local host of the server can detach a database.
+
+Options
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    <name>
+      The name of the database.
+	`,
+}
+
+func init() {
+	dbDetach.Flag.StringVar(&portFlag, "port", "", "")
+	dbDetach.Flag.StringVar(&portFlag, "p", "", "")
+	dbDetach.Run = dbDetachRun
+}
+
+func dbDetachRun(c *cmdapp.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("expecting database name"))
+		c.Usage()
+	}
+	openLocal(c)
+	vals := new(jdh.Values)
+	vals.Add(jdh.KeyId, args[0])
+	if _, err := localDB.Exec(ntv.Detach, "", vals); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+}
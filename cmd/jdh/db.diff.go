@@ -0,0 +1,93 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/native"
+)
+
+var dbDiff = &cmdapp.Command{
+	Name:     "db.diff",
+	Synopsis: `[-d|--dir path] <other>`,
+	Short:    "prints the differences between two databases",
+	Long: `
+Description
+
+Db.diff compares the database with the database in other directory, and
+prints the elements (datasets, taxons, specimens, rasterized distributions
+and trees) that are different. Elements are matched by its id, and then by
+its extern ids, so the same element can have different ids in each
+database. As elements can be added independently in each database, the
+elements with the same id are only matched if they have the same name, and
+for taxons, the same parent.
+
+Each difference is printed in a line, with the kind of the difference, the
+table, the id of the element in the database, the id of the element in the
+other database, the name of the element, and the fields that are
+different, for example:
+
+    changed	taxonomy	12	12	Homo sapiens	Authority,Comment
+
+Kinds of differences are:
+    added      the element is only in the other database.
+    removed    the element is only in the database.
+    changed    the element is different in each database.
+
+Trees are compared with its nodes, so changes in the nodes of a tree are
+reported as changes of the tree.
+
+This command only reads the files of the compared databases, so it can be
+used while the databases are used by a server, but only the operations
+already stored in the database files are compared.
+
+Options
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
+    <other>
+      The directory of the other database.
+	`,
+}
+
+func init() {
+	dbDiff.Flag.StringVar(&dirFlag, "dir", "", "")
+	dbDiff.Flag.StringVar(&dirFlag, "d", "", "")
+	dbDiff.Run = dbDiffRun
+}
+
+func dbDiffRun(c *cmdapp.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("expecting the directory of the other database"))
+		c.Usage()
+	}
+	diffs, err := native.Compare(dirFlag, args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	for _, d := range diffs {
+		printDiff(d)
+	}
+}
+
+// printDiff prints a difference between databases.
+func printDiff(d *native.Diff) {
+	fmt.Fprintf(os.Stdout, "%s\t%s\t%s\t%s\t%s", d.Kind, d.Table, d.Id, d.Other, d.Name)
+	if len(d.Fields) > 0 {
+		fmt.Fprintf(os.Stdout, "\t%s", strings.Join(d.Fields, ","))
+	}
+	if len(d.Note) > 0 {
+		fmt.Fprintf(os.Stdout, "\t%s", d.Note)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+}
@@ -7,6 +7,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 
@@ -15,6 +16,7 @@ import (
 
 	_ "github.com/js-arias/jdh/pkg/driver/gbif"
 	_ "github.com/js-arias/jdh/pkg/driver/inat"
+	_ "github.com/js-arias/jdh/pkg/driver/local"
 	_ "github.com/js-arias/jdh/pkg/driver/native"
 	_ "github.com/js-arias/jdh/pkg/driver/ncbi"
 )
@@ -25,16 +27,79 @@ var (
 	extDB   jdh.DB // extern database
 )
 
-// openLocal opens the local database.
+// localPrefix is the prefix of the port value used to open a database
+// directly from its directory, without a server.
+const localPrefix = "local:"
+
+// openLocal opens the local database. If the --db option is set, the
+// indicated database of the server is used.
 func openLocal(c *cmdapp.Command) {
-	localDB = openDB(c, "native", portFlag)
+	if strings.HasPrefix(portFlag, localPrefix) {
+		if len(dbFlag) > 0 {
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("option --db can not be used without a server"))
+			os.Exit(1)
+		}
+		localDB = openDB(c, "local", portFlag[len(localPrefix):])
+		return
+	}
+	port := portFlag
+	if len(dbFlag) > 0 {
+		sep := "?"
+		if strings.Contains(port, "?") {
+			sep = "&"
+		}
+		port += sep + "db=" + url.QueryEscape(dbFlag)
+	}
+	localDB = openDB(c, "native", port)
+}
+
+// beginTx starts a transaction in the local database.
+func beginTx(c *cmdapp.Command) {
+	if _, err := localDB.Exec(jdh.Begin, "", nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+}
+
+// commitTx finishes the transaction in progress in the local database, and
+// commits the database.
+func commitTx(c *cmdapp.Command) {
+	if _, err := localDB.Exec(jdh.Commit, "", nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+}
+
+// abortTx rolls back the transaction in progress in the local database,
+// and terminates the program with an error.
+func abortTx(c *cmdapp.Command, err error) {
+	localDB.Exec(jdh.Rollback, "", nil)
+	fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+	os.Exit(1)
 }
 
-// openExt opens the extern database.
+// openExt opens the extern database. The driver can include its
+// parameter, in the form <driver>:<param>, for example
+// "local:/path/to/db".
 func openExt(c *cmdapp.Command, driver, par string) {
+	if i := strings.Index(driver, ":"); i > 0 {
+		driver, par = driver[:i], driver[i+1:]
+	}
 	extDB = openDB(c, driver, par)
 }
 
+// openExtServ opens the extern database of a command that uses the ids of
+// the extern database as extern ids. As the name of the driver is the
+// service of the extern ids, a database used directly from its directory
+// is not accepted.
+func openExtServ(c *cmdapp.Command) {
+	if (extDBFlag == "local") || strings.Contains(extDBFlag, ":") {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("extern database "+extDBFlag+" can not be used as a service of extern ids"))
+		os.Exit(1)
+	}
+	openExt(c, extDBFlag, "")
+}
+
 // openDB opens a database.
 func openDB(c *cmdapp.Command, driver, par string) jdh.DB {
 	db, err := jdh.Open(driver, par)
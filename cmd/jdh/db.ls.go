@@ -0,0 +1,60 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/js-arias/cmdapp"
+	ntv "github.com/js-arias/jdh/pkg/driver/native"
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+var dbLs = &cmdapp.Command{
+	Name:     "db.ls",
+	Synopsis: `[-p|--port value]`,
+	Short:    "prints the databases attached to the server",
+	Long: `
+Description
+
+Db.ls prints the name and the directory of each database attached to the
+server.
+
+Options
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+	`,
+}
+
+func init() {
+	dbLs.Flag.StringVar(&portFlag, "port", "", "")
+	dbLs.Flag.StringVar(&portFlag, "p", "", "")
+	dbLs.Run = dbLsRun
+}
+
+func dbLsRun(c *cmdapp.Command, args []string) {
+	openLocal(c)
+	l, err := localDB.List(ntv.Databases, new(jdh.Values))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	for {
+		d := &ntv.Database{}
+		if err := l.Scan(d); err != nil {
+			if err == io.EOF {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stdout, "%s\t%s\n", d.Name, d.Path)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/native"
+)
+
+var dbMerge = &cmdapp.Command{
+	Name:     "db.merge",
+	Synopsis: `[-b|--base path] [-d|--dir path] -o|--output path <other>`,
+	Short:    "merges two databases",
+	Long: `
+Description
+
+Db.merge merges the database with the database in other directory, and
+stores the result as a new database in the output directory. The elements
+of both databases are matched as in the db.diff command.
+
+If the -b, --base option is given, it is the directory of the common
+ancestor of both databases (for example, a copy of the version from which
+both databases were edited). Then, an element that was added, changed, or
+removed in only one of the databases, is merged with that change. Without
+a base, the elements that are only in the other database are added, and
+any other element is taken from the database.
+
+When an element is changed in both databases, or the element can not be
+added to the merged database (for example, because its parent taxon was
+removed), the element is taken from the database, and a conflict is
+reported. If an element added in the other database has an id already
+used in the database, it will receive a new id.
+
+The changes taken from the other database, and the conflicts, are printed
+as in the db.diff command. If there are conflicts, the command exits with
+an error status, but the merged database is still stored.
+
+The merged database is written in a single step, with the parents always
+before its descendants, so it can be used instead of a line based merge
+(for example, from a version control system).
+
+This command only reads the files of the merged databases, so it can be
+used while the databases are used by a server, but only the operations
+already stored in the database files are merged.
+
+Options
+
+    -b path
+    --base path
+      Sets the directory of the common ancestor of the databases.
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
+    -o path
+    --output path
+      Sets the directory in which the merged database will be stored. The
+      directory must not contain a database. This option is required.
+
+    <other>
+      The directory of the other database.
+	`,
+}
+
+func init() {
+	dbMerge.Flag.StringVar(&baseFlag, "base", "", "")
+	dbMerge.Flag.StringVar(&baseFlag, "b", "", "")
+	dbMerge.Flag.StringVar(&dirFlag, "dir", "", "")
+	dbMerge.Flag.StringVar(&dirFlag, "d", "", "")
+	dbMerge.Flag.StringVar(&outFlag, "output", "", "")
+	dbMerge.Flag.StringVar(&outFlag, "o", "", "")
+	dbMerge.Run = dbMergeRun
+}
+
+func dbMergeRun(c *cmdapp.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("expecting the directory of the other database"))
+		c.Usage()
+	}
+	if len(outFlag) == 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("expecting an output directory"))
+		c.Usage()
+	}
+	diffs, err := native.Merge(dirFlag, args[0], baseFlag, outFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	conflicts := 0
+	for _, d := range diffs {
+		printDiff(d)
+		if d.Kind == native.Conflict {
+			conflicts++
+		}
+	}
+	if conflicts > 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(fmt.Sprintf("%d conflicts", conflicts)))
+		os.Exit(1)
+	}
+}
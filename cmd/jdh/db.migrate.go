@@ -0,0 +1,60 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/native"
+)
+
+var dbMigrate = &cmdapp.Command{
+	Name:     "db.migrate",
+	Synopsis: `[-d|--dir path]`,
+	Short:    "migrates the database to the current format",
+	Long: `
+Description
+
+Db.migrate updates the files of a database to the format used by the
+current version of jdh. The version of the format is stored in the 'meta'
+file of the database directory.
+
+Simple changes of the format are applied when the database is opened, but
+other changes require an explicit migration: in that case, the server will
+refuse to open the database until it is migrated. The previous version of
+each modified file is kept with the '.bak' extension.
+
+This command works directly over the database files, so it can not be used
+while the database is used by a server.
+
+Options
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+	`,
+}
+
+func init() {
+	dbMigrate.Flag.StringVar(&dirFlag, "dir", "", "")
+	dbMigrate.Flag.StringVar(&dirFlag, "d", "", "")
+	dbMigrate.Run = dbMigrateRun
+}
+
+func dbMigrateRun(c *cmdapp.Command, args []string) {
+	v, err := native.Migrate(dirFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	if v == native.Version {
+		fmt.Fprintf(os.Stdout, "database format version %d is up to date\n", v)
+		return
+	}
+	fmt.Fprintf(os.Stdout, "database migrated from version %d to %d\n", v, native.Version)
+}
@@ -16,6 +16,18 @@ software for management of taxonomic and biogeographic data.
 Use 'jdh help --all' for a list of available commands. To see help or
 information about a command type 'jdh help <command>'.
 
+Most commands use the database served by 'jdh init'. A database can also be
+used directly from its directory, without a server, using a port value of
+the form "local:<path>", for example:
+
+    jdh tx.ls -p local:/path/to/db
+
+The same form can be used with the -e, --extdb option, to use a database
+in another directory as the extern database, except in the commands that
+store the ids of the extern database as extern ids (ra.mk, sp.pop and
+tx.sync). A database used directly can not be served, or used by another
+command, at the same time.
+
 Author
 
 J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
@@ -31,7 +43,9 @@ Initializes the jdh server
 
 Synopsis
 
-    jdh init [-d|--dir path] [-p|--port value]
+    jdh init [--autocommit value] [--cert file] [-c|--commit]
+	[-d|--dir path] [--http value] [--key file] [--log file]
+	[--logsize value] [-p|--port value] [--root path]
 
 Description
 
@@ -43,28 +57,140 @@ By default, the server will be open in the current directory and at the
 port :16917, this values can be changed by -d, --dir and -p, --port options
 respectively.
 
+Modifications to the database that are not yet committed are stored in the
+'journal' file of the database directory. If the server is stopped before a
+commit, the journal will be applied the next time the server is started.
+When the database is committed, the previous version of each modified file
+is kept with the '.bak' extension. The records of the database files are
+stored one per line, in a stable order (taxa are stored after its parent,
+sorted by name, and other records are sorted by id), so the files can be
+kept in a version control system, such as git.
+
+The server is stopped with the close command, or with an interrupt or
+terminate signal (e.g. Ctrl-C). When stopped, the server stops accepting
+connections, and finishes the requests in progress. If the -c, --commit
+option is set, the database will be committed before the server exits. The
+database can also be committed periodically with the --autocommit option.
+
+Other databases can be attached to the server, while it is running, with
+the db.attach command. Each attached database is identified by a name, that
+is used with the --db option of the other commands, for example:
+
+    jdh tx.ls --db birds
+
+Only databases inside the directory given with the --root option can be
+attached, and only by an editor in the local host. If the option is not
+set, the server does not accept attached databases. The attached databases
+are stored in the 'databases' file of the database directory, and they are
+attached again when the server is restarted.
+
+The version of the format of the database files is stored in the 'meta'
+file of the database directory. If the database uses an outdated format,
+it must be updated with the db.migrate command before it can be served.
+
+The most recent changes of the database are stored in the 'history' file of
+the database directory. They can be reverted with the undo command.
+
+Clients can watch the changes of the database. The server keeps the
+connection of a watch open, and sends the table, id and query of each
+modified element, so, for example, the windows of jdh.gui are updated when
+the database is modified by another client.
+
+Only clients in the local host can modify the database, other clients can
+only read it. Users with editor role, created with the user command, can
+modify the database from any host.
+
+Each request is logged as a JSON object in a single line, with the address
+of the client, the name of its user (if any), the query, the time used to
+answer it, the number of elements returned, and the result or the error of
+the request. The errors of the server (e.g. a failed automatic commit) are
+logged in the same way, with only the time and the error. By default the
+log is written to the standard output, but it can be written into a file
+with the --log option. When the file reaches its maximum size, it is
+renamed with the extension ".1" (previous files are renamed ".2", ".3", and
+so on, up to ".5"), and a new file is started.
+
+If a certificate and its key are given with the --cert and --key options,
+the server will only accept TLS connections. Clients must use a port value
+of the form "tls://host:port", and if the certificate is not signed by a
+known certificate authority, the file with the certificate of the authority
+must be given, for example:
+
+    jdh tx.ls -p "tls://myserver:16917?ca=myca.pem"
+
+If the --http option is given, the server will also answer HTTP requests at
+the given address, so the database can be used by programs that do not use
+the jdh driver. The tables of the database are mapped into paths, and the
+queries into methods, for example:
+
+    GET    /taxonomy/<id>         retrieves a taxon
+    GET    /specimens?taxon=<id>  lists the specimens of a taxon
+    POST   /taxonomy              adds the taxon in the body
+    PATCH  /taxonomy/<id>         sets the values of the object in the body
+    DELETE /taxonomy/<id>         deletes a taxon
+    POST   /commit                commits the database
+
+Elements are encoded in JSON. An attached database can be used with the
+"db" parameter. The token of a user can be given in the "token" parameter,
+or in an "Authorization: Bearer <token>" header. Unlike the jdh driver, the
+HTTP interface requires the token of an editor to modify the database, even
+from the local host. If a certificate is given, the HTTP interface will
+only accept HTTPS connections.
+
 Options
 
+    --autocommit value
+      Sets the interval between automatic commits of the database, for
+      example "10m" for a commit every ten minutes. Valid time units are
+      "s", "m" and "h".
+
+    --cert file
+      Sets the file with the certificate of the server, in PEM format.
+
+    -c
+    --commit
+      If set, the database, and the attached databases, will be committed
+      when the server is stopped.
+
     -d path
     --dir path
       Sets the directory in which the database files will be located. By
       default, the current directory is used as the directory.
 
+    --http value
+      Sets the address in which the HTTP interface will be listening, for
+      example ":8080".
+
+    --key file
+      Sets the file with the private key of the certificate, in PEM format.
+
+    --log file
+      Sets the file in which the requests will be logged.
+
+    --logsize value
+      Sets the maximum size, in megabytes, of the log file. By default the
+      value is 10.
+
     -p value
     --port value
       Sets the port in which the server will be listening. By default the
       value is ":16917"
 
+    --root path
+      Sets the directory of the databases that can be attached to the
+      server. By default, no database can be attached.
+
 Closes the server
 
 Synopsis
 
-    jdh close [-c|--commit] [-p|--port value]
+    jdh close [-c|--commit] [--db name] [-p|--port value]
 
 Description
 
 Close sends a shutdown request to the server. It is up to the server to
-honor this request.
+honor this request. The server only accepts the request from an editor in
+the local host.
 
 Options
 
@@ -72,16 +198,408 @@ Options
     --commit
       If set, the database will be saved into harddisk before closing.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+Prints the status of the server
+
+Synopsis
+
+    jdh status [--db name] [-m|--machine] [-p|--port value]
+
+Description
+
+Status prints the status of the jdh database server: the time the server
+has been running, the time of the last commit, the number of elements of
+each table, and the number of requests answered by the server. Tables with
+changes that are not yet committed are marked with an asterisk.
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -m
+    --machine
+      If set, the output will be machine readable. That is, just key=value
+      pairs will be printed.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+Prints the recent changes of the database
+
+Synopsis
+
+    jdh history [--db name] [-n|--number value] [-p|--port value]
+
+Description
+
+History prints the most recent changes made in the database, from the oldest
+to the newest. Each change is printed with its id, its date, and the queries
+made by the change. Changes made with a transaction (for example, tx.force)
+include all the queries of the transaction.
+
+The id of the change can be used with the undo command, to revert the change.
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -n value
+    --number value
+      Sets the number of changes to be printed. By default the last 10
+      changes are printed. If the value is 0, all the changes stored in the
+      database will be printed.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+Reverts changes of the database
+
+Synopsis
+
+    jdh undo [-c|--commit] [--db name] [-i|--id value] [-p|--port value]
+
+Description
+
+Undo reverts the last change made in the database. If an id is given, the
+indicated change, and all the changes made after it, will be reverted. Use
+the history command to see the list of changes that can be reverted.
+
+A reverted change is removed from the history, so it can not be reverted
+again. The reversion is not saved into harddisk, unless the option -c,
+--commit is defined.
+
+Options
+
+    -c
+    --commit
+      If set, the database will be saved into harddisk after the changes
+      are reverted.
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -i value
+    --id value
+      Sets the id of the change to be reverted.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+Manages the users of the database
+
+Synopsis
+
+    jdh user [-d|--dir path] [-r|--role name] [-x|--delete] [<name>]
+
+Description
+
+User manages the users that can access the database from other hosts. Each
+user has a token, that must be used by the client to connect with the
+server, either using the JDH_TOKEN environment variable, or as part of the
+port value, for example:
+
+    jdh tx.ls -p "myserver:16917?token=<token>"
+
+Clients without a token can only read the database, except if they are
+connected from the local host.
+
+Without arguments, the list of users and their roles will be printed. If a
+name is given, the user will be added to the database, and its token will
+be printed. If the user already exists, it will receive a new token, and
+the old token will be invalid.
+
+This command works directly over the database files, so it can be used when
+the server is running, or not.
+
+Options
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
+    -r name
+    --role name
+      Sets the role of the user. Valid values are:
+          reader    the user can only read the database.
+          editor    the user can read and modify the database.
+      By default, the user will be a reader.
+
+    -x
+    --delete
+      If set, the user will be removed from the database.
+
+    <name>
+      The name of the user.
+
+Attaches a database to the server
+
+Synopsis
+
+    jdh db.attach [-p|--port value] <name> <path>
+
+Description
+
+Db.attach attaches the database stored in the indicated directory to the
+server, so it can be used by the other commands with the --db option. The
+database is attached again each time the server is restarted, until it is
+detached with the db.detach command.
+
+A database can only be attached by an editor in the local host of the
+server, and its directory must be inside the root directory of the server
+(set with the --root option of the init command). A database can only be
+attached if it is not used by another server, or program.
+
+Options
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    <name>
+      The name of the database. It can only contain letters, digits, and
+      the characters '-', '_' and '.'.
+
+    <path>
+      The directory of the database, in the host of the server. If the
+      path is relative, it is taken from the current directory.
+
+Checks the integrity of the database
+
+Synopsis
+
+    jdh db.check [-d|--dir path] [-r|--repair]
+
+Description
+
+Db.check reads the table files of a database, and prints the records that
+are invalid, for example, taxons whose parent is not in the database,
+specimens whose taxon is not in the database, or nodes whose tree is not
+in the database. Each invalid record is printed with its file, its line in
+the file, its id, and the problem found, for example:
+
+    specimens:12: [341] taxon 77 [associated with specimen 341] not in database
+
+Invalid records are ignored when the database is opened, so, except for
+invalid nodes, that are kept in the nodes file, they will be lost the next
+time the database is committed. If the -r, --repair option is set, the invalid records will be removed from the table files, and stored
+in the 'quarantine' file of the database directory, so they can be
+corrected, and added again to the database.
+
+Without the -r, --repair option, the database files are only read, so the
+command can be used while the database is used by a server. To repair a
+database, the database can not be used by a server, or another program.
+
+If there are invalid records, and the database is not repaired, the
+command exits with an error status.
+
+Options
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
+    -r
+    --repair
+      If set, the invalid records will be moved into the quarantine file.
+
+Detaches a database from the server
+
+Synopsis
+
+    jdh db.detach [-p|--port value] <name>
+
+Description
+
+Db.detach detaches a database from the server. The database files are not
+modified: modifications that are not yet committed are kept in the journal
+of the database, and they will be applied when the database is opened
+again. Transactions in progress on the database are lost. The database is
+closed when the requests in progress that use it are answered. Only an
+editor in the local host of the server can detach a database.
+
+Options
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    <name>
+      The name of the database.
+
+Prints the differences between two databases
+
+Synopsis
+
+    jdh db.diff [-d|--dir path] <other>
+
+Description
+
+Db.diff compares the database with the database in other directory, and
+prints the elements (datasets, taxons, specimens, rasterized distributions
+and trees) that are different. Elements are matched by its id, and then by
+its extern ids, so the same element can have different ids in each
+database. As elements can be added independently in each database, the
+elements with the same id are only matched if they have the same name, and
+for taxons, the same parent.
+
+Each difference is printed in a line, with the kind of the difference, the
+table, the id of the element in the database, the id of the element in the
+other database, the name of the element, and the fields that are
+different, for example:
+
+    changed	taxonomy	12	12	Homo sapiens	Authority,Comment
+
+Kinds of differences are:
+    added      the element is only in the other database.
+    removed    the element is only in the database.
+    changed    the element is different in each database.
+
+Trees are compared with its nodes, so changes in the nodes of a tree are
+reported as changes of the tree.
+
+This command only reads the files of the compared databases, so it can be
+used while the databases are used by a server, but only the operations
+already stored in the database files are compared.
+
+Options
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
+    <other>
+      The directory of the other database.
+
+Prints the databases attached to the server
+
+Synopsis
+
+    jdh db.ls [-p|--port value]
+
+Description
+
+Db.ls prints the name and the directory of each database attached to the
+server.
+
+Options
+
     -p value
     --port value
       Sets the port in which the server will be listening. By default the
       value is ":16917"
 
+Merges two databases
+
+Synopsis
+
+    jdh db.merge [-b|--base path] [-d|--dir path] -o|--output path <other>
+
+Description
+
+Db.merge merges the database with the database in other directory, and
+stores the result as a new database in the output directory. The elements
+of both databases are matched as in the db.diff command.
+
+If the -b, --base option is given, it is the directory of the common
+ancestor of both databases (for example, a copy of the version from which
+both databases were edited). Then, an element that was added, changed, or
+removed in only one of the databases, is merged with that change. Without
+a base, the elements that are only in the other database are added, and
+any other element is taken from the database.
+
+When an element is changed in both databases, or the element can not be
+added to the merged database (for example, because its parent taxon was
+removed), the element is taken from the database, and a conflict is
+reported. If an element added in the other database has an id already
+used in the database, it will receive a new id.
+
+The changes taken from the other database, and the conflicts, are printed
+as in the db.diff command. If there are conflicts, the command exits with
+an error status, but the merged database is still stored.
+
+The merged database is written in a single step, with the parents always
+before its descendants, so it can be used instead of a line based merge
+(for example, from a version control system).
+
+This command only reads the files of the merged databases, so it can be
+used while the databases are used by a server, but only the operations
+already stored in the database files are merged.
+
+Options
+
+    -b path
+    --base path
+      Sets the directory of the common ancestor of the databases.
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
+    -o path
+    --output path
+      Sets the directory in which the merged database will be stored. The
+      directory must not contain a database. This option is required.
+
+    <other>
+      The directory of the other database.
+
+Migrates the database to the current format
+
+Synopsis
+
+    jdh db.migrate [-d|--dir path]
+
+Description
+
+Db.migrate updates the files of a database to the format used by the
+current version of jdh. The version of the format is stored in the 'meta'
+file of the database directory.
+
+Simple changes of the format are applied when the database is opened, but
+other changes require an explicit migration: in that case, the server will
+refuse to open the database until it is migrated. The previous version of
+each modified file is kept with the '.bak' extension.
+
+This command works directly over the database files, so it can not be used
+while the database is used by a server.
+
+Options
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
 Deletes a dataset
 
 Synopsis
 
-    jdh ds.del -i|--id value [-p|--port value]
+    jdh ds.del -i|--id value [--db name] [-p|--port value]
 
 Description
 
@@ -90,6 +608,10 @@ database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated dataset id. It is a required option.
@@ -103,8 +625,8 @@ Imports dataset data
 
 Synopsis
 
-    jdh ds.in [-f|--format value] [-p|--port value] [-v|--verbose]
-	[<file>...]
+    jdh ds.in [--db name] [-f|--format value] [-p|--port value]
+	[-v|--verbose] [<file>...]
 
 Description
 
@@ -116,6 +638,10 @@ line corresponds to a dataset (lines starting with '#' or ';' will be ignored).
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -f value
     --format value
       Sets the format used in the source data. Valid values are:
@@ -139,7 +665,7 @@ Prints dataset information
 
 Synopsis
 
-    jdh ds.info -i|--id value [-e|--extdb name] [-k|--key value]
+    jdh ds.info -i|--id value [--db name] [-e|--extdb name] [-k|--key value]
 	[-m|--machine] [-p|--port value]
 
 Description
@@ -148,6 +674,10 @@ Ds.info prints general information of a dataset in the database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -184,7 +714,7 @@ Prints a list of datasets
 
 Synopsis
 
-    jdh ds.ls [-c|--citation] [-e|--extdb name] [-l|--license]
+    jdh ds.ls [-c|--citation] [--db name] [-e|--extdb name] [-l|--license]
 	[-m|--machine] [-p|--port value] [-u|--url] [-v|--verbose]
 
 Description
@@ -198,6 +728,10 @@ Options
     --citation
       If set, citation information will be printed.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -231,7 +765,7 @@ Sets a dataset value
 
 Synopsis
 
-    jdh ds.set -i|--id value [-p|--port value] [<key=value>...]
+    jdh ds.set -i|--id value [--db name] [-p|--port value] [<key=value>...]
 
 Description
 
@@ -245,6 +779,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Indicate the dataset to be set. It is a required option.
@@ -272,8 +810,8 @@ Deletes rasterized distributions
 
 Synopsis
 
-    jdh ra.del [-i|--id value] [-p|--port value] [-t|--taxon value]
-	[<name> [<parentname>]]
+    jdh ra.del [--db name] [-i|--id value] [-p|--port value]
+	[-t|--taxon value] [<name> [<parentname>]]
 
 Description
 
@@ -285,6 +823,10 @@ that operations.
 
 Operations
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated raster id.
@@ -313,7 +855,7 @@ Prints information about a rasterized distribution
 
 Synopsis
 
-    jdh ra.info -i|--id value [-k|--key value] [-m|--machine]
+    jdh ra.info -i|--id value [--db name] [-k|--key value] [-m|--machine]
 	[-p|--port value]
 
 Description
@@ -323,6 +865,10 @@ database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated rasterized distribution id.
@@ -356,7 +902,7 @@ Prints a list of rasterized distributions
 
 Synopsis
 
-    jdh ra.ls [-c|--children] [-m|--machine] [-p|--port value]
+    jdh ra.ls [-c|--children] [--db name] [-m|--machine] [-p|--port value]
 	[-t|--taxon value] [-v|--verbose] [<name> [<parentname>]]
 
 Description
@@ -370,6 +916,10 @@ Options
       If set, the rastes associated with the indicated taxon, as well
       as the ones from its descendants, will be printed.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -m
     --machine
       If set, the output will be machine readable. That is, just ids will
@@ -404,8 +954,8 @@ Creates raster distributions from specimen data
 
 Synopsis
 
-    jdh ra.mk [-e|--extdb name] [-p|--port value] [-r|--rank name]
-	[-s|--size value] [-t|--taxon value] [-d|--validated]
+    jdh ra.mk [--db name] [-e|--extdb name] [-p|--port value]
+	[-r|--rank name] [-s|--size value] [-t|--taxon value] [-d|--validated]
 	[<name> [<parentname>]]
 
 Description
@@ -423,6 +973,10 @@ rank will be rasterized.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Sets the a extern database to extract distribution data.
@@ -441,12 +995,13 @@ Options
     --rank name
       If set, only taxons below the indicated rank will be populated.
       Valid values are:
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -s value
     --size value
@@ -474,7 +1029,7 @@ Sets a value in a rasterized distribution
 
 Synopsis
 
-    jdh ra.set -i|--id value [-p|--port value] [<key=value>...]
+    jdh ra.set -i|--id value [--db name] [-p|--port value] [<key=value>...]
 
 Description
 
@@ -489,6 +1044,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Indicate the raster to be set. It is a required option.
@@ -517,8 +1076,8 @@ Deletes specimens
 
 Synopsis
 
-    jdh sp.del [-i|--id value] [-p|--port value] [-t|--taxon value]
-	[<name> [<parentname>]]
+    jdh sp.del [--db name] [-i|--id value] [-p|--port value]
+	[-t|--taxon value] [<name> [<parentname>]]
 
 Description
 
@@ -529,6 +1088,10 @@ delete a taxon use the command tx.del.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated specimen id.
@@ -557,9 +1120,9 @@ Imports specimen data
 
 Synopsis
 
-    jdh sp.in [-a|--anc value] [-d|--dataset value] [-f|--format value]
-	[-p|--port value] [-r|--rank name] [-s|--skip] [-v|--verbose]
-	[<file>...]
+    jdh sp.in [-a|--anc value] [-d|--dataset value] [--db name]
+	[-f|--format value] [-n|--new] [-p|--port value] [-r|--rank name]
+	[-s|--skip] [-v|--verbose] [<file>...]
 
 Description
 
@@ -572,6 +1135,10 @@ line corresponds to a specimen (lines starting with '#' or ';' will be ignored).
 If the taxons in the input file are not in the database, they will be
 added to the root, valid, and unranked. Use options -a, --anc, -r, --rank
 to change this behavior.
+In ndm format, the authority is taken from the name of the taxon. If the
+rank is species, or below species, the genus (and the species of an
+infraspecific taxon) is also taken from the name, and added if it is not
+in the database.
 
 Options
 
@@ -584,6 +1151,10 @@ Options
       If defined, new specimens will be set to the indicated dataset. If
       the value is not a valid id, then this option will be ignored.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -f value
     --format value
       Sets the format used in the source data. Valid values are:
@@ -595,18 +1166,28 @@ Options
       If set, taxons in the database will be ignored. Useful to add a
       dataset after correcting warnings.
 
+    -n
+    --new
+      If set, taxons with names spelled as the names of taxons already in
+      the database, except for the case, or the hyphens, will be added. By
+      default, these taxons are not added, and the names are reported.
+      Taxons with other similar names (for example, a misspelling of a
+      name, or a congener with a similar epithet) are always added, and
+      the similar names are reported.
+
     -r name
     --rank name
       Set the rank of the added taxon. If the taxon has a parent (the -a,
       --anc options) the parent must be concordant with the given rank.
       Valid values are:
-      	  unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          unranked
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -p value
     --port value
@@ -626,7 +1207,7 @@ Prints general specimen information
 
 Synopsis
 
-    jdh sp.info -i|--id value [-e|--extdb name] [-k|--key value]
+    jdh sp.info -i|--id value [--db name] [-e|--extdb name] [-k|--key value]
 	[-m|--machine] [-p|--port value]
 
 Description
@@ -635,6 +1216,10 @@ Sp.info prints general information of an specimen in the database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -689,7 +1274,7 @@ Validate and add specimen georeferences
 
 Synopsis
 
-    jdh sp.gref [-a|--add] [-c|--correct] [-p|--port value]
+    jdh sp.gref [-a|--add] [-c|--correct] [--db name] [-p|--port value]
 	[-t|--taxon value] [-u|--uncert value] [-v|--verbose]
 	[<name> [<parentname>]]
 
@@ -723,6 +1308,10 @@ Options
       If set, it will try to correct invalid georeferences. It will try it
       by flipping lon, lat values, and changing lon, lat values sings.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -p value
     --port value
       Sets the port in which the server will be listening. By default the
@@ -760,7 +1349,7 @@ Prints a list of specimens
 
 Synopsis
 
-    jdh sp.ls [-c|--children] [-e|--extdb name] [-g|--georef]
+    jdh sp.ls [-c|--children] [--db name] [-e|--extdb name] [-g|--georef]
 	[-m|--machine] [-n|--nonref] [-p|--port value] [-r|--country name]
 	[-t|--taxon value] [-v|--verbose] [<name> [<parentname>]]
 
@@ -778,6 +1367,10 @@ Options
       If set, the speciemens associated with the indicated taxon, as well
       as the ones from its descendants, will be printed.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -831,8 +1424,8 @@ Add specimens from an extern database
 
 Synopsis
 
-    jdh sp.pop -e|--extdb name [-p|--port value] [-r|--rank name]
-	[-t|--taxon value] [<name> [<parentname>]]
+    jdh sp.pop -e|--extdb name [--db name] [-p|--port value]
+	[-r|--rank name] [-t|--taxon value] [<name> [<parentname>]]
 
 Description
 
@@ -846,6 +1439,10 @@ rank will be populated.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Sets the extern database.
@@ -867,12 +1464,13 @@ Options
     --rank name
       If set, only taxons below the indicated rank will be populated.
       Valid values are:
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -t value
     --taxon value
@@ -893,7 +1491,7 @@ Sets an specimen value
 
 Synopsis
 
-    jdh sp.set -i|--id value [-p|--port value] [<key=value>...]
+    jdh sp.set -i|--id value [--db name] [-p|--port value] [<key=value>...]
 
 Description
 
@@ -907,6 +1505,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Indicate the specimen to be set. It is a required option.
@@ -951,7 +1553,7 @@ Deletes a tree or a node
 
 Synopsis
 
-    jdh tr.del [-c|--collapse] [-i|--id value] [-n|--node value]
+    jdh tr.del [-c|--collapse] [--db name] [-i|--id value] [-n|--node value]
 	[-p|--port value]
 
 Description
@@ -969,6 +1571,10 @@ Options
       of the node. If the node to be collapsed is the root node, then, the
       collapse will be ignored.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated tree id.
@@ -982,12 +1588,51 @@ Options
       Sets the port in which the server will be listening. By default the
       value is ":16917"
 
+Enforces valid taxons as tree terminals
+
+Synopsis
+
+    jdh tr.force [--db name] [-i|--id value] [-p|--port value] [-r|--report]
+
+Description
+
+Tr.force enforces the terminals of the trees of the database to be all valid
+taxons. If the taxon is not presented in the tree, then the invalid terminal
+will be replaced with the valid taxon, if the valid taxon is already in the
+tree, then, the invalid taxon will be deleted.
+
+All the changes are done in a single transaction, so if an error happens, no
+tree will be modified.
+
+If the option -r, --report is set, it only show what terminals should be
+removed without performing any operation.
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -i value
+    --id value
+      Search for the indicated tree id.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    -r
+    --report
+      If set, then only the modifications will be printed, without doing any
+      operation.
+
 Imports tree data
 
 Synopsis
 
-    jdh tr.in [-a|--anc value] [-f|--format value] [-p|--port value]
-	[-r|--rank value] [-v|--verbose] [<file>...]
+    jdh tr.in [-a|--anc value] [--db name] [-f|--format value]
+	[-p|--port value] [-r|--rank value] [-v|--verbose] [<file>...]
 
 Description
 
@@ -1004,6 +1649,10 @@ Options
       Sets the parent of the terminals of the tree. The value must be a valid
       id.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -f value
     --format value
       Sets the format used in the source data. Valid values are:
@@ -1019,13 +1668,14 @@ Options
       Set the rank of the added taxon. If the taxon has a parent (the -a,
       --anc options) the parent must be concordant with the given rank.
       Valid values are:
-      	  unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          unranked
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -v
     --verbose
@@ -1039,8 +1689,8 @@ Prints tree or node information
 
 Synopsis
 
-    jdh tr.info [-i|--id value] [-n|--node value] [-k|--key value]
-	[-m|--machine] [-p|--port value]
+    jdh tr.info [--db name] [-i|--id value] [-n|--node value]
+	[-k|--key value] [-m|--machine] [-p|--port value]
 
 Description
 
@@ -1050,6 +1700,10 @@ database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated tree id.
@@ -1087,7 +1741,7 @@ Prints a list of trees or nodes
 
 Synopsis
 
-    jdh tr.ls [-a|--ancs] [-n|--node value] [-m|--machine]
+    jdh tr.ls [-a|--ancs] [--db name] [-n|--node value] [-m|--machine]
 	[-p|--port value] [-v|--verbose]
 
 Description
@@ -1104,6 +1758,10 @@ Options
       If set, and the option -i, --id is set, the parents of the indicated
       node will be printed.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -m
     --machine
       If set, the output will be machine readable. That is, just ids will
@@ -1127,8 +1785,8 @@ Set a tree or node value
 
 Synopsis
 
-    jdh tr.set [-i|--id value] [-n|--node value] [-p|--port value]
-	[<key=value>...]
+    jdh tr.set [--db name] [-i|--id value] [-n|--node value]
+	[-p|--port value] [<key=value>...]
 
 Description
 
@@ -1143,6 +1801,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated tree id.
@@ -1178,7 +1840,7 @@ Deletes a taxon
 
 Synopsis
 
-    jdh tx.del [-c|--collapse] [-i|--id value] [-p|--port value]
+    jdh tx.del [-c|--collapse] [--db name] [-i|--id value] [-p|--port value]
 	[<name> [<parentname>]]
 
 Description
@@ -1197,6 +1859,10 @@ Options
       then the valid descendants will be assigned to the root of the
       taxonomy and synonyms will be deleted with the taxon.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated taxon id.
@@ -1216,20 +1882,80 @@ Options
       descendants of the indicated name. Ignored if option -i or --id
       are defined.
 
-Enforces a ranked taxonomy
+Finds duplicated taxons
 
 Synopsis
 
-    jdh tx.force [-i|--id value] [-p|--port value] [-r|--rank name]
+    jdh tx.dup [--db name] [-i|--id value] [-p|--port value]
 	[<name> [<parentname>]]
 
 Description
 
+Tx.dup searches the taxonomy for taxons that are likely duplicates, for
+example, the same species added from different services under different
+parents. Duplicates can be merged with 'jdh tx.merge'.
+
+Taxons with the same canonical name (i.e. the name without the authority)
+are reported as duplicates if they have the same authority and rank (or if
+the authority or the rank is not defined in one of them). Otherwise they
+are reported as homonyms. Extern identifiers are not compared, as the
+database does not accept the same extern identifier in two taxons.
+
+Each group of taxons is printed with the kind of the group, and the name
+shared by the taxons, followed by the id, the name, the authority, the
+rank and the parent of each taxon, for example:
+
+    duplicate	Puma concolor
+    	12	Puma concolor (Linnaeus, 1771)	species	8 Puma
+    	341	Puma concolor	species	277 Felidae
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -i value
+    --id value
+      Search only the descendants of the indicated taxon id.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    <name>
+      Search only the descendants of the indicated name. If there are more
+      than one taxon, then the list of possible candidates will be printed
+      and the program will be terminated. Ignored if option -i or --id are
+      defined.
+
+    <parentname>
+      If defined, the taxon search with <name> will be limited to
+      descendants of the indicated name. Ignored if option -i or --id
+      are defined.
+
+Enforces a ranked taxonomy
+
+Synopsis
+
+    jdh tx.force [--db name] [-i|--id value] [-p|--port value]
+	[-r|--rank name] [<name> [<parentname>]]
+
+Description
+
 Tx.force enforces the database to be ranked, synonymizing all rankless taxa
 with their most inmmediate ranked taxon.
 
+All the changes are done in a single transaction, so if an error happens, no
+taxon will be modified.
+
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated taxon id.
@@ -1243,13 +1969,14 @@ Options
     --rank name
       If set search only for taxons below the indicated rank.
       Valid values are:
-      	  unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          unranked
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     <name>
       Search for the indicated name. If there are more than one taxon,
@@ -1265,8 +1992,9 @@ Imports taxon data
 
 Synopsis
 
-    jdh tx.in [-a|--anc value] [-f|--format value] [-p|--port value]
-	[-r|--rank name] [-s|--synonym] [-v|--verbose] [<file>...]
+    jdh tx.in [-a|--anc value] [--db name] [-f|--format value]
+	[-n|--new] [-p|--port value] [-r|--rank name] [-s|--synonym]
+	[-v|--verbose] [<file>...]
 
 Description
 
@@ -1275,9 +2003,19 @@ Tx.in reads taxon data from the indicated files, or the standard input
 
 Default input format is txt. If the format is txt, it is assummed that each
 line corresponds to a taxon (lines starting with '#' or ';' will be ignored).
+The name of the taxon can be followed by its authority, for example:
+
+    Puma concolor (Linnaeus, 1771)
+    Abies alba subsp. apennina Brullo, Scelsi & Spamp.
+
+Qualifiers of open nomenclature (sp., cf., aff.) are part of the name (for
+example, 'Puma sp. 1', or 'Puma cf. concolor'). Names that are not formal
+scientific names (for example, 'Clade1') are added as they are.
 
 By default, taxons will be added to the root of the taxonomy, valid, and
-unranked.
+unranked. If the rank is species, or below species, the genus (and the
+species of an infraspecific taxon) is taken from the name of the taxon, and
+added if it is not in the database.
 
 Options
 
@@ -1285,11 +2023,24 @@ Options
     --anc value
       Sets the parent of the added taxons. The value must be a valid id.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -f value
     --format value
       Sets the format used in the source data. Valid values are:
           txt        Txt format
 
+    -n
+    --new
+      If set, taxons with names spelled as the names of taxons already in
+      the database, except for the case, or the hyphens, will be added. By
+      default, these taxons are not added, and the names are reported.
+      Taxons with other similar names (for example, a misspelling of a
+      name, or a congener with a similar epithet) are always added, and
+      the similar names are reported.
+
     -p value
     --port value
       Sets the port in which the server will be listening. By default the
@@ -1300,13 +2051,14 @@ Options
       Set the rank of the added taxon. If the taxon has a parent (the -a,
       --anc options) the parent must be concordant with the given rank.
       Valid values are:
-      	  unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          unranked
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -s
     --synonym
@@ -1327,16 +2079,30 @@ Prints general taxon information
 
 Synopsis
 
-    jdh tx.info [-e|--extdb name] [-i|--id value] [-k|--key value]
-	[-m|--machine] [-p|--port value] [<name> [<parentname>]]
+    jdh tx.info [--db name] [-e|--extdb name] [-i|--id value]
+	[-k|--key value] [-m|--machine] [-p|--port value]
+	[<name> [<parentname>]]
 
 Description
 
 Tx.info prints general information of a taxon in the database. For the
 list of parents, descendants of synonyms of the taxon, use 'jdh tx.ls'.
 
+The information includes the history of the taxon, i.e. the nomenclatural
+acts that changed the parent, or the validity, of the taxon (for example,
+when the taxon is set as a synonym with 'jdh tx.set'). Each act is printed
+with its date, the placement of the taxon before and after the act, and
+the reference of the act, if any. In machine readable output, each act is
+printed as a history key, with the date, the old parent, the old validity,
+the new parent, the new validity, and the reference of the act, separated
+by tabs. The history is only stored in the local database.
+
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -1357,12 +2123,29 @@ Options
           comment        A free text comment on the taxon.
           extern         Extern identifiers of the taxon, in the form
                          <service>:<key>, for example: gbif:5216933.
+          history        Nomenclatural acts of the taxon.
           name           Name of the taxon.
           parent         Id of the new parent.
           rank           The taxon rank.
           synonym        Prints the parent of the taxon, if it is a synonym.
                          If the taxon is valid, the valid string will be printed.
           valid          See synonym.
+      The parts of the parsed name of the taxon can be printed with the
+      following keys:
+          genus          Genus of the name, or the name of a taxon above
+                         the genus.
+          infrageneric   Infrageneric epithet (e.g. subgenus).
+          specific       Specific epithet.
+          infraspecific  Infraspecific epithet.
+          marker         Rank marker of the infraspecific epithet (or the
+                         infrageneric epithet), for example: var.
+          authors        Authors of the name, without the parenthetical
+                         authors.
+          year           Year of the name.
+          parauthors     Parenthetical authors of the name, i.e. the
+                         authors of the original combination, or the
+                         basionym.
+          paryear        Year of the parenthetical authors.
 
     -m
     --machine
@@ -1388,7 +2171,7 @@ Prints a list of taxons
 
 Synopsis
 
-    jdh tx.ls [-a|--ancs] [-e|--extdb name] [-i|--id value]
+    jdh tx.ls [-a|--ancs] [--db name] [-e|--extdb name] [-i|--id value]
 	[-m|--machine] [-p|--port value] [-r|--rank name] [-s|--synonym]
 	[-v|--verbose] [<name> [<parentname>]]
 
@@ -1407,6 +2190,10 @@ Options
     --ancs
       If set, the parents of the indicated taxon will be printed.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -1434,12 +2221,13 @@ Options
       If indicated, the only taxons at the given rank will be printed. Valid
       values are:
           unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -s
     --synonym
@@ -1460,18 +2248,67 @@ Options
       descendants of the indicated name. Ignored if option -i or --id
       are defined.
 
+Merges a taxon into another taxon
+
+Synopsis
+
+    jdh tx.merge [--db name] [-p|--port value] [-x|--delete] <id> <target>
+
+Description
+
+Tx.merge merges a taxon into a target taxon, for example, to remove a
+duplicate found with 'jdh tx.dup'. The specimens, the rasterized
+distributions, the tree terminals, and the extern identifiers of the taxon
+are moved to the target taxon, and then, the taxon is set as a synonym of
+the target (its descendants, including synonyms, are moved to the target).
+If the option -x, --delete is defined, then the taxon will be deleted.
+
+Extern identifiers of a service already defined in the target are kept by
+the merged taxon (and deleted if the taxon is deleted). If a tree has both
+taxons as terminals, the terminal of the merged taxon will be left without
+a taxon.
+
+All the changes are done in a single transaction, so if an error happens, no
+data will be modified.
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    -x
+    --delete
+      If set, the merged taxon will be deleted, instead of being set as a
+      synonym of the target.
+
+    <id>
+      Id of the taxon to be merged.
+
+    <target>
+      Id of the target taxon.
+
 Sets a taxon value
 
 Synopsis
 
-    jdh tx.set [-i|--id value] [-p|--port value] [<name> [<parentname>]]
-	[<key=value>...]
+    jdh tx.set [--db name] [-i|--id value] [-p|--port value]
+	[<name> [<parentname>]] [<key=value>...]
 
 Description
 
 Tx.set sets a particular value for a taxon in the database. Use this
 command to edit the taxon database, instead of manual edition.
 
+Changes of the parent, or the validity, of the taxon (i.e. nomenclatural
+acts) are stored in the history of the taxon, that can be printed with
+'jdh tx.info'.
+
 If no key is defined, the key values will be read from the standard input,
 it is assumed that each line is in the form:
      'key=value'
@@ -1479,6 +2316,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Indicate the taxon to be set.
@@ -1511,6 +2352,10 @@ Options
                              genus
                              species
 
+          reference      Bibliographic reference of the change of the
+                         parent, or the validity, of the taxon. It is
+                         stored in the history of the taxon (see
+                         'jdh tx.info'), and not in the taxon.
           synonym        Set the taxon as synonym. If no id of a new parent
                          is defined, the taxon will be synonymized with its
                          current parent.
@@ -1531,7 +2376,7 @@ Updates local database using an extern database
 
 Synopsis
 
-    jdh tx.sync -e|--extdb name [-d|--validate] [-i|--id value]
+    jdh tx.sync -e|--extdb name [-d|--validate] [--db name] [-i|--id value]
 	[-l|--populate name] [-m|--match] [-p|--port value] [-r|--rank name]
 	[-u|--update] [-v|--verbose] [<name> [<parentname>]]
 
@@ -1555,6 +2400,10 @@ Options
       If set, and -u, --update option is defined, then the validity of the
       taxon will be set as in the extern database.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database.
@@ -1573,12 +2422,13 @@ Options
       If set, then the taxons below the indicated rank will be populated
       with all descendants (valid and invalid) from the extern database.
       Valid values are:
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -m
     --match
@@ -1597,12 +2447,13 @@ Options
       indicates the maximum rank to search, for example '--rank=class' only
       assign taxons up to class rank.
       Valid values are:
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -u
     --update
@@ -1625,8 +2476,9 @@ Prints taxonomy
 
 Synopsis
 
-    jdh tx.taxo [-e|--extdb name] [-f|--format name]	[-i|--id value]
-	[-p|--port value] [-s|--simple]	[<name> [<parentname>]]
+    jdh tx.taxo [--db name] [-e|--extdb name]
+	[-f|--format name]	[-i|--id value] [-p|--port value]
+	[-s|--simple]	[<name> [<parentname>]]
 
 Description
 
@@ -1635,6 +2487,10 @@ taxonomic catalog.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
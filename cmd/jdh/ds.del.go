@@ -14,7 +14,7 @@ import (
 
 var dsDel = &cmdapp.Command{
 	Name:     "ds.del",
-	Synopsis: `-i|--id value [-p|--port value]`,
+	Synopsis: `-i|--id value [--db name] [-p|--port value]`,
 	Short:    "deletes a dataset",
 	Long: `
 Description
@@ -24,6 +24,10 @@ database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated dataset id. It is a required option.
@@ -36,6 +40,7 @@ Options
 }
 
 func init() {
+	dsDel.Flag.StringVar(&dbFlag, "db", "", "")
 	dsDel.Flag.StringVar(&idFlag, "id", "", "")
 	dsDel.Flag.StringVar(&idFlag, "i", "", "")
 	dsDel.Flag.StringVar(&portFlag, "port", "", "")
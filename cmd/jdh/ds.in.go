@@ -16,8 +16,8 @@ import (
 
 var dsIn = &cmdapp.Command{
 	Name: "ds.in",
-	Synopsis: `[-f|--format value] [-p|--port value] [-v|--verbose]
-	[<file>...]`,
+	Synopsis: `[--db name] [-f|--format value] [-p|--port value]
+	[-v|--verbose] [<file>...]`,
 	Short: "imports dataset data",
 	Long: `
 Description
@@ -30,6 +30,10 @@ line corresponds to a dataset (lines starting with '#' or ';' will be ignored).
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -f value
     --format value
       Sets the format used in the source data. Valid values are:
@@ -52,6 +56,7 @@ Options
 }
 
 func init() {
+	dsIn.Flag.StringVar(&dbFlag, "db", "", "")
 	dsIn.Flag.StringVar(&formatFlag, "format", "", "")
 	dsIn.Flag.StringVar(&formatFlag, "f", "", "")
 	dsIn.Flag.StringVar(&portFlag, "port", "", "")
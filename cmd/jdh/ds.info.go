@@ -14,7 +14,7 @@ import (
 
 var dsInfo = &cmdapp.Command{
 	Name: "ds.info",
-	Synopsis: `-i|--id value [-e|--extdb name] [-k|--key value]
+	Synopsis: `-i|--id value [--db name] [-e|--extdb name] [-k|--key value]
 	[-m|--machine] [-p|--port value]`,
 	Short: "prints dataset information",
 	Long: `
@@ -24,6 +24,10 @@ Ds.info prints general information of a dataset in the database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -59,6 +63,7 @@ Options
 }
 
 func init() {
+	dsInfo.Flag.StringVar(&dbFlag, "db", "", "")
 	dsInfo.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	dsInfo.Flag.StringVar(&extDBFlag, "e", "", "")
 	dsInfo.Flag.StringVar(&idFlag, "id", "", "")
@@ -15,7 +15,7 @@ import (
 
 var dsLs = &cmdapp.Command{
 	Name: "ds.ls",
-	Synopsis: `[-c|--citation] [-e|--extdb name] [-l|--license]
+	Synopsis: `[-c|--citation] [--db name] [-e|--extdb name] [-l|--license]
 	[-m|--machine] [-p|--port value] [-u|--url] [-v|--verbose]`,
 	Short: "prints a list of datasets",
 	Long: `
@@ -30,6 +30,10 @@ Options
     --citation
       If set, citation information will be printed.
       
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -64,6 +68,7 @@ Options
 func init() {
 	dsLs.Flag.BoolVar(&citFlag, "citation", false, "")
 	dsLs.Flag.BoolVar(&citFlag, "c", false, "")
+	dsLs.Flag.StringVar(&dbFlag, "db", "", "")
 	dsLs.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	dsLs.Flag.StringVar(&extDBFlag, "e", "", "")
 	dsLs.Flag.BoolVar(&licFlag, "license", false, "")
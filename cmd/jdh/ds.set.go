@@ -14,7 +14,7 @@ import (
 
 var dsSet = &cmdapp.Command{
 	Name:     "ds.set",
-	Synopsis: `-i|--id value [-p|--port value] [<key=value>...]`,
+	Synopsis: `-i|--id value [--db name] [-p|--port value] [<key=value>...]`,
 	Short:    "sets a dataset value",
 	Long: `
 Description
@@ -29,6 +29,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Indicate the dataset to be set. It is a required option.
@@ -55,6 +59,7 @@ Options
 }
 
 func init() {
+	dsSet.Flag.StringVar(&dbFlag, "db", "", "")
 	dsSet.Flag.StringVar(&idFlag, "id", "", "")
 	dsSet.Flag.StringVar(&idFlag, "i", "", "")
 	dsSet.Flag.StringVar(&portFlag, "port", "", "")
@@ -6,9 +6,17 @@ package main
 
 // connection flags
 var (
-	dirFlag  string // set db directory, -d|--dir
-	portFlag string // set connection port, -p|--port
-	commFlag bool   // commit flag, -c|--commit
+	autoFlag    string // set the auto-commit interval, --autocommit
+	certFlag    string // set a certificate file, --cert
+	dbFlag      string // set a database of the server, --db
+	dirFlag     string // set db directory, -d|--dir
+	httpFlag    string // set the http address, --http
+	keyFileFlag string // set a key file, --key
+	logFlag     string // set the log file, --log
+	logSizeFlag int    // set the size of the log, --logsize
+	portFlag    string // set connection port, -p|--port
+	rootFlag    string // set the root of attached databases, --root
+	commFlag    bool   // commit flag, -c|--commit
 )
 
 // common flags
@@ -19,11 +27,25 @@ var (
 	keyFlag     string // key flag -k|--key
 	machineFlag bool   // set machine output, -m|--machine
 	matchFlag   bool   // set match option, -m|--match
+	numFlag     int    // set a number, -n|--number
 	updateFlag  bool   // set update option, -u|--update
 	validFlag   bool   // validate flag, -d|--validate
 	verboseFlag bool   // set command verbosity, -v|--verbose
 )
 
+// flags used by database commands.
+var (
+	baseFlag   string // set a base database, -b|--base
+	outFlag    string // set an output database, -o|--output
+	repairFlag bool   // repair flag, -r|--repair
+)
+
+// flags used by user commands.
+var (
+	delFlag  bool   // delete flag, -x|--delete
+	roleFlag string // set a role, -r|--role
+)
+
 // flags used by dataset commands.
 var (
 	citFlag bool // set citation option, -c|--citation
@@ -36,6 +58,7 @@ var (
 	ancFlag     string // set a parent id, -a|--anc
 	ancsFlag    bool   // ancs flag, -a|--ancs
 	colpFlag    bool   // set collapse option -c|--collapse
+	newFlag     bool   // add new taxons, -n|--new
 	popFlag     string // populate flag, -l|--populate
 	rankFlag    string // set a rank, -r|--rank
 	synonymFlag bool   // synonym flag, -s|--synonym
@@ -0,0 +1,97 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+var jdhHistory = &cmdapp.Command{
+	Name:     "history",
+	Synopsis: `[--db name] [-n|--number value] [-p|--port value]`,
+	Short:    "prints the recent changes of the database",
+	IsCommon: true,
+	Long: `
+Description
+
+History prints the most recent changes made in the database, from the oldest
+to the newest. Each change is printed with its id, its date, and the queries
+made by the change. Changes made with a transaction (for example, tx.force)
+include all the queries of the transaction.
+
+The id of the change can be used with the undo command, to revert the change.
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -n value
+    --number value
+      Sets the number of changes to be printed. By default the last 10
+      changes are printed. If the value is 0, all the changes stored in the
+      database will be printed.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+	`,
+}
+
+func init() {
+	jdhHistory.Flag.StringVar(&dbFlag, "db", "", "")
+	jdhHistory.Flag.IntVar(&numFlag, "number", 10, "")
+	jdhHistory.Flag.IntVar(&numFlag, "n", 10, "")
+	jdhHistory.Flag.StringVar(&portFlag, "port", "", "")
+	jdhHistory.Flag.StringVar(&portFlag, "p", "", "")
+	jdhHistory.Run = historyRun
+}
+
+func historyRun(c *cmdapp.Command, args []string) {
+	openLocal(c)
+	l, err := localDB.List(jdh.History, new(jdh.Values))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	chLs := list.New()
+	for {
+		ch := &jdh.Change{}
+		if err := l.Scan(ch); err != nil {
+			if err == io.EOF {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			os.Exit(1)
+		}
+		chLs.PushBack(ch)
+		if (numFlag > 0) && (chLs.Len() > numFlag) {
+			chLs.Remove(chLs.Front())
+		}
+	}
+	for e := chLs.Front(); e != nil; e = e.Next() {
+		ch := e.Value.(*jdh.Change)
+		fmt.Fprintf(os.Stdout, "%s\t%s", ch.Id, ch.Date.Format("2006-01-02 15:04:05"))
+		for i, q := range ch.Queries {
+			if i > 0 {
+				fmt.Fprintf(os.Stdout, "\t")
+			}
+			fmt.Fprintf(os.Stdout, "\t%s %s", q.Query, q.Table)
+			for _, kv := range q.Kvs {
+				fmt.Fprintf(os.Stdout, " %s=%s", kv.Key, strings.Join(kv.Value, ","))
+			}
+			fmt.Fprintf(os.Stdout, "\n")
+		}
+	}
+}
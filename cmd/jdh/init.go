@@ -5,16 +5,22 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/js-arias/cmdapp"
 	"github.com/js-arias/jdh/pkg/server"
 )
 
 var jdhInit = &cmdapp.Command{
-	Name:     "init",
-	Synopsis: `[-d|--dir path] [-p|--port value]`,
+	Name: "init",
+	Synopsis: `[--autocommit value] [--cert file] [-c|--commit]
+	[-d|--dir path] [--http value] [--key file] [--log file]
+	[--logsize value] [-p|--port value] [--root path]`,
 	Short:    "initializes the jdh server",
 	IsCommon: true,
 	Long: `
@@ -28,30 +34,186 @@ By default, the server will be open in the current directory and at the
 port :16917, this values can be changed by -d, --dir and -p, --port options
 respectively.
 
+Modifications to the database that are not yet committed are stored in the
+'journal' file of the database directory. If the server is stopped before a
+commit, the journal will be applied the next time the server is started.
+When the database is committed, the previous version of each modified file
+is kept with the '.bak' extension. The records of the database files are
+stored one per line, in a stable order (taxa are stored after its parent,
+sorted by name, and other records are sorted by id), so the files can be
+kept in a version control system, such as git.
+
+The server is stopped with the close command, or with an interrupt or
+terminate signal (e.g. Ctrl-C). When stopped, the server stops accepting
+connections, and finishes the requests in progress. If the -c, --commit
+option is set, the database will be committed before the server exits. The
+database can also be committed periodically with the --autocommit option.
+
+Other databases can be attached to the server, while it is running, with
+the db.attach command. Each attached database is identified by a name, that
+is used with the --db option of the other commands, for example:
+
+    jdh tx.ls --db birds
+
+Only databases inside the directory given with the --root option can be
+attached, and only by an editor in the local host. If the option is not
+set, the server does not accept attached databases. The attached databases
+are stored in the 'databases' file of the database directory, and they are
+attached again when the server is restarted.
+
+The version of the format of the database files is stored in the 'meta'
+file of the database directory. If the database uses an outdated format,
+it must be updated with the db.migrate command before it can be served.
+
+The most recent changes of the database are stored in the 'history' file of
+the database directory. They can be reverted with the undo command.
+
+Clients can watch the changes of the database. The server keeps the
+connection of a watch open, and sends the table, id and query of each
+modified element, so, for example, the windows of jdh.gui are updated when
+the database is modified by another client.
+
+Only clients in the local host can modify the database, other clients can
+only read it. Users with editor role, created with the user command, can
+modify the database from any host.
+
+Each request is logged as a JSON object in a single line, with the address
+of the client, the name of its user (if any), the query, the time used to
+answer it, the number of elements returned, and the result or the error of
+the request. The errors of the server (e.g. a failed automatic commit) are
+logged in the same way, with only the time and the error. By default the
+log is written to the standard output, but it can be written into a file
+with the --log option. When the file reaches its maximum size, it is
+renamed with the extension ".1" (previous files are renamed ".2", ".3", and
+so on, up to ".5"), and a new file is started.
+
+If a certificate and its key are given with the --cert and --key options,
+the server will only accept TLS connections. Clients must use a port value
+of the form "tls://host:port", and if the certificate is not signed by a
+known certificate authority, the file with the certificate of the authority
+must be given, for example:
+
+    jdh tx.ls -p "tls://myserver:16917?ca=myca.pem"
+
+If the --http option is given, the server will also answer HTTP requests at
+the given address, so the database can be used by programs that do not use
+the jdh driver. The tables of the database are mapped into paths, and the
+queries into methods, for example:
+
+    GET    /taxonomy/<id>         retrieves a taxon
+    GET    /specimens?taxon=<id>  lists the specimens of a taxon
+    POST   /taxonomy              adds the taxon in the body
+    PATCH  /taxonomy/<id>         sets the values of the object in the body
+    DELETE /taxonomy/<id>         deletes a taxon
+    POST   /commit                commits the database
+
+Elements are encoded in JSON. An attached database can be used with the
+"db" parameter. The token of a user can be given in the "token" parameter,
+or in an "Authorization: Bearer <token>" header. Unlike the jdh driver, the
+HTTP interface requires the token of an editor to modify the database, even
+from the local host. If a certificate is given, the HTTP interface will
+only accept HTTPS connections.
+
 Options
 
+    --autocommit value
+      Sets the interval between automatic commits of the database, for
+      example "10m" for a commit every ten minutes. Valid time units are
+      "s", "m" and "h".
+
+    --cert file
+      Sets the file with the certificate of the server, in PEM format.
+
+    -c
+    --commit
+      If set, the database, and the attached databases, will be committed
+      when the server is stopped.
+
     -d path
     --dir path
       Sets the directory in which the database files will be located. By
       default, the current directory is used as the directory.
+
+    --http value
+      Sets the address in which the HTTP interface will be listening, for
+      example ":8080".
+
+    --key file
+      Sets the file with the private key of the certificate, in PEM format.
+
+    --log file
+      Sets the file in which the requests will be logged.
+
+    --logsize value
+      Sets the maximum size, in megabytes, of the log file. By default the
+      value is 10.
     
     -p value
     --port value
       Sets the port in which the server will be listening. By default the
       value is ":16917"
+
+    --root path
+      Sets the directory of the databases that can be attached to the
+      server. By default, no database can be attached.
 	`,
 }
 
 func init() {
+	jdhInit.Flag.StringVar(&autoFlag, "autocommit", "", "")
+	jdhInit.Flag.StringVar(&certFlag, "cert", "", "")
+	jdhInit.Flag.BoolVar(&commFlag, "commit", false, "")
+	jdhInit.Flag.BoolVar(&commFlag, "c", false, "")
 	jdhInit.Flag.StringVar(&dirFlag, "dir", "", "")
 	jdhInit.Flag.StringVar(&dirFlag, "d", "", "")
+	jdhInit.Flag.StringVar(&httpFlag, "http", "", "")
+	jdhInit.Flag.StringVar(&keyFileFlag, "key", "", "")
+	jdhInit.Flag.StringVar(&logFlag, "log", "", "")
+	jdhInit.Flag.IntVar(&logSizeFlag, "logsize", 0, "")
 	jdhInit.Flag.StringVar(&portFlag, "port", "", "")
 	jdhInit.Flag.StringVar(&portFlag, "p", "", "")
+	jdhInit.Flag.StringVar(&rootFlag, "root", "", "")
 	jdhInit.Run = initRun
 }
 
 func initRun(c *cmdapp.Command, args []string) {
-	if err := server.Listen(portFlag, dirFlag); err != nil {
+	stop := make(chan struct{})
+	cfg := &server.Config{
+		Port:   portFlag,
+		Path:   dirFlag,
+		Cert:   certFlag,
+		Key:    keyFileFlag,
+		HTTP:   httpFlag,
+		Stop:   stop,
+		Commit: commFlag,
+		Log:    logFlag,
+		Root:   rootFlag,
+	}
+	if logSizeFlag < 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(errors.New("invalid log size")))
+		os.Exit(1)
+	}
+	cfg.LogSize = int64(logSizeFlag) << 20
+	if len(autoFlag) > 0 {
+		d, err := time.ParseDuration(autoFlag)
+		if (err == nil) && (d <= 0) {
+			err = errors.New("invalid auto-commit interval: " + autoFlag)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			os.Exit(1)
+		}
+		cfg.AutoCommit = d
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		close(stop)
+		// a second signal kills the server.
+		signal.Stop(sig)
+	}()
+	if err := server.Serve(cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
 		os.Exit(1)
 	}
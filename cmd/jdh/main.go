@@ -24,6 +24,18 @@ software for management of taxonomic and biogeographic data.
 Use 'jdh help --all' for a list of available commands. To see help or 
 information about a command type 'jdh help <command>'.
 
+Most commands use the database served by 'jdh init'. A database can also be
+used directly from its directory, without a server, using a port value of
+the form "local:<path>", for example:
+
+    jdh tx.ls -p local:/path/to/db
+
+The same form can be used with the -e, --extdb option, to use a database
+in another directory as the extern database, except in the commands that
+store the ids of the extern database as extern ids (ra.mk, sp.pop and
+tx.sync). A database used directly can not be served, or used by another
+command, at the same time.
+
 Author
 
 J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
@@ -38,6 +50,17 @@ Please report any bug to J.S. Arias at <jsalarias@csnat.unt.edu.ar>.
 	Commands: []*cmdapp.Command{
 		jdhInit,
 		jdhClose,
+		jdhStatus,
+		jdhHistory,
+		jdhUndo,
+		jdhUser,
+		dbAttach,
+		dbCheck,
+		dbDetach,
+		dbDiff,
+		dbLs,
+		dbMerge,
+		dbMigrate,
 		dsDel,
 		dsIn,
 		dsInfo,
@@ -62,10 +85,12 @@ Please report any bug to J.S. Arias at <jsalarias@csnat.unt.edu.ar>.
 		trLs,
 		trSet,
 		txDel,
+		txDup,
 		txForce,
 		txIn,
 		txInfo,
 		txLs,
+		txMerge,
 		txSet,
 		txSync,
 		txTaxo,
@@ -14,8 +14,8 @@ import (
 
 var raDel = &cmdapp.Command{
 	Name: "ra.del",
-	Synopsis: `[-i|--id value] [-p|--port value] [-t|--taxon value]
-	[<name> [<parentname>]]`,
+	Synopsis: `[--db name] [-i|--id value] [-p|--port value]
+	[-t|--taxon value] [<name> [<parentname>]]`,
 	Short: "deletes rasterized distributions",
 	Long: `
 Description
@@ -28,6 +28,10 @@ that operations.
 
 Operations
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated raster id.
@@ -55,6 +59,7 @@ Operations
 }
 
 func init() {
+	raDel.Flag.StringVar(&dbFlag, "db", "", "")
 	raDel.Flag.StringVar(&idFlag, "id", "", "")
 	raDel.Flag.StringVar(&idFlag, "i", "", "")
 	raDel.Flag.StringVar(&portFlag, "port", "", "")
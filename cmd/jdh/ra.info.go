@@ -14,7 +14,7 @@ import (
 
 var raInfo = &cmdapp.Command{
 	Name: "ra.info",
-	Synopsis: `-i|--id value [-k|--key value] [-m|--machine]
+	Synopsis: `-i|--id value [--db name] [-k|--key value] [-m|--machine]
 	[-p|--port value]`,
 	Short:    "prints information about a rasterized distribution",
 	IsCommon: true,
@@ -26,6 +26,10 @@ database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated rasterized distribution id.
@@ -58,6 +62,7 @@ Options
 }
 
 func init() {
+	raInfo.Flag.StringVar(&dbFlag, "db", "", "")
 	raInfo.Flag.StringVar(&idFlag, "id", "", "")
 	raInfo.Flag.StringVar(&idFlag, "i", "", "")
 	raInfo.Flag.StringVar(&keyFlag, "key", "", "")
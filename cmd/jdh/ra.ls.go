@@ -15,7 +15,7 @@ import (
 
 var raLs = &cmdapp.Command{
 	Name: "ra.ls",
-	Synopsis: `[-c|--children] [-m|--machine] [-p|--port value] 
+	Synopsis: `[-c|--children] [--db name] [-m|--machine] [-p|--port value]
 	[-t|--taxon value] [-v|--verbose] [<name> [<parentname>]]`,
 	Short:    "prints a list of rasterized distributions",
 	IsCommon: true,
@@ -31,6 +31,10 @@ Options
       If set, the rastes associated with the indicated taxon, as well
       as the ones from its descendants, will be printed.
     
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -m
     --machine
       If set, the output will be machine readable. That is, just ids will
@@ -66,6 +70,7 @@ Options
 func init() {
 	raLs.Flag.BoolVar(&childFlag, "children", false, "")
 	raLs.Flag.BoolVar(&childFlag, "c", false, "")
+	raLs.Flag.StringVar(&dbFlag, "db", "", "")
 	raLs.Flag.BoolVar(&machineFlag, "machine", false, "")
 	raLs.Flag.BoolVar(&machineFlag, "m", false, "")
 	raLs.Flag.StringVar(&portFlag, "port", "", "")
@@ -18,8 +18,8 @@ import (
 
 var raMk = &cmdapp.Command{
 	Name: "ra.mk",
-	Synopsis: `[-e|--extdb name] [-p|--port value] [-r|--rank name]
-	[-s|--size value] [-t|--taxon value] [-d|--validated]
+	Synopsis: `[--db name] [-e|--extdb name] [-p|--port value]
+	[-r|--rank name] [-s|--size value] [-t|--taxon value] [-d|--validated]
 	[<name> [<parentname>]]`,
 	Short:    "creates raster distributions from specimen data",
 	IsCommon: true,
@@ -39,6 +39,10 @@ rank will be rasterized.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Sets the a extern database to extract distribution data.
@@ -57,12 +61,13 @@ Options
     --rank name
       If set, only taxons below the indicated rank will be populated.
       Valid values are:
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -s value
     --size value
@@ -91,6 +96,7 @@ Options
 func init() {
 	raMk.Flag.BoolVar(&validFlag, "validate", false, "")
 	raMk.Flag.BoolVar(&validFlag, "d", false, "")
+	raMk.Flag.StringVar(&dbFlag, "db", "", "")
 	raMk.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	raMk.Flag.StringVar(&extDBFlag, "e", "", "")
 	raMk.Flag.StringVar(&portFlag, "port", "", "")
@@ -108,7 +114,7 @@ func raMkRun(c *cmdapp.Command, args []string) {
 	openLocal(c)
 	var spDB jdh.DB
 	if len(extDBFlag) > 0 {
-		openExt(c, extDBFlag, "")
+		openExtServ(c)
 		spDB = extDB
 	} else {
 		spDB = localDB
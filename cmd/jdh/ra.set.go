@@ -14,7 +14,7 @@ import (
 
 var raSet = &cmdapp.Command{
 	Name:     "ra.set",
-	Synopsis: `-i|--id value [-p|--port value] [<key=value>...]`,
+	Synopsis: `-i|--id value [--db name] [-p|--port value] [<key=value>...]`,
 	Short:    "sets a value in a rasterized distribution",
 	Long: `
 Description
@@ -30,6 +30,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Indicate the raster to be set. It is a required option.
@@ -57,6 +61,7 @@ Options
 }
 
 func init() {
+	raSet.Flag.StringVar(&dbFlag, "db", "", "")
 	raSet.Flag.StringVar(&idFlag, "id", "", "")
 	raSet.Flag.StringVar(&idFlag, "i", "", "")
 	raSet.Flag.StringVar(&portFlag, "port", "", "")
@@ -14,8 +14,8 @@ import (
 
 var spDel = &cmdapp.Command{
 	Name: "sp.del",
-	Synopsis: `[-i|--id value] [-p|--port value] [-t|--taxon value]
-	[<name> [<parentname>]]`,
+	Synopsis: `[--db name] [-i|--id value] [-p|--port value]
+	[-t|--taxon value] [<name> [<parentname>]]`,
 	Short: "deletes specimens",
 	Long: `
 Description
@@ -27,6 +27,10 @@ delete a taxon use the command tx.del.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated specimen id.
@@ -54,6 +58,7 @@ Options
 }
 
 func init() {
+	spDel.Flag.StringVar(&dbFlag, "db", "", "")
 	spDel.Flag.StringVar(&idFlag, "id", "", "")
 	spDel.Flag.StringVar(&idFlag, "i", "", "")
 	spDel.Flag.StringVar(&portFlag, "port", "", "")
@@ -18,7 +18,7 @@ import (
 
 var spGref = &cmdapp.Command{
 	Name: "sp.gref",
-	Synopsis: `[-a|--add] [-c|--correct] [-p|--port value]
+	Synopsis: `[-a|--add] [-c|--correct] [--db name] [-p|--port value]
 	[-t|--taxon value] [-u|--uncert value] [-v|--verbose]
 	[<name> [<parentname>]]`,
 	Short: "validate and add specimen georeferences",
@@ -53,6 +53,10 @@ Options
       If set, it will try to correct invalid georeferences. It will try it
       by flipping lon, lat values, and changing lon, lat values sings.
     
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -p value
     --port value
       Sets the port in which the server will be listening. By default the
@@ -93,6 +97,7 @@ func init() {
 	spGref.Flag.BoolVar(&addFlag, "a", false, "")
 	spGref.Flag.BoolVar(&corrFlag, "correct", false, "")
 	spGref.Flag.BoolVar(&corrFlag, "c", false, "")
+	spGref.Flag.StringVar(&dbFlag, "db", "", "")
 	spGref.Flag.StringVar(&portFlag, "port", "", "")
 	spGref.Flag.StringVar(&portFlag, "p", "", "")
 	spGref.Flag.StringVar(&taxonFlag, "taxon", "", "")
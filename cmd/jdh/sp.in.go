@@ -20,9 +20,9 @@ import (
 
 var spIn = &cmdapp.Command{
 	Name: "sp.in",
-	Synopsis: `[-a|--anc value] [-d|--dataset value] [-f|--format value]
-	[-p|--port value] [-r|--rank name] [-s|--skip] [-v|--verbose]
-	[<file>...]`,
+	Synopsis: `[-a|--anc value] [-d|--dataset value] [--db name]
+	[-f|--format value] [-n|--new] [-p|--port value] [-r|--rank name]
+	[-s|--skip] [-v|--verbose] [<file>...]`,
 	Short: "imports specimen data",
 	Long: `
 Description
@@ -36,6 +36,10 @@ line corresponds to a specimen (lines starting with '#' or ';' will be ignored).
 If the taxons in the input file are not in the database, they will be
 added to the root, valid, and unranked. Use options -a, --anc, -r, --rank
 to change this behavior.
+In ndm format, the authority is taken from the name of the taxon. If the
+rank is species, or below species, the genus (and the species of an
+infraspecific taxon) is also taken from the name, and added if it is not
+in the database.
 
 Options
 
@@ -48,6 +52,10 @@ Options
       If defined, new specimens will be set to the indicated dataset. If 
       the value is not a valid id, then this option will be ignored.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -f value
     --format value
       Sets the format used in the source data. Valid values are:
@@ -59,18 +67,28 @@ Options
       If set, taxons in the database will be ignored. Useful to add a
       dataset after correcting warnings.
 
+    -n
+    --new
+      If set, taxons with names spelled as the names of taxons already in
+      the database, except for the case, or the hyphens, will be added. By
+      default, these taxons are not added, and the names are reported.
+      Taxons with other similar names (for example, a misspelling of a
+      name, or a congener with a similar epithet) are always added, and
+      the similar names are reported.
+
     -r name
     --rank name
       Set the rank of the added taxon. If the taxon has a parent (the -a,
       --anc options) the parent must be concordant with the given rank.
       Valid values are:
-      	  unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          unranked
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -p value
     --port value
@@ -93,8 +111,11 @@ func init() {
 	spIn.Flag.StringVar(&ancFlag, "a", "", "")
 	spIn.Flag.StringVar(&dsetFlag, "dataset", "", "")
 	spIn.Flag.StringVar(&dsetFlag, "d", "", "")
+	spIn.Flag.StringVar(&dbFlag, "db", "", "")
 	spIn.Flag.StringVar(&formatFlag, "format", "", "")
 	spIn.Flag.StringVar(&formatFlag, "f", "", "")
+	spIn.Flag.BoolVar(&newFlag, "new", false, "")
+	spIn.Flag.BoolVar(&newFlag, "n", false, "")
 	spIn.Flag.StringVar(&portFlag, "port", "", "")
 	spIn.Flag.StringVar(&portFlag, "p", "", "")
 	spIn.Flag.StringVar(&rankFlag, "rank", "", "")
@@ -327,43 +348,32 @@ func spInNdm(c *cmdapp.Command, fname, parent string, rank jdh.Rank, set *jdh.Da
 			tok, err = skipNdmTaxon(c, in)
 			continue
 		}
+		n := parseName(txname)
+		txname = n.Canonical()
 		mult, id := spInSearchNmdTaxon(c, txname, parent, txNum, rank)
 		if mult {
 			tok, err = skipNdmTaxon(c, in)
 			continue
 		}
 		if len(id) == 0 {
-			pId := parent
-			// we know that the first name of a species is the genus.
-			if rank == jdh.Species {
-				tn := strings.Fields(txname)
-				if len(tn) > 1 {
-					if p := taxInDB(c, localDB, tn[0], parent, jdh.Genus, true); p != nil {
-						pId = p.Id
-					} else {
-						par := &jdh.Taxon{
-							Name:    tn[0],
-							IsValid: true,
-							Parent:  parent,
-							Rank:    jdh.Genus,
-						}
-						pId, err = localDB.Exec(jdh.Add, jdh.Taxonomy, par)
-						if err != nil {
-							fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-							tok, err = skipNdmTaxon(c, in)
-							continue
-						}
-						if verboseFlag {
-							fmt.Fprintf(os.Stdout, "%s %s\n", pId, par.Name)
-						}
-					}
-				}
+			if err = similarTaxa(c, localDB, txname, parent); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+				tok, err = skipNdmTaxon(c, in)
+				continue
+			}
+			var pId string
+			pId, err = nameParent(c, localDB, n, parent, rank)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+				tok, err = skipNdmTaxon(c, in)
+				continue
 			}
 			tax := &jdh.Taxon{
-				Name:    txname,
-				IsValid: true,
-				Parent:  pId,
-				Rank:    rank,
+				Name:      txname,
+				Authority: n.Authority(),
+				IsValid:   true,
+				Parent:    pId,
+				Rank:      rank,
 			}
 			id, err = localDB.Exec(jdh.Add, jdh.Taxonomy, tax)
 			if err != nil {
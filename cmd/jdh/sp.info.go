@@ -14,7 +14,7 @@ import (
 
 var spInfo = &cmdapp.Command{
 	Name: "sp.info",
-	Synopsis: `-i|--id value [-e|--extdb name] [-k|--key value]
+	Synopsis: `-i|--id value [--db name] [-e|--extdb name] [-k|--key value]
 	[-m|--machine] [-p|--port value]`,
 	Short:    "prints general specimen information",
 	IsCommon: true,
@@ -25,6 +25,10 @@ Sp.info prints general information of an specimen in the database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -78,6 +82,7 @@ Options
 }
 
 func init() {
+	spInfo.Flag.StringVar(&dbFlag, "db", "", "")
 	spInfo.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	spInfo.Flag.StringVar(&extDBFlag, "e", "", "")
 	spInfo.Flag.StringVar(&idFlag, "id", "", "")
@@ -15,7 +15,7 @@ import (
 
 var spLs = &cmdapp.Command{
 	Name: "sp.ls",
-	Synopsis: `[-c|--children] [-e|--extdb name] [-g|--georef]
+	Synopsis: `[-c|--children] [--db name] [-e|--extdb name] [-g|--georef]
 	[-m|--machine] [-n|--nonref] [-p|--port value] [-r|--country name]
 	[-t|--taxon value] [-v|--verbose] [<name> [<parentname>]]`,
 	Short:    "prints a list of specimens",
@@ -35,6 +35,10 @@ Options
       If set, the speciemens associated with the indicated taxon, as well
       as the ones from its descendants, will be printed.
     
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -89,6 +93,7 @@ Options
 func init() {
 	spLs.Flag.BoolVar(&childFlag, "children", false, "")
 	spLs.Flag.BoolVar(&childFlag, "c", false, "")
+	spLs.Flag.StringVar(&dbFlag, "db", "", "")
 	spLs.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	spLs.Flag.StringVar(&extDBFlag, "e", "", "")
 	spLs.Flag.BoolVar(&geoRefFlag, "georef", false, "")
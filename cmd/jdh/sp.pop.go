@@ -16,8 +16,8 @@ import (
 
 var spPop = &cmdapp.Command{
 	Name: "sp.pop",
-	Synopsis: `-e|--extdb name [-p|--port value] [-r|--rank name]
-	[-t|--taxon value] [<name> [<parentname>]]`,
+	Synopsis: `-e|--extdb name [--db name] [-p|--port value]
+	[-r|--rank name] [-t|--taxon value] [<name> [<parentname>]]`,
 	Short:    "add specimens from an extern database",
 	IsCommon: true,
 	Long: `
@@ -33,6 +33,10 @@ rank will be populated.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Sets the extern database.
@@ -54,12 +58,13 @@ Options
     --rank name
       If set, only taxons below the indicated rank will be populated.
       Valid values are:
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -t value
     --taxon value
@@ -79,6 +84,7 @@ Options
 }
 
 func init() {
+	spPop.Flag.StringVar(&dbFlag, "db", "", "")
 	spPop.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	spPop.Flag.StringVar(&extDBFlag, "e", "", "")
 	spPop.Flag.BoolVar(&geoRefFlag, "georef", false, "")
@@ -98,7 +104,7 @@ func spPopRun(c *cmdapp.Command, args []string) {
 		c.Usage()
 	}
 	openLocal(c)
-	openExt(c, extDBFlag, "")
+	openExtServ(c)
 	var tax *jdh.Taxon
 	if len(taxonFlag) > 0 {
 		tax = taxon(c, localDB, taxonFlag)
@@ -14,7 +14,7 @@ import (
 
 var spSet = &cmdapp.Command{
 	Name:     "sp.set",
-	Synopsis: `-i|--id value [-p|--port value] [<key=value>...]`,
+	Synopsis: `-i|--id value [--db name] [-p|--port value] [<key=value>...]`,
 	Short:    "sets an specimen value",
 	Long: `
 Description
@@ -29,6 +29,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Indicate the specimen to be set. It is a required option.
@@ -72,6 +76,7 @@ Options
 }
 
 func init() {
+	spSet.Flag.StringVar(&dbFlag, "db", "", "")
 	spSet.Flag.StringVar(&idFlag, "id", "", "")
 	spSet.Flag.StringVar(&idFlag, "i", "", "")
 	spSet.Flag.StringVar(&portFlag, "port", "", "")
@@ -0,0 +1,111 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+var jdhStatus = &cmdapp.Command{
+	Name:     "status",
+	Synopsis: `[--db name] [-m|--machine] [-p|--port value]`,
+	Short:    "prints the status of the server",
+	IsCommon: true,
+	Long: `
+Description
+
+Status prints the status of the jdh database server: the time the server
+has been running, the time of the last commit, the number of elements of
+each table, and the number of requests answered by the server. Tables with
+changes that are not yet committed are marked with an asterisk.
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -m
+    --machine
+      If set, the output will be machine readable. That is, just key=value
+      pairs will be printed.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+	`,
+}
+
+func init() {
+	jdhStatus.Flag.StringVar(&dbFlag, "db", "", "")
+	jdhStatus.Flag.BoolVar(&machineFlag, "machine", false, "")
+	jdhStatus.Flag.BoolVar(&machineFlag, "m", false, "")
+	jdhStatus.Flag.StringVar(&portFlag, "port", "", "")
+	jdhStatus.Flag.StringVar(&portFlag, "p", "", "")
+	jdhStatus.Run = statusRun
+}
+
+func statusRun(c *cmdapp.Command, args []string) {
+	openLocal(c)
+	sc, err := localDB.Get(jdh.Stats, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	st := &jdh.Status{}
+	if err := sc.Scan(st); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	var qs []string
+	for q := range st.Requests {
+		qs = append(qs, string(q))
+	}
+	sort.Strings(qs)
+	if machineFlag {
+		fmt.Fprintf(os.Stdout, "start=%s\n", st.Start.Format(time.RFC3339))
+		if !st.Commit.IsZero() {
+			fmt.Fprintf(os.Stdout, "commit=%s\n", st.Commit.Format(time.RFC3339))
+		}
+		for _, t := range st.Tables {
+			fmt.Fprintf(os.Stdout, "%s=%d\n", t.Table, t.Count)
+			fmt.Fprintf(os.Stdout, "%s.changed=%v\n", t.Table, t.Changed)
+		}
+		fmt.Fprintf(os.Stdout, "transactions=%d\n", st.Txs)
+		for _, q := range qs {
+			fmt.Fprintf(os.Stdout, "requests.%s=%d\n", q, st.Requests[jdh.Query(q)])
+		}
+		fmt.Fprintf(os.Stdout, "errors=%d\n", st.Errors)
+		return
+	}
+	up := time.Since(st.Start)
+	fmt.Fprintf(os.Stdout, "%-16s %s (up %s)\n", "Started:", st.Start.Format("2006-01-02 15:04:05"), up-up%time.Second)
+	if !st.Commit.IsZero() {
+		fmt.Fprintf(os.Stdout, "%-16s %s\n", "Last commit:", st.Commit.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(os.Stdout, "Tables:\n")
+	for _, t := range st.Tables {
+		mark := ""
+		if t.Changed {
+			mark = " *"
+		}
+		fmt.Fprintf(os.Stdout, "\t%-12s %8d%s\n", t.Table, t.Count, mark)
+	}
+	if st.Txs > 0 {
+		fmt.Fprintf(os.Stdout, "%-16s %d\n", "Transactions:", st.Txs)
+	}
+	fmt.Fprintf(os.Stdout, "Requests:\n")
+	for _, q := range qs {
+		fmt.Fprintf(os.Stdout, "\t%-12s %8d\n", q, st.Requests[jdh.Query(q)])
+	}
+	fmt.Fprintf(os.Stdout, "\t%-12s %8d\n", "errors", st.Errors)
+}
@@ -8,9 +8,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/js-arias/cmdapp"
 	"github.com/js-arias/jdh/pkg/jdh"
+	"github.com/js-arias/jdh/pkg/names"
 )
 
 // Taxon gets a taxon.
@@ -62,6 +64,125 @@ func taxInDB(c *cmdapp.Command, db jdh.DB, name, parent string, rank jdh.Rank, v
 	}
 }
 
+// ParseName parses the name of a taxon. If the name is not a valid
+// scientific name (e.g. an informal name, or a name in lower case), the
+// whole name is used as an uninomial, without authority.
+func parseName(nm string) *names.Name {
+	n, err := names.Parse(nm)
+	if err != nil {
+		return &names.Name{Genus: strings.Join(strings.Fields(nm), " ")}
+	}
+	return n
+}
+
+// NameParent returns the id of the parent of a taxon of a given rank,
+// deduced from its parsed name: the genus of a species, or the species of
+// an infraspecific taxon. If the given parent is the genus, or the
+// species, it is used as the parent. Parents not in the database are added
+// as valid taxons.
+func nameParent(c *cmdapp.Command, db jdh.DB, n *names.Name, parent string, rank jdh.Rank) (string, error) {
+	if (rank < jdh.Species) || ((len(n.Specific) == 0) && (len(n.Qualifier) == 0)) {
+		return parent, nil
+	}
+	pName := ""
+	if len(parent) > 0 {
+		pName = taxon(c, db, parent).Name
+	}
+	sp := &names.Name{Genus: n.Genus, Qualifier: n.Qualifier, Specific: n.Specific}
+	infra := (rank > jdh.Species) && (len(n.Infraspecific) > 0)
+	if infra && (pName == sp.Canonical()) {
+		return parent, nil
+	}
+	pId := parent
+	if pName != n.Genus {
+		var err error
+		if pId, err = addNameParent(c, db, n.Genus, parent, jdh.Genus); err != nil {
+			return "", err
+		}
+	}
+	if !infra {
+		return pId, nil
+	}
+	return addNameParent(c, db, sp.Canonical(), pId, jdh.Species)
+}
+
+// AddNameParent returns the id of a valid taxon with the given name and
+// rank, and adds it if the taxon is not in the database.
+func addNameParent(c *cmdapp.Command, db jdh.DB, name, parent string, rank jdh.Rank) (string, error) {
+	if p := taxInDB(c, db, name, parent, rank, true); p != nil {
+		return p.Id, nil
+	}
+	if err := similarTaxa(c, db, name, parent); err != nil {
+		return "", err
+	}
+	tax := &jdh.Taxon{
+		Name:    name,
+		IsValid: true,
+		Parent:  parent,
+		Rank:    rank,
+	}
+	id, err := db.Exec(jdh.Add, jdh.Taxonomy, tax)
+	if err != nil {
+		return "", err
+	}
+	if verboseFlag {
+		fmt.Fprintf(os.Stdout, "%s %s\n", id, tax.Name)
+	}
+	return id, nil
+}
+
+// SimilarTaxa returns an error with the list of taxons with a name that is
+// spelled as a given name, except for the case, or the hyphens. Other
+// similar names (for example, a misspelling, or a congener with a similar
+// epithet) are only reported. It returns nil if there are no names with
+// the same spelling, or the -n, --new option is set.
+func similarTaxa(c *cmdapp.Command, db jdh.DB, name, parent string) error {
+	if newFlag {
+		return nil
+	}
+	args := new(jdh.Values)
+	args.Add(jdh.TaxSimilar, name)
+	if len(parent) != 0 {
+		args.Add(jdh.TaxParent, parent)
+	}
+	l, err := db.List(jdh.Taxonomy, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	defer l.Close()
+	var same, sim []string
+	for {
+		tax := &jdh.Taxon{}
+		if err := l.Scan(tax); err != nil {
+			if err == io.EOF {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			os.Exit(1)
+		}
+		s := fmt.Sprintf("%s %s [id: %s]", tax.Name, tax.Authority, tax.Id)
+		if spelling(tax.Name) == spelling(name) {
+			same = append(same, s)
+			continue
+		}
+		sim = append(sim, s)
+	}
+	if len(same) > 0 {
+		return fmt.Errorf("taxon %s not added, same name in database:\n\t%s", name, strings.Join(same, "\n\t"))
+	}
+	if len(sim) > 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(fmt.Sprintf("taxon %s has similar names in database:\n\t%s", name, strings.Join(sim, "\n\t"))))
+	}
+	return nil
+}
+
+// Spelling returns a name in lower case, and without hyphens, so names
+// with the same spelling are equal.
+func spelling(name string) string {
+	return strings.Replace(strings.Join(strings.Fields(strings.ToLower(name)), " "), "-", "", -1)
+}
+
 // PickTaxName search for a unique taxon name. If there are more taxons
 // fullfilling the name, then it will print a list of the potential
 // names and finish the program.
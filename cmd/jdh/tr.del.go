@@ -14,7 +14,7 @@ import (
 
 var trDel = &cmdapp.Command{
 	Name: "tr.del",
-	Synopsis: `[-c|--collapse] [-i|--id value] [-n|--node value]
+	Synopsis: `[-c|--collapse] [--db name] [-i|--id value] [-n|--node value]
 	[-p|--port value]`,
 	Short: "deletes a tree or a node",
 	Long: `
@@ -33,6 +33,10 @@ Options
       of the node. If the node to be collapsed is the root node, then, the
       collapse will be ignored.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated tree id.
@@ -51,6 +55,7 @@ Options
 func init() {
 	trDel.Flag.BoolVar(&colpFlag, "collapse", false, "")
 	trDel.Flag.BoolVar(&colpFlag, "c", false, "")
+	trDel.Flag.StringVar(&dbFlag, "db", "", "")
 	trDel.Flag.StringVar(&idFlag, "id", "", "")
 	trDel.Flag.StringVar(&idFlag, "i", "", "")
 	trDel.Flag.StringVar(&nodeFlag, "node", "", "")
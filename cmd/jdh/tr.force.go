@@ -16,7 +16,7 @@ import (
 
 var trForce = &cmdapp.Command{
 	Name:     "tr.force",
-	Synopsis: `[-i|--id value] [-p|--port value] [-r|--report]`,
+	Synopsis: `[--db name] [-i|--id value] [-p|--port value] [-r|--report]`,
 	Short:    "enforces valid taxons as tree terminals",
 	Long: `
 Description
@@ -26,11 +26,18 @@ taxons. If the taxon is not presented in the tree, then the invalid terminal
 will be replaced with the valid taxon, if the valid taxon is already in the
 tree, then, the invalid taxon will be deleted.
 
+All the changes are done in a single transaction, so if an error happens, no
+tree will be modified.
+
 If the option -r, --report is set, it only show what terminals should be
 removed without performing any operation.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated tree id.
@@ -48,6 +55,7 @@ Options
 }
 
 func init() {
+	trForce.Flag.StringVar(&dbFlag, "db", "", "")
 	trForce.Flag.StringVar(&idFlag, "id", "", "")
 	trForce.Flag.StringVar(&idFlag, "i", "", "")
 	trForce.Flag.StringVar(&portFlag, "port", "", "")
@@ -64,14 +72,15 @@ func trForceRun(c *cmdapp.Command, args []string) {
 		if len(phy.Id) == 0 {
 			return
 		}
+		beginTx(c)
 		trForceProc(c, phy)
-		localDB.Exec(jdh.Commit, "", nil)
+		commitTx(c)
 		return
 	}
+	beginTx(c)
 	l, err := localDB.List(jdh.Trees, new(jdh.Values))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-		os.Exit(1)
+		abortTx(c, err)
 	}
 	for {
 		phy := &jdh.Phylogeny{}
@@ -79,15 +88,14 @@ func trForceRun(c *cmdapp.Command, args []string) {
 			if err == io.EOF {
 				break
 			}
-			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-			os.Exit(1)
+			abortTx(c, err)
 		}
 		if len(phy.Id) == 0 {
 			continue
 		}
 		trForceProc(c, phy)
 	}
-	localDB.Exec(jdh.Commit, "", nil)
+	commitTx(c)
 }
 
 func trForceProc(c *cmdapp.Command, phy *jdh.Phylogeny) {
@@ -96,8 +104,7 @@ func trForceProc(c *cmdapp.Command, phy *jdh.Phylogeny) {
 	vals.Add(jdh.TreTaxon, phy.Id)
 	l, err := localDB.List(jdh.Trees, vals)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-		os.Exit(1)
+		abortTx(c, err)
 	}
 	for {
 		var tId jdh.IdElement
@@ -105,8 +112,7 @@ func trForceProc(c *cmdapp.Command, phy *jdh.Phylogeny) {
 			if err == io.EOF {
 				break
 			}
-			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-			os.Exit(1)
+			abortTx(c, err)
 		}
 		if len(tId.Id) == 0 {
 			continue
@@ -125,8 +131,7 @@ func trForceNode(c *cmdapp.Command, nod *jdh.Node, txLs *list.List) {
 	vals.Add(jdh.NodChildren, nod.Id)
 	l, err := localDB.List(jdh.Nodes, vals)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-		os.Exit(1)
+		abortTx(c, err)
 	}
 	childs := 0
 	for {
@@ -135,8 +140,7 @@ func trForceNode(c *cmdapp.Command, nod *jdh.Node, txLs *list.List) {
 			if err == io.EOF {
 				break
 			}
-			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-			os.Exit(1)
+			abortTx(c, err)
 		}
 		trForceNode(c, desc, txLs)
 		childs++
@@ -165,7 +169,9 @@ func trForceNode(c *cmdapp.Command, nod *jdh.Node, txLs *list.List) {
 		if !repFlag {
 			vals.Reset()
 			vals.Add(jdh.KeyId, nod.Id)
-			localDB.Exec(jdh.Delete, jdh.Nodes, vals)
+			if _, err := localDB.Exec(jdh.Delete, jdh.Nodes, vals); err != nil {
+				abortTx(c, err)
+			}
 		}
 		return
 	}
@@ -174,7 +180,9 @@ func trForceNode(c *cmdapp.Command, nod *jdh.Node, txLs *list.List) {
 		vals.Reset()
 		vals.Add(jdh.KeyId, nod.Id)
 		vals.Add(jdh.NodTaxon, par.Id)
-		localDB.Exec(jdh.Set, jdh.Nodes, vals)
+		if _, err := localDB.Exec(jdh.Set, jdh.Nodes, vals); err != nil {
+			abortTx(c, err)
+		}
 		txLs.PushBack(par.Id)
 	}
 }
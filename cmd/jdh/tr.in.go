@@ -20,8 +20,8 @@ import (
 
 var trIn = &cmdapp.Command{
 	Name: "tr.in",
-	Synopsis: `[-a|--anc value] [-f|--format value] [-p|--port value]
-	[-r|--rank value] [-v|--verbose] [<file>...]`,
+	Synopsis: `[-a|--anc value] [--db name] [-f|--format value]
+	[-p|--port value] [-r|--rank value] [-v|--verbose] [<file>...]`,
 	Short: "imports tree data",
 	Long: `
 Description
@@ -39,6 +39,10 @@ Options
       Sets the parent of the terminals of the tree. The value must be a valid
       id.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -f value
     --format value
       Sets the format used in the source data. Valid values are:
@@ -54,13 +58,14 @@ Options
       Set the rank of the added taxon. If the taxon has a parent (the -a,
       --anc options) the parent must be concordant with the given rank.
       Valid values are:
-      	  unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          unranked
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -v
     --verbose
@@ -75,6 +80,7 @@ Options
 func init() {
 	trIn.Flag.StringVar(&ancFlag, "anc", "", "")
 	trIn.Flag.StringVar(&ancFlag, "a", "", "")
+	trIn.Flag.StringVar(&dbFlag, "db", "", "")
 	trIn.Flag.StringVar(&formatFlag, "format", "", "")
 	trIn.Flag.StringVar(&formatFlag, "f", "", "")
 	trIn.Flag.StringVar(&portFlag, "port", "", "")
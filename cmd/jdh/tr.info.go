@@ -15,8 +15,8 @@ import (
 
 var trInfo = &cmdapp.Command{
 	Name: "tr.info",
-	Synopsis: `[-i|--id value] [-n|--node value] [-k|--key value]
-	[-m|--machine] [-p|--port value]`,
+	Synopsis: `[--db name] [-i|--id value] [-n|--node value]
+	[-k|--key value] [-m|--machine] [-p|--port value]`,
 	Short: "prints tree or node information",
 	Long: `
 Description
@@ -27,6 +27,10 @@ database.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated tree id.
@@ -63,6 +67,7 @@ Options
 }
 
 func init() {
+	trInfo.Flag.StringVar(&dbFlag, "db", "", "")
 	trInfo.Flag.StringVar(&idFlag, "id", "", "")
 	trInfo.Flag.StringVar(&idFlag, "i", "", "")
 	trInfo.Flag.StringVar(&keyFlag, "key", "", "")
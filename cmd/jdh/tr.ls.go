@@ -15,7 +15,7 @@ import (
 
 var trLs = &cmdapp.Command{
 	Name: "tr.ls",
-	Synopsis: `[-a|--ancs] [-n|--node value] [-m|--machine]
+	Synopsis: `[-a|--ancs] [--db name] [-n|--node value] [-m|--machine]
 	[-p|--port value] [-v|--verbose]`,
 	Short:    "prints a list of trees or nodes",
 	IsCommon: true,
@@ -34,6 +34,10 @@ Options
       If set, and the option -i, --id is set, the parents of the indicated
       node will be printed.
     
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -m
     --machine
       If set, the output will be machine readable. That is, just ids will
@@ -58,6 +62,7 @@ Options
 func init() {
 	trLs.Flag.BoolVar(&ancsFlag, "ancs", false, "")
 	trLs.Flag.BoolVar(&ancsFlag, "a", false, "")
+	trLs.Flag.StringVar(&dbFlag, "db", "", "")
 	trLs.Flag.StringVar(&nodeFlag, "node", "", "")
 	trLs.Flag.StringVar(&nodeFlag, "n", "", "")
 	trLs.Flag.BoolVar(&machineFlag, "machine", false, "")
@@ -14,8 +14,8 @@ import (
 
 var trSet = &cmdapp.Command{
 	Name: "tr.set",
-	Synopsis: `[-i|--id value] [-n|--node value] [-p|--port value]
-	[<key=value>...]`,
+	Synopsis: `[--db name] [-i|--id value] [-n|--node value]
+	[-p|--port value] [<key=value>...]`,
 	Short: "set a tree or node value",
 	Long: `
 Description
@@ -31,6 +31,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated tree id.
@@ -65,6 +69,7 @@ Options
 }
 
 func init() {
+	trSet.Flag.StringVar(&dbFlag, "db", "", "")
 	trSet.Flag.StringVar(&idFlag, "id", "", "")
 	trSet.Flag.StringVar(&idFlag, "i", "", "")
 	trSet.Flag.StringVar(&nodeFlag, "node", "", "")
@@ -15,7 +15,7 @@ import (
 
 var txDel = &cmdapp.Command{
 	Name: "tx.del",
-	Synopsis: `[-c|--collapse] [-i|--id value] [-p|--port value]
+	Synopsis: `[-c|--collapse] [--db name] [-i|--id value] [-p|--port value]
 	[<name> [<parentname>]]`,
 	Short: "deletes a taxon",
 	Long: `
@@ -35,6 +35,10 @@ Options
       then the valid descendants will be assigned to the root of the
       taxonomy and synonyms will be deleted with the taxon.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated taxon id.
@@ -59,6 +63,7 @@ Options
 func init() {
 	txDel.Flag.BoolVar(&colpFlag, "collapse", false, "")
 	txDel.Flag.BoolVar(&colpFlag, "c", false, "")
+	txDel.Flag.StringVar(&dbFlag, "db", "", "")
 	txDel.Flag.StringVar(&idFlag, "id", "", "")
 	txDel.Flag.StringVar(&idFlag, "i", "", "")
 	txDel.Flag.StringVar(&portFlag, "port", "", "")
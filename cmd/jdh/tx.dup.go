@@ -0,0 +1,235 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/jdh"
+	"github.com/js-arias/jdh/pkg/names"
+)
+
+var txDup = &cmdapp.Command{
+	Name: "tx.dup",
+	Synopsis: `[--db name] [-i|--id value] [-p|--port value]
+	[<name> [<parentname>]]`,
+	Short: "finds duplicated taxons",
+	Long: `
+Description
+
+Tx.dup searches the taxonomy for taxons that are likely duplicates, for
+example, the same species added from different services under different
+parents. Duplicates can be merged with 'jdh tx.merge'.
+
+Taxons with the same canonical name (i.e. the name without the authority)
+are reported as duplicates if they have the same authority and rank (or if
+the authority or the rank is not defined in one of them). Otherwise they
+are reported as homonyms. Extern identifiers are not compared, as the
+database does not accept the same extern identifier in two taxons.
+
+Each group of taxons is printed with the kind of the group, and the name
+shared by the taxons, followed by the id, the name, the authority, the
+rank and the parent of each taxon, for example:
+
+    duplicate	Puma concolor
+    	12	Puma concolor (Linnaeus, 1771)	species	8 Puma
+    	341	Puma concolor	species	277 Felidae
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -i value
+    --id value
+      Search only the descendants of the indicated taxon id.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    <name>
+      Search only the descendants of the indicated name. If there are more
+      than one taxon, then the list of possible candidates will be printed
+      and the program will be terminated. Ignored if option -i or --id are
+      defined.
+
+    <parentname>
+      If defined, the taxon search with <name> will be limited to
+      descendants of the indicated name. Ignored if option -i or --id
+      are defined.
+	`,
+}
+
+func init() {
+	txDup.Flag.StringVar(&dbFlag, "db", "", "")
+	txDup.Flag.StringVar(&idFlag, "id", "", "")
+	txDup.Flag.StringVar(&idFlag, "i", "", "")
+	txDup.Flag.StringVar(&portFlag, "port", "", "")
+	txDup.Flag.StringVar(&portFlag, "p", "", "")
+	txDup.Run = txDupRun
+}
+
+func txDupRun(c *cmdapp.Command, args []string) {
+	openLocal(c)
+	var tax *jdh.Taxon
+	if len(idFlag) > 0 {
+		tax = taxon(c, localDB, idFlag)
+		if len(tax.Id) == 0 {
+			return
+		}
+	} else if len(args) > 0 {
+		if len(args) > 2 {
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("too many arguments"))
+			os.Exit(1)
+		}
+		pName := ""
+		if len(args) > 1 {
+			pName = args[1]
+		}
+		tax = pickTaxName(c, localDB, args[0], pName)
+		if len(tax.Id) == 0 {
+			return
+		}
+	} else {
+		tax = &jdh.Taxon{}
+	}
+	ls := txDupTaxa(c, tax.Id, nil)
+
+	// taxons by canonical name
+	byName := make(map[string][]*jdh.Taxon)
+	for _, tx := range ls {
+		nm := txDupCanonical(tx.Name)
+		byName[nm] = append(byName[nm], tx)
+	}
+	parents := make(map[string]*jdh.Taxon)
+	for _, nm := range txDupKeys(byName) {
+		g := txDupSynonyms(byName[nm])
+		if len(g) < 2 {
+			continue
+		}
+		kind := "duplicate"
+		if !txDupSame(g) {
+			kind = "homonym"
+		}
+		txDupPrint(c, kind, g[0].Name, g, parents)
+	}
+}
+
+// TxDupTaxa returns the descendants (including synonyms) of a taxon.
+func txDupTaxa(c *cmdapp.Command, id string, ls []*jdh.Taxon) []*jdh.Taxon {
+	for _, valid := range []bool{true, false} {
+		if (len(id) == 0) && !valid {
+			// the root has no synonyms
+			break
+		}
+		l := getTaxDesc(c, localDB, id, valid)
+		var desc []*jdh.Taxon
+		for {
+			tax := &jdh.Taxon{}
+			if err := l.Scan(tax); err != nil {
+				if err == io.EOF {
+					break
+				}
+				fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+				os.Exit(1)
+			}
+			desc = append(desc, tax)
+		}
+		for _, tax := range desc {
+			ls = append(ls, tax)
+			ls = txDupTaxa(c, tax.Id, ls)
+		}
+	}
+	return ls
+}
+
+// TxDupCanonical returns the canonical name used to compare taxons.
+func txDupCanonical(name string) string {
+	if n, err := names.Parse(name); err == nil {
+		name = n.Canonical()
+	}
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// TxDupSynonyms removes from a group the synonyms of other taxons in the
+// group (e.g. taxons already merged with 'jdh tx.merge').
+func txDupSynonyms(g []*jdh.Taxon) []*jdh.Taxon {
+	ids := make(map[string]bool)
+	for _, tx := range g {
+		ids[tx.Id] = true
+	}
+	var ng []*jdh.Taxon
+	for _, tx := range g {
+		if !tx.IsValid && ids[tx.Parent] {
+			continue
+		}
+		ng = append(ng, tx)
+	}
+	return ng
+}
+
+// TxDupSame returns true if all the taxons in a group with the same name
+// have compatible authorities and ranks.
+func txDupSame(g []*jdh.Taxon) bool {
+	for i, tx := range g {
+		for _, ot := range g[i+1:] {
+			if (tx.Rank != jdh.Unranked) && (ot.Rank != jdh.Unranked) && (tx.Rank != ot.Rank) {
+				return false
+			}
+			a, b := txDupAuthority(tx.Authority), txDupAuthority(ot.Authority)
+			if (len(a) > 0) && (len(b) > 0) && (a != b) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TxDupAuthority returns an authority without spaces and punctuation, so
+// "(Linnaeus, 1771)" and "(Linnaeus 1771)" are the same authority.
+func txDupAuthority(a string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, a)
+}
+
+// TxDupKeys returns the sorted keys of a group map.
+func txDupKeys(m map[string][]*jdh.Taxon) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TxDupPrint prints a group of taxons.
+func txDupPrint(c *cmdapp.Command, kind, name string, g []*jdh.Taxon, parents map[string]*jdh.Taxon) {
+	fmt.Fprintf(os.Stdout, "%s\t%s\n", kind, name)
+	for _, tx := range g {
+		pName := ""
+		if len(tx.Parent) > 0 {
+			p, ok := parents[tx.Parent]
+			if !ok {
+				p = taxon(c, localDB, tx.Parent)
+				parents[tx.Parent] = p
+			}
+			pName = p.Id + " " + p.Name
+		}
+		fmt.Fprintf(os.Stdout, "\t%s\t%s\t%s\t%s\n", tx.Id, strings.TrimSpace(tx.Name+" "+tx.Authority), tx.Rank, pName)
+	}
+}
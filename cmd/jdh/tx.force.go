@@ -16,8 +16,8 @@ import (
 
 var txForce = &cmdapp.Command{
 	Name: "tx.force",
-	Synopsis: `[-i|--id value] [-p|--port value] [-r|--rank name]
-	[<name> [<parentname>]]`,
+	Synopsis: `[--db name] [-i|--id value] [-p|--port value]
+	[-r|--rank name] [<name> [<parentname>]]`,
 	Short: "enforces a ranked taxonomy",
 	Long: `
 Description
@@ -25,8 +25,15 @@ Description
 Tx.force enforces the database to be ranked, synonymizing all rankless taxa
 with their most inmmediate ranked taxon.
 
+All the changes are done in a single transaction, so if an error happens, no
+taxon will be modified.
+
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Search for the indicated taxon id.
@@ -40,13 +47,14 @@ Options
     --rank name
       If set search only for taxons below the indicated rank.
       Valid values are:
-      	  unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          unranked
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     <name>
       Search for the indicated name. If there are more than one taxon,
@@ -61,6 +69,7 @@ Options
 }
 
 func init() {
+	txForce.Flag.StringVar(&dbFlag, "db", "", "")
 	txForce.Flag.StringVar(&idFlag, "id", "", "")
 	txForce.Flag.StringVar(&idFlag, "i", "", "")
 	txForce.Flag.StringVar(&portFlag, "port", "", "")
@@ -96,14 +105,15 @@ func txForceRun(c *cmdapp.Command, args []string) {
 	}
 	rank := jdh.Kingdom
 	if len(rankFlag) > 0 {
-		rank := jdh.GetRank(rankFlag)
+		rank = jdh.GetRank(rankFlag)
 		if rank.String() != strings.ToLower(rankFlag) {
 			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("invalid rank"))
 			os.Exit(1)
 		}
 	}
+	beginTx(c)
 	txForceProc(c, tax, jdh.Kingdom, rank)
-	localDB.Exec(jdh.Commit, "", nil)
+	commitTx(c)
 }
 
 func txForceProc(c *cmdapp.Command, tax *jdh.Taxon, prevRank, rank jdh.Rank) {
@@ -118,8 +128,7 @@ func txForceProc(c *cmdapp.Command, tax *jdh.Taxon, prevRank, rank jdh.Rank) {
 			if err == io.EOF {
 				break
 			}
-			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-			os.Exit(1)
+			abortTx(c, err)
 		}
 		txForceProc(c, desc, r, rank)
 	}
@@ -139,5 +148,7 @@ func txForceProc(c *cmdapp.Command, tax *jdh.Taxon, prevRank, rank jdh.Rank) {
 	args := new(jdh.Values)
 	args.Add(jdh.KeyId, tax.Id)
 	args.Add(jdh.TaxSynonym, tax.Parent)
-	localDB.Exec(jdh.Set, jdh.Taxonomy, args)
+	if _, err := localDB.Exec(jdh.Set, jdh.Taxonomy, args); err != nil {
+		abortTx(c, err)
+	}
 }
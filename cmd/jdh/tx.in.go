@@ -17,8 +17,9 @@ import (
 
 var txIn = &cmdapp.Command{
 	Name: "tx.in",
-	Synopsis: `[-a|--anc value] [-f|--format value] [-p|--port value]
-	[-r|--rank name] [-s|--synonym] [-v|--verbose] [<file>...]`,
+	Synopsis: `[-a|--anc value] [--db name] [-f|--format value]
+	[-n|--new] [-p|--port value] [-r|--rank name] [-s|--synonym]
+	[-v|--verbose] [<file>...]`,
 	Short: "imports taxon data",
 	Long: `
 Description
@@ -28,9 +29,19 @@ Tx.in reads taxon data from the indicated files, or the standard input
 
 Default input format is txt. If the format is txt, it is assummed that each 
 line corresponds to a taxon (lines starting with '#' or ';' will be ignored).
+The name of the taxon can be followed by its authority, for example:
+
+    Puma concolor (Linnaeus, 1771)
+    Abies alba subsp. apennina Brullo, Scelsi & Spamp.
+
+Qualifiers of open nomenclature (sp., cf., aff.) are part of the name (for
+example, 'Puma sp. 1', or 'Puma cf. concolor'). Names that are not formal
+scientific names (for example, 'Clade1') are added as they are.
 
 By default, taxons will be added to the root of the taxonomy, valid, and
-unranked.
+unranked. If the rank is species, or below species, the genus (and the
+species of an infraspecific taxon) is taken from the name of the taxon, and
+added if it is not in the database.
 
 Options
 
@@ -38,11 +49,24 @@ Options
     --anc value
       Sets the parent of the added taxons. The value must be a valid id.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -f value
     --format value
       Sets the format used in the source data. Valid values are:
           txt        Txt format
 
+    -n
+    --new
+      If set, taxons with names spelled as the names of taxons already in
+      the database, except for the case, or the hyphens, will be added. By
+      default, these taxons are not added, and the names are reported.
+      Taxons with other similar names (for example, a misspelling of a
+      name, or a congener with a similar epithet) are always added, and
+      the similar names are reported.
+
     -p value
     --port value
       Sets the port in which the server will be listening. By default the
@@ -53,13 +77,14 @@ Options
       Set the rank of the added taxon. If the taxon has a parent (the -a,
       --anc options) the parent must be concordant with the given rank.
       Valid values are:
-      	  unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          unranked
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -s
     --synonym
@@ -81,8 +106,11 @@ Options
 func init() {
 	txIn.Flag.StringVar(&ancFlag, "anc", "", "")
 	txIn.Flag.StringVar(&ancFlag, "a", "", "")
+	txIn.Flag.StringVar(&dbFlag, "db", "", "")
 	txIn.Flag.StringVar(&formatFlag, "format", "", "")
 	txIn.Flag.StringVar(&formatFlag, "f", "", "")
+	txIn.Flag.BoolVar(&newFlag, "new", false, "")
+	txIn.Flag.BoolVar(&newFlag, "n", false, "")
 	txIn.Flag.StringVar(&portFlag, "port", "", "")
 	txIn.Flag.StringVar(&portFlag, "p", "", "")
 	txIn.Flag.StringVar(&rankFlag, "rank", "", "")
@@ -165,34 +193,26 @@ func txInTxt(c *cmdapp.Command, fname, parent string, rank jdh.Rank, valid bool)
 		if r := []rune(nm); !unicode.IsLetter(r[0]) {
 			continue
 		}
+		n := parseName(nm)
 		// skip names already in the database
-		if taxInDB(c, localDB, nm, parent, rank, valid) != nil {
+		if taxInDB(c, localDB, n.Canonical(), parent, rank, valid) != nil {
 			continue
 		}
-		pId := parent
-		// we know that the first name of a species is the genus.
-		if rank == jdh.Species {
-			if p := taxInDB(c, localDB, tn[0], parent, jdh.Genus, true); p != nil {
-				pId = p.Id
-			} else {
-				par := &jdh.Taxon{
-					Name:    tn[0],
-					IsValid: true,
-					Parent:  parent,
-					Rank:    jdh.Genus,
-				}
-				pId, err = localDB.Exec(jdh.Add, jdh.Taxonomy, par)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
-					continue
-				}
-			}
+		if err := similarTaxa(c, localDB, n.Canonical(), parent); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			continue
+		}
+		pId, err := nameParent(c, localDB, n, parent, rank)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			continue
 		}
 		tax := &jdh.Taxon{
-			Name:    nm,
-			IsValid: valid,
-			Parent:  pId,
-			Rank:    rank,
+			Name:      n.Canonical(),
+			Authority: n.Authority(),
+			IsValid:   valid,
+			Parent:    pId,
+			Rank:      rank,
 		}
 		id, err := localDB.Exec(jdh.Add, jdh.Taxonomy, tax)
 		if err != nil {
@@ -0,0 +1,49 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// TestTxInCongeners checks that congeners with similar names are added,
+// but not a name with the same spelling of a name in the database.
+func TestTxInCongeners(t *testing.T) {
+	portFlag = localPrefix + t.TempDir()
+	openLocal(txIn)
+	defer localDB.Close()
+	gen, err := localDB.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: "Lanius", Rank: jdh.Genus, IsValid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(t.TempDir(), "names.txt")
+	names := "Lanius collurio Linnaeus, 1758\nLanius collaris (Linnaeus, 1766)\nLanius col-lurio\n"
+	if err := ioutil.WriteFile(fn, []byte(names), 0644); err != nil {
+		t.Fatal(err)
+	}
+	txInTxt(txIn, fn, gen, jdh.Species, true)
+
+	vals := new(jdh.Values)
+	vals.Add(jdh.TaxChildren, gen)
+	l, err := localDB.List(jdh.Taxonomy, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for {
+		tax := &jdh.Taxon{}
+		if err := l.Scan(tax); err != nil {
+			break
+		}
+		got[tax.Name] = true
+	}
+	if !got["Lanius collurio"] || !got["Lanius collaris"] || (len(got) != 2) {
+		t.Errorf("children of %s: got %v, want Lanius collurio and Lanius collaris", gen, got)
+	}
+}
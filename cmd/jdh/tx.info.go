@@ -8,15 +8,18 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/js-arias/cmdapp"
 	"github.com/js-arias/jdh/pkg/jdh"
+	"github.com/js-arias/jdh/pkg/names"
 )
 
 var txInfo = &cmdapp.Command{
 	Name: "tx.info",
-	Synopsis: `[-e|--extdb name] [-i|--id value] [-k|--key value]
-	[-m|--machine] [-p|--port value] [<name> [<parentname>]]`,
+	Synopsis: `[--db name] [-e|--extdb name] [-i|--id value]
+	[-k|--key value] [-m|--machine] [-p|--port value]
+	[<name> [<parentname>]]`,
 	Short:    "prints general taxon information",
 	IsCommon: true,
 	Long: `
@@ -25,8 +28,21 @@ Description
 Tx.info prints general information of a taxon in the database. For the
 list of parents, descendants of synonyms of the taxon, use 'jdh tx.ls'.
 
+The information includes the history of the taxon, i.e. the nomenclatural
+acts that changed the parent, or the validity, of the taxon (for example,
+when the taxon is set as a synonym with 'jdh tx.set'). Each act is printed
+with its date, the placement of the taxon before and after the act, and
+the reference of the act, if any. In machine readable output, each act is
+printed as a history key, with the date, the old parent, the old validity,
+the new parent, the new validity, and the reference of the act, separated
+by tabs. The history is only stored in the local database.
+
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -47,12 +63,29 @@ Options
           comment        A free text comment on the taxon.
           extern         Extern identifiers of the taxon, in the form
                          <service>:<key>, for example: gbif:5216933.
+          history        Nomenclatural acts of the taxon.
           name           Name of the taxon.
           parent         Id of the new parent.
           rank           The taxon rank.
           synonym        Prints the parent of the taxon, if it is a synonym.
                          If the taxon is valid, the valid string will be printed.
           valid          See synonym.
+      The parts of the parsed name of the taxon can be printed with the
+      following keys:
+          genus          Genus of the name, or the name of a taxon above
+                         the genus.
+          infrageneric   Infrageneric epithet (e.g. subgenus).
+          specific       Specific epithet.
+          infraspecific  Infraspecific epithet.
+          marker         Rank marker of the infraspecific epithet (or the
+                         infrageneric epithet), for example: var.
+          authors        Authors of the name, without the parenthetical
+                         authors.
+          year           Year of the name.
+          parauthors     Parenthetical authors of the name, i.e. the
+                         authors of the original combination, or the
+                         basionym.
+          paryear        Year of the parenthetical authors.
 
     -m
     --machine
@@ -77,6 +110,7 @@ Options
 }
 
 func init() {
+	txInfo.Flag.StringVar(&dbFlag, "db", "", "")
 	txInfo.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	txInfo.Flag.StringVar(&extDBFlag, "e", "", "")
 	txInfo.Flag.StringVar(&idFlag, "id", "", "")
@@ -126,6 +160,9 @@ func txInfoRun(c *cmdapp.Command, args []string) {
 		if len(keyFlag) == 0 {
 			fmt.Fprintf(os.Stdout, "%s=%s\n", jdh.TaxName, tax.Name)
 			fmt.Fprintf(os.Stdout, "%s=%s\n", jdh.TaxAuthority, tax.Authority)
+			for _, np := range txInfoName(tax) {
+				fmt.Fprintf(os.Stdout, "%s=%s\n", np.key, np.value)
+			}
 			fmt.Fprintf(os.Stdout, "%s=%s\n", jdh.TaxRank, tax.Rank)
 			if tax.IsValid {
 				fmt.Fprintf(os.Stdout, "%s=true\n", jdh.TaxValid)
@@ -137,6 +174,7 @@ func txInfoRun(c *cmdapp.Command, args []string) {
 				fmt.Fprintf(os.Stdout, "%s=%s\n", jdh.KeyExtern, e)
 			}
 			fmt.Fprintf(os.Stdout, "%s=%s\n", jdh.KeyComment, tax.Comment)
+			txInfoMachineActs(txInfoActs(c, db, tax.Id))
 			return
 		}
 		switch jdh.Key(keyFlag) {
@@ -160,6 +198,14 @@ func txInfoRun(c *cmdapp.Command, args []string) {
 			}
 		case jdh.KeyComment:
 			fmt.Fprintf(os.Stdout, "%s=%s\n", jdh.KeyComment, tax.Comment)
+		case "history":
+			txInfoMachineActs(txInfoActs(c, db, tax.Id))
+		default:
+			for _, np := range txInfoName(tax) {
+				if np.key == keyFlag {
+					fmt.Fprintf(os.Stdout, "%s=%s\n", np.key, np.value)
+				}
+			}
 		}
 		return
 	}
@@ -167,6 +213,9 @@ func txInfoRun(c *cmdapp.Command, args []string) {
 		fmt.Fprintf(os.Stdout, "%-16s %s\n", "Id:", tax.Id)
 		fmt.Fprintf(os.Stdout, "%-16s %s\n", "Name:", tax.Name)
 		fmt.Fprintf(os.Stdout, "%-16s %s\n", "Authority:", tax.Authority)
+		for _, np := range txInfoName(tax) {
+			fmt.Fprintf(os.Stdout, "  %-14s %s\n", np.label+":", np.value)
+		}
 		fmt.Fprintf(os.Stdout, "%-16s %s\n", "Rank:", tax.Rank)
 		if tax.IsValid {
 			fmt.Fprintf(os.Stdout, "%-16s true\n", "Valid:")
@@ -186,6 +235,10 @@ func txInfoRun(c *cmdapp.Command, args []string) {
 		if len(tax.Comment) > 0 {
 			fmt.Fprintf(os.Stdout, "Comments:\n%s\n", tax.Comment)
 		}
+		if acts := txInfoActs(c, db, tax.Id); len(acts) > 0 {
+			fmt.Fprintf(os.Stdout, "History:\n")
+			txInfoHistory(c, db, acts)
+		}
 		txInfoList(c, db, tax.Id, true)
 		txInfoList(c, db, tax.Id, false)
 		return
@@ -211,7 +264,116 @@ func txInfoRun(c *cmdapp.Command, args []string) {
 		}
 	case jdh.KeyComment:
 		fmt.Fprintf(os.Stdout, "%s\n", tax.Comment)
+	case "history":
+		txInfoHistory(c, db, txInfoActs(c, db, tax.Id))
+	default:
+		for _, np := range txInfoName(tax) {
+			if np.key == keyFlag {
+				fmt.Fprintf(os.Stdout, "%s\n", np.value)
+			}
+		}
+	}
+}
+
+// namePart is a part of a parsed name.
+type namePart struct {
+	key, label, value string
+}
+
+// TxInfoName returns the parts of the parsed name of a taxon. Empty parts
+// are not returned.
+func txInfoName(tax *jdh.Taxon) []namePart {
+	n, err := names.Parse(tax.Name + " " + tax.Authority)
+	if err != nil {
+		return nil
+	}
+	marker := n.InfraspecificMarker
+	if len(n.Infraspecific) == 0 {
+		marker = n.InfragenericMarker
+	}
+	parts := []namePart{
+		{"genus", "Genus", n.Genus},
+		{"infrageneric", "Infrageneric", n.Infrageneric},
+		{"specific", "Specific", n.Specific},
+		{"infraspecific", "Infraspecific", n.Infraspecific},
+		{"marker", "Marker", marker},
+		{"authors", "Authors", n.Authors},
+		{"year", "Year", n.Year},
+		{"parauthors", "Par. authors", n.ParAuthors},
+		{"paryear", "Par. year", n.ParYear},
+	}
+	var np []namePart
+	for _, p := range parts {
+		if len(p.value) > 0 {
+			np = append(np, p)
+		}
+	}
+	return np
+}
+
+// TxInfoActs returns the nomenclatural acts of a taxon. Extern databases
+// do not store the acts of its taxons.
+func txInfoActs(c *cmdapp.Command, db jdh.DB, id string) []*jdh.TaxAct {
+	if len(extDBFlag) != 0 {
+		return nil
+	}
+	vals := new(jdh.Values)
+	vals.Add(jdh.ActTaxon, id)
+	l, err := db.List(jdh.TaxActs, vals)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	var acts []*jdh.TaxAct
+	for {
+		act := &jdh.TaxAct{}
+		if err := l.Scan(act); err != nil {
+			if err == io.EOF {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			os.Exit(1)
+		}
+		acts = append(acts, act)
+	}
+	return acts
+}
+
+// TxInfoHistory prints the nomenclatural acts of a taxon.
+func txInfoHistory(c *cmdapp.Command, db jdh.DB, acts []*jdh.TaxAct) {
+	for _, act := range acts {
+		fmt.Fprintf(os.Stdout, "\t%s\t%s -> %s\n", act.Date.Format("2006-01-02 15:04:05"), txInfoPlace(c, db, act.OldParent, act.OldValid), txInfoPlace(c, db, act.NewParent, act.NewValid))
+		if len(act.Reference) > 0 {
+			fmt.Fprintf(os.Stdout, "\t\tReference: %s\n", act.Reference)
+		}
+	}
+}
+
+// TxInfoMachineActs prints the nomenclatural acts of a taxon in machine
+// readable output.
+func txInfoMachineActs(acts []*jdh.TaxAct) {
+	for _, act := range acts {
+		fmt.Fprintf(os.Stdout, "history=%s\t%s\t%v\t%s\t%v\t%s\n", act.Date.Format(time.RFC3339), act.OldParent, act.OldValid, act.NewParent, act.NewValid, act.Reference)
+	}
+}
+
+// TxInfoPlace returns the placement of a taxon in the taxonomy, as printed
+// in the history of the taxon.
+func txInfoPlace(c *cmdapp.Command, db jdh.DB, parent string, valid bool) string {
+	if len(parent) == 0 {
+		if valid {
+			return "valid, without parent"
+		}
+		return "synonym"
+	}
+	p := "[id: " + parent + "]"
+	if pt := taxon(c, db, parent); len(pt.Id) > 0 {
+		p = pt.Name + " " + p
+	}
+	if valid {
+		return "valid, in " + p
 	}
+	return "synonym of " + p
 }
 
 func txInfoList(c *cmdapp.Command, db jdh.DB, pId string, valid bool) {
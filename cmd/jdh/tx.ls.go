@@ -16,7 +16,7 @@ import (
 
 var txLs = &cmdapp.Command{
 	Name: "tx.ls",
-	Synopsis: `[-a|--ancs] [-e|--extdb name] [-i|--id value]
+	Synopsis: `[-a|--ancs] [--db name] [-e|--extdb name] [-i|--id value]
 	[-m|--machine] [-p|--port value] [-r|--rank name] [-s|--synonym]
 	[-v|--verbose] [<name> [<parentname>]]`,
 	Short:    "prints a list of taxons",
@@ -37,6 +37,10 @@ Options
     --ancs
       If set, the parents of the indicated taxon will be printed.
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -64,12 +68,13 @@ Options
       If indicated, the only taxons at the given rank will be printed. Valid
       values are:
           unranked
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -s
     --synonym
@@ -95,6 +100,7 @@ Options
 func init() {
 	txLs.Flag.BoolVar(&ancsFlag, "ancs", false, "")
 	txLs.Flag.BoolVar(&ancsFlag, "a", false, "")
+	txLs.Flag.StringVar(&dbFlag, "db", "", "")
 	txLs.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	txLs.Flag.StringVar(&extDBFlag, "e", "", "")
 	txLs.Flag.StringVar(&idFlag, "id", "", "")
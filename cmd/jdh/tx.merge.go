@@ -0,0 +1,237 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+var txMerge = &cmdapp.Command{
+	Name:     "tx.merge",
+	Synopsis: `[--db name] [-p|--port value] [-x|--delete] <id> <target>`,
+	Short:    "merges a taxon into another taxon",
+	Long: `
+Description
+
+Tx.merge merges a taxon into a target taxon, for example, to remove a
+duplicate found with 'jdh tx.dup'. The specimens, the rasterized
+distributions, the tree terminals, and the extern identifiers of the taxon
+are moved to the target taxon, and then, the taxon is set as a synonym of
+the target (its descendants, including synonyms, are moved to the target).
+If the option -x, --delete is defined, then the taxon will be deleted.
+
+Extern identifiers of a service already defined in the target are kept by
+the merged taxon (and deleted if the taxon is deleted). If a tree has both
+taxons as terminals, the terminal of the merged taxon will be left without
+a taxon.
+
+All the changes are done in a single transaction, so if an error happens, no
+data will be modified.
+
+Options
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+
+    -x
+    --delete
+      If set, the merged taxon will be deleted, instead of being set as a
+      synonym of the target.
+
+    <id>
+      Id of the taxon to be merged.
+
+    <target>
+      Id of the target taxon.
+	`,
+}
+
+func init() {
+	txMerge.Flag.StringVar(&dbFlag, "db", "", "")
+	txMerge.Flag.BoolVar(&delFlag, "delete", false, "")
+	txMerge.Flag.BoolVar(&delFlag, "x", false, "")
+	txMerge.Flag.StringVar(&portFlag, "port", "", "")
+	txMerge.Flag.StringVar(&portFlag, "p", "", "")
+	txMerge.Run = txMergeRun
+}
+
+func txMergeRun(c *cmdapp.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("expecting taxon and target ids"))
+		c.Usage()
+	}
+	openLocal(c)
+	tax := taxon(c, localDB, args[0])
+	if len(tax.Id) == 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("taxon "+args[0]+" not in database"))
+		os.Exit(1)
+	}
+	tgt := taxon(c, localDB, args[1])
+	if len(tgt.Id) == 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("taxon "+args[1]+" not in database"))
+		os.Exit(1)
+	}
+	if tax.Id == tgt.Id {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("a taxon can not be merged with itself"))
+		os.Exit(1)
+	}
+	if !tgt.IsValid {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("taxon "+tgt.Name+" is a synonym, can not be a target"))
+		os.Exit(1)
+	}
+	if isInParentList(c, localDB, tgt.Id, []string{tax.Id}) {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr("taxon "+tgt.Name+" is a descendant of "+tax.Name))
+		os.Exit(1)
+	}
+	beginTx(c)
+	vals := new(jdh.Values)
+
+	// specimens
+	vals.Add(jdh.SpeTaxon, tax.Id)
+	var ids []string
+	l := speList(c, localDB, vals)
+	for {
+		spe := &jdh.Specimen{}
+		if err := l.Scan(spe); err != nil {
+			if err == io.EOF {
+				break
+			}
+			abortTx(c, err)
+		}
+		ids = append(ids, spe.Id)
+	}
+	for _, id := range ids {
+		vals.Reset()
+		vals.Add(jdh.KeyId, id)
+		vals.Add(jdh.SpeTaxon, tgt.Id)
+		if _, err := localDB.Exec(jdh.Set, jdh.Specimens, vals); err != nil {
+			abortTx(c, err)
+		}
+	}
+
+	// rasterized distributions
+	vals.Reset()
+	vals.Add(jdh.RDisTaxon, tax.Id)
+	ids = nil
+	l = rasList(c, localDB, vals)
+	for {
+		ras := &jdh.Raster{}
+		if err := l.Scan(ras); err != nil {
+			if err == io.EOF {
+				break
+			}
+			abortTx(c, err)
+		}
+		ids = append(ids, ras.Id)
+	}
+	for _, id := range ids {
+		vals.Reset()
+		vals.Add(jdh.KeyId, id)
+		vals.Add(jdh.RDisTaxon, tgt.Id)
+		if _, err := localDB.Exec(jdh.Set, jdh.RasDistros, vals); err != nil {
+			abortTx(c, err)
+		}
+	}
+
+	// tree terminals
+	inTree := make(map[string]bool)
+	for _, nod := range txMergeNodes(c, tgt.Id) {
+		inTree[nod.Tree] = true
+	}
+	for _, nod := range txMergeNodes(c, tax.Id) {
+		vals.Reset()
+		vals.Add(jdh.KeyId, nod.Id)
+		if inTree[nod.Tree] {
+			vals.Add(jdh.NodTaxon, "")
+		} else {
+			vals.Add(jdh.NodTaxon, tgt.Id)
+		}
+		if _, err := localDB.Exec(jdh.Set, jdh.Nodes, vals); err != nil {
+			abortTx(c, err)
+		}
+	}
+
+	// extern ids
+	for _, e := range tax.Extern {
+		serv, _, err := jdh.ParseExtern(e)
+		if err != nil {
+			continue
+		}
+		if txMergeHasServ(tgt, serv) {
+			continue
+		}
+		vals.Reset()
+		vals.Add(jdh.KeyId, tax.Id)
+		vals.Add(jdh.KeyExtern, serv)
+		if _, err := localDB.Exec(jdh.Set, jdh.Taxonomy, vals); err != nil {
+			abortTx(c, err)
+		}
+		vals.Reset()
+		vals.Add(jdh.KeyId, tgt.Id)
+		vals.Add(jdh.KeyExtern, e)
+		if _, err := localDB.Exec(jdh.Set, jdh.Taxonomy, vals); err != nil {
+			abortTx(c, err)
+		}
+	}
+
+	vals.Reset()
+	vals.Add(jdh.KeyId, tax.Id)
+	vals.Add(jdh.TaxSynonym, tgt.Id)
+	if _, err := localDB.Exec(jdh.Set, jdh.Taxonomy, vals); err != nil {
+		abortTx(c, err)
+	}
+	if delFlag {
+		vals.Reset()
+		vals.Add(jdh.KeyId, tax.Id)
+		if _, err := localDB.Exec(jdh.Delete, jdh.Taxonomy, vals); err != nil {
+			abortTx(c, err)
+		}
+	}
+	commitTx(c)
+}
+
+// TxMergeNodes returns the tree nodes associated with a taxon.
+func txMergeNodes(c *cmdapp.Command, id string) []*jdh.Node {
+	vals := new(jdh.Values)
+	vals.Add(jdh.NodTaxon, id)
+	l, err := localDB.List(jdh.Nodes, vals)
+	if err != nil {
+		abortTx(c, err)
+	}
+	var ls []*jdh.Node
+	for {
+		nod := &jdh.Node{}
+		if err := l.Scan(nod); err != nil {
+			if err == io.EOF {
+				break
+			}
+			abortTx(c, err)
+		}
+		ls = append(ls, nod)
+	}
+	return ls
+}
+
+// TxMergeHasServ returns true if a taxon has an extern id of a given
+// service.
+func txMergeHasServ(tax *jdh.Taxon, serv string) bool {
+	for _, e := range tax.Extern {
+		if s, _, err := jdh.ParseExtern(e); (err == nil) && (s == serv) {
+			return true
+		}
+	}
+	return false
+}
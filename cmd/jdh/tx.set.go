@@ -15,8 +15,8 @@ import (
 
 var txSet = &cmdapp.Command{
 	Name: "tx.set",
-	Synopsis: `[-i|--id value] [-p|--port value] [<name> [<parentname>]]
-	[<key=value>...]`,
+	Synopsis: `[--db name] [-i|--id value] [-p|--port value]
+	[<name> [<parentname>]] [<key=value>...]`,
 	Short: "sets a taxon value",
 	Long: `
 Description
@@ -24,6 +24,10 @@ Description
 Tx.set sets a particular value for a taxon in the database. Use this
 command to edit the taxon database, instead of manual edition.
 
+Changes of the parent, or the validity, of the taxon (i.e. nomenclatural
+acts) are stored in the history of the taxon, that can be printed with
+'jdh tx.info'.
+
 If no key is defined, the key values will be read from the standard input, 
 it is assumed that each line is in the form:
      'key=value'
@@ -31,6 +35,10 @@ Lines starting with '#' or ';' will be ignored.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -i value
     --id value
       Indicate the taxon to be set.
@@ -63,6 +71,10 @@ Options
                              genus
                              species
 
+          reference      Bibliographic reference of the change of the
+                         parent, or the validity, of the taxon. It is
+                         stored in the history of the taxon (see
+                         'jdh tx.info'), and not in the taxon.
           synonym        Set the taxon as synonym. If no id of a new parent
                          is defined, the taxon will be synonymized with its
                          current parent.
@@ -82,6 +94,7 @@ Options
 }
 
 func init() {
+	txSet.Flag.StringVar(&dbFlag, "db", "", "")
 	txSet.Flag.StringVar(&idFlag, "id", "", "")
 	txSet.Flag.StringVar(&idFlag, "i", "", "")
 	txSet.Flag.StringVar(&portFlag, "port", "", "")
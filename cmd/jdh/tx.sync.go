@@ -15,7 +15,7 @@ import (
 
 var txSync = &cmdapp.Command{
 	Name: "tx.sync",
-	Synopsis: `-e|--extdb name [-d|--validate] [-i|--id value]
+	Synopsis: `-e|--extdb name [-d|--validate] [--db name] [-i|--id value]
 	[-l|--populate name] [-m|--match] [-p|--port value] [-r|--rank name]
 	[-u|--update] [-v|--verbose] [<name> [<parentname>]]`,
 	Short:    "updates local database using an extern database",
@@ -41,6 +41,10 @@ Options
       If set, and -u, --update option is defined, then the validity of the
       taxon will be set as in the extern database.
     
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database.
@@ -59,12 +63,13 @@ Options
       If set, then the taxons below the indicated rank will be populated
       with all descendants (valid and invalid) from the extern database.
       Valid values are:
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
 
     -m
     --match
@@ -83,12 +88,13 @@ Options
       indicates the maximum rank to search, for example '--rank=class' only
       assign taxons up to class rank.
       Valid values are:
-          kingdom
-          class
-          order
-          family
-          genus
-          species
+          kingdom, subkingdom
+          phylum, subphylum
+          superclass, class, subclass, infraclass
+          superorder, order, suborder, infraorder
+          superfamily, family, subfamily, tribe, subtribe
+          genus, subgenus, section, series
+          species, subspecies, variety, form
   
     -u
     --update
@@ -112,6 +118,7 @@ Options
 func init() {
 	txSync.Flag.BoolVar(&validFlag, "validate", false, "")
 	txSync.Flag.BoolVar(&validFlag, "d", false, "")
+	txSync.Flag.StringVar(&dbFlag, "db", "", "")
 	txSync.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	txSync.Flag.StringVar(&extDBFlag, "e", "", "")
 	txSync.Flag.StringVar(&idFlag, "id", "", "")
@@ -135,7 +142,7 @@ func txSyncRun(c *cmdapp.Command, args []string) {
 		c.Usage()
 	}
 	openLocal(c)
-	openExt(c, extDBFlag, "")
+	openExtServ(c)
 	var tax *jdh.Taxon
 	if len(idFlag) > 0 {
 		tax = taxon(c, localDB, idFlag)
@@ -424,7 +431,7 @@ func getValidParent(c *cmdapp.Command, extId string) string {
 
 // txSyncRank implements rank option of tx.sync.
 func txSyncRank(c *cmdapp.Command, tax *jdh.Taxon, rank jdh.Rank) {
-	for i := jdh.Species; i >= rank; i-- {
+	for i := jdh.Form; i >= rank; i-- {
 		txSyncRankSet(c, tax, i)
 	}
 }
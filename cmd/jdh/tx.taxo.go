@@ -17,8 +17,9 @@ import (
 
 var txTaxo = &cmdapp.Command{
 	Name: "tx.taxo",
-	Synopsis: `[-e|--extdb name] [-f|--format name]	[-i|--id value]
-	[-p|--port value] [-s|--simple]	[<name> [<parentname>]]`,
+	Synopsis: `[--db name] [-e|--extdb name]
+	[-f|--format name]	[-i|--id value] [-p|--port value]
+	[-s|--simple]	[<name> [<parentname>]]`,
 	Short: "prints taxonomy",
 	Long: `
 Description
@@ -28,6 +29,10 @@ taxonomic catalog.
 
 Options
 
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
     -e name
     --extdb name
       Set the extern database. By default, the local database is used.
@@ -65,6 +70,7 @@ Options
 }
 
 func init() {
+	txTaxo.Flag.StringVar(&dbFlag, "db", "", "")
 	txTaxo.Flag.StringVar(&extDBFlag, "extdb", "", "")
 	txTaxo.Flag.StringVar(&extDBFlag, "e", "", "")
 	txTaxo.Flag.StringVar(&formatFlag, "format", "", "")
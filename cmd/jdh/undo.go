@@ -0,0 +1,77 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+var jdhUndo = &cmdapp.Command{
+	Name:     "undo",
+	Synopsis: `[-c|--commit] [--db name] [-i|--id value] [-p|--port value]`,
+	Short:    "reverts changes of the database",
+	IsCommon: true,
+	Long: `
+Description
+
+Undo reverts the last change made in the database. If an id is given, the
+indicated change, and all the changes made after it, will be reverted. Use
+the history command to see the list of changes that can be reverted.
+
+A reverted change is removed from the history, so it can not be reverted
+again. The reversion is not saved into harddisk, unless the option -c,
+--commit is defined.
+
+Options
+
+    -c
+    --commit
+      If set, the database will be saved into harddisk after the changes
+      are reverted.
+
+    --db name
+      Sets the database of the server to be used. By default, the main
+      database of the server is used.
+
+    -i value
+    --id value
+      Sets the id of the change to be reverted.
+
+    -p value
+    --port value
+      Sets the port in which the server will be listening. By default the
+      value is ":16917"
+	`,
+}
+
+func init() {
+	jdhUndo.Flag.BoolVar(&commFlag, "commit", false, "")
+	jdhUndo.Flag.BoolVar(&commFlag, "c", false, "")
+	jdhUndo.Flag.StringVar(&dbFlag, "db", "", "")
+	jdhUndo.Flag.StringVar(&idFlag, "id", "", "")
+	jdhUndo.Flag.StringVar(&idFlag, "i", "", "")
+	jdhUndo.Flag.StringVar(&portFlag, "port", "", "")
+	jdhUndo.Flag.StringVar(&portFlag, "p", "", "")
+	jdhUndo.Run = undoRun
+}
+
+func undoRun(c *cmdapp.Command, args []string) {
+	openLocal(c)
+	vals := new(jdh.Values)
+	if len(idFlag) > 0 {
+		vals.Add(jdh.KeyId, idFlag)
+	}
+	if _, err := localDB.Exec(jdh.Undo, jdh.History, vals); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	if commFlag {
+		localDB.Exec(jdh.Commit, "", nil)
+	}
+}
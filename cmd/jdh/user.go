@@ -0,0 +1,100 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/cmdapp"
+	"github.com/js-arias/jdh/pkg/server"
+)
+
+var jdhUser = &cmdapp.Command{
+	Name:     "user",
+	Synopsis: `[-d|--dir path] [-r|--role name] [-x|--delete] [<name>]`,
+	Short:    "manages the users of the database",
+	Long: `
+Description
+
+User manages the users that can access the database from other hosts. Each
+user has a token, that must be used by the client to connect with the
+server, either using the JDH_TOKEN environment variable, or as part of the
+port value, for example:
+
+    jdh tx.ls -p "myserver:16917?token=<token>"
+
+Clients without a token can only read the database, except if they are
+connected from the local host.
+
+Without arguments, the list of users and their roles will be printed. If a
+name is given, the user will be added to the database, and its token will
+be printed. If the user already exists, it will receive a new token, and
+the old token will be invalid.
+
+This command works directly over the database files, so it can be used when
+the server is running, or not.
+
+Options
+
+    -d path
+    --dir path
+      Sets the directory in which the database files are located. By
+      default, the current directory is used as the directory.
+
+    -r name
+    --role name
+      Sets the role of the user. Valid values are:
+          reader    the user can only read the database.
+          editor    the user can read and modify the database.
+      By default, the user will be a reader.
+
+    -x
+    --delete
+      If set, the user will be removed from the database.
+
+    <name>
+      The name of the user.
+	`,
+}
+
+func init() {
+	jdhUser.Flag.StringVar(&dirFlag, "dir", "", "")
+	jdhUser.Flag.StringVar(&dirFlag, "d", "", "")
+	jdhUser.Flag.StringVar(&roleFlag, "role", server.Reader, "")
+	jdhUser.Flag.StringVar(&roleFlag, "r", server.Reader, "")
+	jdhUser.Flag.BoolVar(&delFlag, "delete", false, "")
+	jdhUser.Flag.BoolVar(&delFlag, "x", false, "")
+	jdhUser.Run = userRun
+}
+
+func userRun(c *cmdapp.Command, args []string) {
+	if len(args) == 0 {
+		ls, err := server.Users(dirFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			os.Exit(1)
+		}
+		for _, u := range ls {
+			fmt.Fprintf(os.Stdout, "%s\t%s\n", u.Name, u.Role)
+		}
+		return
+	}
+	name := strings.Join(args, " ")
+	if delFlag {
+		if err := server.DelUser(dirFlag, name); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+			os.Exit(1)
+		}
+		return
+	}
+	token, err := server.AddUser(dirFlag, name, strings.ToLower(roleFlag))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", c.ErrStr(err))
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", token)
+}
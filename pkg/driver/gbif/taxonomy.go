@@ -31,19 +31,23 @@ type species struct {
 	ParentKey                int64  // parent
 
 	//parents (it seems that gbif knows nothing about arrays)
-	KingdomKey int64
-	PhylumKey  int64
-	ClassKey   int64
-	OrderKey   int64
-	FamilyKey  int64
-	GenusKey   int64
+	KingdomKey  int64
+	PhylumKey   int64
+	ClassKey    int64
+	OrderKey    int64
+	FamilyKey   int64
+	GenusKey    int64
+	SubgenusKey int64
+	SpeciesKey  int64
 
-	Kingdom string
-	Phylum  string
-	Clazz   string
-	Order   string
-	Family  string
-	Genus   string
+	Kingdom  string
+	Phylum   string
+	Clazz    string
+	Order    string
+	Family   string
+	Genus    string
+	Subgenus string
+	Species  string
 }
 
 // returns a copy of species
@@ -91,6 +95,13 @@ func (sp *species) isDesc(p int64) bool {
 	if sp.GenusKey == p {
 		return true
 	}
+	if sp.SubgenusKey == p {
+		return true
+	}
+	// the species key of a species is the species itself.
+	if (sp.SpeciesKey == p) && (sp.Key != p) {
+		return true
+	}
 	return false
 }
 
@@ -117,6 +128,12 @@ func (sp *species) hasParentName(p string) bool {
 	if strings.ToLower(sp.Genus) == p {
 		return true
 	}
+	if strings.ToLower(sp.Subgenus) == p {
+		return true
+	}
+	if (strings.ToLower(sp.Species) == p) && (strings.ToLower(sp.CanonicalName) != p) {
+		return true
+	}
 	return false
 }
 
@@ -27,7 +27,7 @@ func (tx *taxon) copy() *jdh.Taxon {
 	tax := &jdh.Taxon{
 		Id:   strings.TrimSpace(tx.id),
 		Name: strings.Join(strings.Fields(tx.name), " "),
-		Rank: jdh.GetRank(strings.TrimSpace(tx.rank)),
+		Rank: getRank(tx.rank),
 	}
 	return tax
 }
@@ -576,3 +576,19 @@ func (db *DB) txSearch(request string) ([]taxon, bool, error) {
 	}
 	return tx, next, nil
 }
+
+// inatRanks are the inaturalist ranks with a name different from the name
+// used in jdh. Hybrids are ranked as its parents.
+var inatRanks = map[string]jdh.Rank{
+	"genushybrid": jdh.Genus,
+	"hybrid":      jdh.Species,
+}
+
+// getRank returns the rank of an inaturalist rank name.
+func getRank(s string) jdh.Rank {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if r, ok := inatRanks[s]; ok {
+		return r
+	}
+	return jdh.GetRank(s)
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+// Package local implements a driver that opens a native jdh database
+// directly, without a server.
+//
+// The database is locked while it is open, so it can not be used by a
+// server, or another program, at the same time.
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+	"github.com/js-arias/jdh/pkg/native"
+)
+
+const driver = "local"
+
+func init() {
+	jdh.Register(driver, open)
+}
+
+// DB holds the information of the local database.
+type DB struct {
+	db    *native.DB
+	tx    *native.Tx // transaction in progress
+	start time.Time  // time at which the database was opened
+}
+
+// Open opens a database. The parameter is the path of the database. If it
+// is empty, the current directory will be used.
+func open(path string) (jdh.DB, error) {
+	if len(path) == 0 {
+		path = "."
+	}
+	db, err := native.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db: db, start: time.Now()}, nil
+}
+
+// Close closes the database. Any transaction in progress is rolled back,
+// and the operations not yet committed are kept in the journal of the
+// database.
+func (db *DB) Close() error {
+	if db.tx != nil {
+		db.tx.Rollback()
+		db.tx = nil
+	}
+	return db.db.Close()
+}
+
+// Driver returns the driver name.
+func (db *DB) Driver() string {
+	return driver
+}
+
+// editor is a type that modifies the database.
+type editor interface {
+	Add(table jdh.Table, dec *json.Decoder) (string, error)
+	Delete(table jdh.Table, vals []jdh.KeyValue) error
+	Set(table jdh.Table, vals []jdh.KeyValue) error
+}
+
+// Editor returns the transaction in progress, or the database, if there
+// is no transaction.
+func (db *DB) editor() editor {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.db
+}
+
+// Exec executes a query on the database.
+func (db *DB) Exec(query jdh.Query, table jdh.Table, param interface{}) (string, error) {
+	switch query {
+	case jdh.Add:
+		if param == nil {
+			return "", errors.New("empty element")
+		}
+		b, err := json.Marshal(param)
+		if err != nil {
+			return "", err
+		}
+		return db.editor().Add(table, json.NewDecoder(bytes.NewReader(b)))
+	case jdh.Begin:
+		if db.tx != nil {
+			return "", errors.New("transaction already in progress")
+		}
+		db.tx = db.db.Begin("")
+		return db.tx.Id(), nil
+	case jdh.Commit:
+		if tx := db.tx; tx != nil {
+			// the transaction is finished, even if the commit fails.
+			db.tx = nil
+			if err := tx.Commit(); err != nil {
+				tx.Rollback()
+				return "", err
+			}
+		}
+		return "", db.db.Commit()
+	case jdh.Delete, jdh.Set:
+		if param == nil {
+			return "", errors.New("empty argument list")
+		}
+		kvs := param.(*jdh.Values)
+		if len(kvs.KV) == 0 {
+			return "", errors.New("empty argument list")
+		}
+		if query == jdh.Delete {
+			return "", db.editor().Delete(table, kvs.KV)
+		}
+		return "", db.editor().Set(table, kvs.KV)
+	case jdh.Rollback:
+		if db.tx == nil {
+			return "", errors.New("no transaction in progress")
+		}
+		tx := db.tx
+		db.tx = nil
+		return "", tx.Rollback()
+	case jdh.Undo:
+		id := ""
+		if param != nil {
+			for _, kv := range param.(*jdh.Values).KV {
+				if (kv.Key == jdh.KeyId) && (len(kv.Value) > 0) {
+					id = kv.Value[0]
+					break
+				}
+			}
+		}
+		return "", db.db.Undo(id)
+	}
+	return "", errors.New("invalid query")
+}
+
+// Get request a single element from the database.
+func (db *DB) Get(table jdh.Table, id string) (jdh.Scanner, error) {
+	var buf bytes.Buffer
+	if table == jdh.Stats {
+		st := db.db.Status()
+		st.Start = db.start
+		if err := json.NewEncoder(&buf).Encode(st); err != nil {
+			return nil, err
+		}
+	} else if err := db.db.EncodeGet(table, id, &buf); err != nil {
+		return nil, err
+	}
+	return &scanner{d: json.NewDecoder(&buf)}, nil
+}
+
+// List executes a query that returns a list.
+func (db *DB) List(table jdh.Table, args *jdh.Values) (jdh.ListScanner, error) {
+	if args == nil {
+		return nil, errors.New("empty argument list")
+	}
+	var buf bytes.Buffer
+	if _, err := db.db.EncodeList(table, args.KV, &buf); err != nil {
+		return nil, err
+	}
+	return &scanner{d: json.NewDecoder(&buf)}, nil
+}
+
+// Watch returns a list with the events of the changes made in a table of
+// the database. If table is empty, the events of all the tables are
+// returned.
+func (db *DB) Watch(table jdh.Table) (jdh.ListScanner, error) {
+	return &watchScanner{w: db.db.Watch(table)}, nil
+}
+
+// watchScanner scans the events of a watch.
+type watchScanner struct {
+	w *native.Watch
+}
+
+func (s *watchScanner) Scan(dest interface{}) error {
+	e, ok := <-s.w.Events()
+	if !ok {
+		if err := s.w.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	// events are copied as in the native driver, so dest can be of any
+	// type that decodes an event.
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dest)
+}
+
+func (s *watchScanner) Close() {
+	s.w.Close()
+}
+
+// scanner scans the elements of a query. The elements are encoded as in
+// the native protocol, so they are decoded into the destination as in the
+// native driver.
+type scanner struct {
+	d   *json.Decoder
+	err error
+}
+
+func (s *scanner) Scan(dest interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.d.Decode(dest); err != nil {
+		s.err = err
+		return err
+	}
+	return nil
+}
+
+func (s *scanner) Close() {
+	s.err = io.EOF
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package native
+
+import "github.com/js-arias/jdh/pkg/jdh"
+
+// A server can host several databases: the main database, that is used
+// when a request does not define a database, and any number of attached
+// databases, each one identified by its name.
+const (
+	// Attach attaches a database to the server. The arguments are the
+	// name of the database (KeyId), and the path of its directory in the
+	// server host (KeyPath).
+	Attach jdh.Query = "attach"
+
+	// Detach detaches a database from the server. The argument is the
+	// name of the database (KeyId).
+	Detach jdh.Query = "detach"
+)
+
+// Databases is the table with the databases attached to a server. It can
+// only be listed, and its elements are of type Database.
+const Databases jdh.Table = "databases"
+
+// KeyPath is the key used for the path of an attached database.
+const KeyPath jdh.Key = "path"
+
+// Database is a database attached to a server.
+type Database struct {
+	Name string // name of the database
+	Path string // path of the database directory
+}
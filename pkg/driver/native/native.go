@@ -6,10 +6,18 @@
 package native
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/js-arias/jdh/pkg/jdh"
 )
@@ -28,6 +36,14 @@ type Request struct {
 	Query jdh.Query
 	Table jdh.Table
 	Kvs   []jdh.KeyValue
+	Tx    string `json:",omitempty"` // transaction of the request
+	Token string `json:",omitempty"` // token of the user
+	DB    string `json:",omitempty"` // database of the request
+
+	// fields used only in sessions
+	Id   uint64          `json:",omitempty"` // id of the request
+	Elem json.RawMessage `json:",omitempty"` // element of an addition
+	Size int             `json:",omitempty"` // bytes read, in credits
 }
 
 // An Answer is an answer from the database. If the Message field is
@@ -65,11 +81,49 @@ func ErrAnswer(msg string) *Answer {
 
 // DB holds the information of the native database.
 type DB struct {
-	port string
+	port  string
+	tx    string      // transaction in progress
+	token string      // token of the user
+	name  string      // database of the server, if any
+	tls   *tls.Config // tls configuration, if any
+
+	lock  sync.Mutex
+	sess  *session  // session with the server
+	retry time.Time // if the server rejects sessions, time of a new try
 }
 
-// Open creates a new database connection.
+// TokenEnv is the environment variable used to set the token of the user,
+// if the token is not defined in the connection parameter.
+const TokenEnv = "JDH_TOKEN"
+
+// Open creates a new database connection. The connection parameter is of
+// the form "host:port?token=value&db=name", in which db is the name of a
+// database attached to the server. If the database is not defined, the
+// main database of the server will be used. If the host is not defined,
+// the local host will be used. To use a TLS connection the parameter should be of
+// the form "tls://host:port?ca=file", where file is the file with the
+// certificates of the valid certificate authorities. If the ca is not
+// defined, the certificate authorities of the system will be used.
 func open(port string) (jdh.DB, error) {
+	db := &DB{token: os.Getenv(TokenEnv)}
+	isTLS := false
+	if strings.HasPrefix(port, tlsPrefix) {
+		isTLS = true
+		port = port[len(tlsPrefix):]
+	}
+	ca := ""
+	if i := strings.Index(port, "?"); i >= 0 {
+		q, err := url.ParseQuery(port[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		if t := q.Get("token"); len(t) > 0 {
+			db.token = t
+		}
+		ca = q.Get("ca")
+		db.name = q.Get("db")
+		port = port[:i]
+	}
 	if len(port) == 0 {
 		port = Port
 	}
@@ -78,12 +132,50 @@ func open(port string) (jdh.DB, error) {
 	} else if i < 0 {
 		port = "localhost:" + port
 	}
-	return &DB{port}, nil
+	db.port = port
+	if isTLS {
+		host, _, err := net.SplitHostPort(port)
+		if err != nil {
+			return nil, err
+		}
+		db.tls = &tls.Config{ServerName: host}
+		if len(ca) > 0 {
+			pem, err := ioutil.ReadFile(ca)
+			if err != nil {
+				return nil, err
+			}
+			db.tls.RootCAs = x509.NewCertPool()
+			if !db.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, errors.New("invalid certificates in " + ca)
+			}
+		}
+	} else if len(ca) > 0 {
+		return nil, errors.New("certificate authority without a tls connection")
+	}
+	return db, nil
+}
+
+// tlsPrefix is the prefix used for tls connections.
+const tlsPrefix = "tls://"
+
+// dial connects to the database server.
+func (db *DB) dial() (net.Conn, error) {
+	if db.tls != nil {
+		return tls.Dial("tcp", db.port, db.tls)
+	}
+	return net.Dial("tcp", db.port)
 }
 
 // Close closes the database.
 func (db *DB) Close() error {
-	return db.simple(jdh.Close)
+	err := db.simple(jdh.Close)
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.sess != nil {
+		db.sess.close()
+		db.sess = nil
+	}
+	return err
 }
 
 // Driver returns the driver name.
@@ -98,61 +190,40 @@ func (db *DB) Exec(query jdh.Query, table jdh.Table, param interface{}) (string,
 		if param == nil {
 			return "", errors.New("empty element")
 		}
-		conn, err := net.Dial("tcp", db.port)
-		if err != nil {
-			return "", err
-		}
-		defer conn.Close()
-		enc := json.NewEncoder(conn)
 		req := &Request{
 			Query: jdh.Add,
 			Table: table,
+			Tx:    db.tx,
 		}
-		enc.Encode(req)
-		enc.Encode(param)
-		dec := json.NewDecoder(conn)
-		ans := &Answer{}
-		if err := dec.Decode(ans); err != nil {
-			return "", err
-		}
-		if id, err := ans.GetMessage(); err != nil {
-			return "", err
-		} else {
-			return id, nil
-		}
-		return "", nil
-	case jdh.Commit:
-		return "", db.simple(query)
-	case jdh.Delete:
+		return db.ask(req, param)
+	case Attach, Detach:
 		if param == nil {
 			return "", errors.New("empty argument list")
 		}
-		kvs := param.(*jdh.Values)
-		if len(kvs.KV) == 0 {
-			return "", errors.New("empty argument list")
-		}
-		conn, err := net.Dial("tcp", db.port)
-		if err != nil {
-			return "", err
-		}
-		defer conn.Close()
-		enc := json.NewEncoder(conn)
 		req := &Request{
-			Query: jdh.Delete,
-			Table: table,
-			Kvs:   kvs.KV,
+			Query: query,
+			Table: Databases,
+			Kvs:   param.(*jdh.Values).KV,
 		}
-		enc.Encode(req)
-		dec := json.NewDecoder(conn)
-		ans := &Answer{}
-		if err := dec.Decode(ans); err != nil {
+		if _, err := db.ask(req, nil); err != nil {
 			return "", err
 		}
-		if _, err := ans.GetMessage(); err != nil {
+		return "", nil
+	case jdh.Begin:
+		if len(db.tx) > 0 {
+			return "", errors.New("transaction already in progress")
+		}
+		id, err := db.ask(&Request{Query: jdh.Begin}, nil)
+		if err != nil {
 			return "", err
 		}
-		return "", nil
-	case jdh.Set:
+		db.tx = id
+		return id, nil
+	case jdh.Commit, jdh.Rollback:
+		// the transaction is finished, even if the request fails.
+		defer func() { db.tx = "" }()
+		return "", db.simple(query)
+	case jdh.Delete, jdh.Set:
 		if param == nil {
 			return "", errors.New("empty argument list")
 		}
@@ -160,24 +231,25 @@ func (db *DB) Exec(query jdh.Query, table jdh.Table, param interface{}) (string,
 		if len(kvs.KV) == 0 {
 			return "", errors.New("empty argument list")
 		}
-		conn, err := net.Dial("tcp", db.port)
-		if err != nil {
-			return "", err
-		}
-		defer conn.Close()
-		enc := json.NewEncoder(conn)
 		req := &Request{
-			Query: jdh.Set,
+			Query: query,
 			Table: table,
 			Kvs:   kvs.KV,
+			Tx:    db.tx,
 		}
-		enc.Encode(req)
-		dec := json.NewDecoder(conn)
-		ans := &Answer{}
-		if err := dec.Decode(ans); err != nil {
+		if _, err := db.ask(req, nil); err != nil {
 			return "", err
 		}
-		if _, err := ans.GetMessage(); err != nil {
+		return "", nil
+	case jdh.Undo:
+		req := &Request{
+			Query: jdh.Undo,
+			Table: jdh.History,
+		}
+		if param != nil {
+			req.Kvs = param.(*jdh.Values).KV
+		}
+		if _, err := db.ask(req, nil); err != nil {
 			return "", err
 		}
 		return "", nil
@@ -187,26 +259,16 @@ func (db *DB) Exec(query jdh.Query, table jdh.Table, param interface{}) (string,
 
 // Get request a single element from the database.
 func (db *DB) Get(table jdh.Table, id string) (jdh.Scanner, error) {
-	conn, err := net.Dial("tcp", db.port)
-	if err != nil {
-		return nil, err
-	}
-	enc := json.NewEncoder(conn)
 	req := &Request{
 		Query: jdh.Get,
 		Table: table,
 		Kvs:   []jdh.KeyValue{jdh.KeyValue{Key: jdh.KeyId, Value: []string{id}}},
 	}
-	enc.Encode(req)
-	dec := json.NewDecoder(conn)
-	ans := &Answer{}
-	if err := dec.Decode(ans); err != nil {
-		return nil, err
-	}
-	if _, err := ans.GetMessage(); err != nil {
+	r, dec, err := db.answer(req)
+	if err != nil {
 		return nil, err
 	}
-	return &getScanner{c: conn, d: dec}, nil
+	return &getScanner{c: r, d: dec}, nil
 }
 
 // List executes a query that returns a list.
@@ -214,45 +276,99 @@ func (db *DB) List(table jdh.Table, args *jdh.Values) (jdh.ListScanner, error) {
 	if args == nil {
 		return nil, errors.New("empty argument list")
 	}
-	conn, err := net.Dial("tcp", db.port)
-	if err != nil {
-		return nil, err
-	}
-	enc := json.NewEncoder(conn)
 	req := &Request{
 		Query: jdh.List,
 		Table: table,
 		Kvs:   args.KV,
 	}
-	enc.Encode(req)
-	dec := json.NewDecoder(conn)
+	r, dec, err := db.answer(req)
+	if err != nil {
+		return nil, err
+	}
+	return &listScanner{c: r, d: dec}, nil
+}
+
+// Answer sends a request, and reads the answer of the server. It returns
+// the reader of the request, and the decoder positioned at the first
+// element of the answer.
+func (db *DB) answer(req *Request) (io.ReadCloser, *json.Decoder, error) {
+	r, err := db.request(req, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	dec := json.NewDecoder(r)
 	ans := &Answer{}
 	if err := dec.Decode(ans); err != nil {
-		return nil, err
+		r.Close()
+		return nil, nil, err
 	}
 	if _, err := ans.GetMessage(); err != nil {
+		r.Close()
+		return nil, nil, err
+	}
+	return r, dec, nil
+}
+
+// Ask sends a request, and returns the message of the answer.
+func (db *DB) ask(req *Request, elem interface{}) (string, error) {
+	r, err := db.request(req, elem)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	ans := &Answer{}
+	if err := json.NewDecoder(r).Decode(ans); err != nil {
+		return "", err
+	}
+	return ans.GetMessage()
+}
+
+// Request sends a request to the server, using the session with the
+// server if possible, and returns the reader of the answer. If elem is not
+// nil, it is sent as the element of the request.
+func (db *DB) request(req *Request, elem interface{}) (io.ReadCloser, error) {
+	req.Token = db.token
+	req.DB = db.name
+	s, err := db.session()
+	if err != nil {
 		return nil, err
 	}
-	return &listScanner{c: conn, d: dec}, nil
+	if s == nil {
+		return db.single(req, elem)
+	}
+	if elem != nil {
+		b, err := json.Marshal(elem)
+		if err != nil {
+			return nil, err
+		}
+		req.Elem = b
+	}
+	return s.send(req)
 }
 
-// sends a simple request
-func (db *DB) simple(query jdh.Query) error {
-	conn, err := net.Dial("tcp", db.port)
+// Single sends a request in its own connection, as in the original
+// protocol.
+func (db *DB) single(req *Request, elem interface{}) (io.ReadCloser, error) {
+	conn, err := db.dial()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer conn.Close()
 	enc := json.NewEncoder(conn)
-	req := &Request{Query: query}
-	enc.Encode(req)
-	dec := json.NewDecoder(conn)
-	ans := &Answer{}
-	if err := dec.Decode(ans); err != nil {
-		return err
+	if err := enc.Encode(req); err != nil {
+		conn.Close()
+		return nil, err
 	}
-	if _, err := ans.GetMessage(); err != nil {
-		return err
+	if elem != nil {
+		if err := enc.Encode(elem); err != nil {
+			conn.Close()
+			return nil, err
+		}
 	}
-	return nil
+	return conn, nil
+}
+
+// sends a simple request, as part of the transaction in progress
+func (db *DB) simple(query jdh.Query) error {
+	_, err := db.ask(&Request{Query: query, Tx: db.tx}, nil)
+	return err
 }
@@ -7,12 +7,11 @@ package native
 import (
 	"encoding/json"
 	"io"
-	"net"
 )
 
 // GetScanner scans a single value.
 type getScanner struct {
-	c   net.Conn
+	c   io.Closer
 	d   *json.Decoder
 	err error
 }
@@ -32,7 +31,7 @@ func (g *getScanner) Scan(dest interface{}) error {
 
 // ListScanner scans a list of values.
 type listScanner struct {
-	c   net.Conn
+	c   io.Closer
 	d   *json.Decoder
 	err error
 }
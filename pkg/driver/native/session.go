@@ -0,0 +1,297 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package native
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// Session is the query used to start a session. In the original protocol,
+// each request is sent in its own connection, that is closed by the server
+// after the answer. In a session, the connection is kept open, and each
+// request carries an id. The answers of the requests are sent in frames,
+// so several requests can be answered at the same time. Servers that do
+// not accept sessions answer the query with an error, and the client
+// should use the original protocol.
+const Session jdh.Query = "session"
+
+// Credit is the query used in a session to tell the server that the
+// client has read Size bytes of the answer of the request Id, so the
+// server can send more data of the answer. A credit with a Size of 0
+// tells the server that the client has discarded the answer.
+const Credit jdh.Query = "credit"
+
+// Window is the number of bytes of an answer that the server sends in a
+// session before it waits for a credit of the client.
+const Window = 1 << 20
+
+// sessionRetry is the time before a new session is tried, if the server
+// rejects a session.
+const sessionRetry = time.Minute
+
+// A Frame is the header of a part of an answer in a session. The header is
+// sent in a single line, and it is followed by Size bytes of the answer,
+// that are formatted exactly as in the original protocol.
+type Frame struct {
+	Id   uint64 // id of the request
+	Size int    `json:",omitempty"` // size of the part
+	End  bool   `json:",omitempty"` // true in the last part of the answer
+}
+
+// session is a connection with the server in which several requests are
+// sent.
+type session struct {
+	conn  net.Conn
+	enc   *json.Encoder
+	wlock sync.Mutex // lock of the writes
+
+	lock    sync.Mutex // lock of the streams
+	streams map[uint64]*stream
+	next    uint64 // next valid id
+	err     error  // error of the connection, if any
+}
+
+// Session returns the session with the server, starting a new one if
+// there is no session, or the previous one is broken. If the server does
+// not accept sessions, it returns nil, and no session is tried again
+// until sessionRetry is elapsed.
+func (db *DB) session() (*session, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if time.Now().Before(db.retry) {
+		return nil, nil
+	}
+	if db.sess != nil {
+		if db.sess.broken() == nil {
+			return db.sess, nil
+		}
+		db.sess = nil
+	}
+	conn, err := db.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(conn).Encode(&Request{Query: Session, Token: db.token}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// the answer is read with the same reader used for the frames, so no
+	// data of the session is lost.
+	r := bufio.NewReader(conn)
+	b, err := r.ReadBytes('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ans := &Answer{}
+	if err := json.Unmarshal(b, ans); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := ans.GetMessage(); err != nil {
+		conn.Close()
+		db.retry = time.Now().Add(sessionRetry)
+		return nil, nil
+	}
+	db.sess = &session{
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		streams: make(map[uint64]*stream),
+		next:    1,
+	}
+	go db.sess.read(r)
+	return db.sess, nil
+}
+
+// Send sends a request, and returns the stream with its answer.
+func (s *session) send(req *Request) (*stream, error) {
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return nil, s.err
+	}
+	st := &stream{s: s, id: s.next}
+	st.cond = sync.NewCond(&st.lock)
+	s.next++
+	s.streams[st.id] = st
+	s.lock.Unlock()
+
+	req.Id = st.id
+	s.wlock.Lock()
+	err := s.enc.Encode(req)
+	s.wlock.Unlock()
+	if err != nil {
+		s.fail(err)
+		return nil, err
+	}
+	return st, nil
+}
+
+// Read reads the frames sent by the server, and sends its content to the
+// stream of each request.
+func (s *session) read(r *bufio.Reader) {
+	for {
+		b, err := r.ReadBytes('\n')
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		f := &Frame{}
+		if err := json.Unmarshal(b, f); err != nil {
+			s.fail(err)
+			return
+		}
+		var data []byte
+		if f.Size > 0 {
+			data = make([]byte, f.Size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				s.fail(err)
+				return
+			}
+		}
+		s.lock.Lock()
+		st := s.streams[f.Id]
+		if f.End {
+			delete(s.streams, f.Id)
+		}
+		s.lock.Unlock()
+
+		// the stream was closed before the end of the answer.
+		if st == nil {
+			continue
+		}
+		st.push(data, f.End)
+	}
+}
+
+// Fail marks the session as broken, and finishes all the streams in
+// progress.
+func (s *session) fail(err error) {
+	if err == io.EOF {
+		err = errors.New("session closed by the server")
+	}
+	s.lock.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	streams := s.streams
+	s.streams = make(map[uint64]*stream)
+	s.lock.Unlock()
+	s.conn.Close()
+	for _, st := range streams {
+		st.fail(err)
+	}
+}
+
+// Broken returns the error of a broken session.
+func (s *session) broken() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// Close closes the session.
+func (s *session) close() {
+	s.fail(errors.New("session closed"))
+}
+
+// Credit sends a credit of an answer to the server.
+func (s *session) credit(id uint64, n int) {
+	s.wlock.Lock()
+	err := s.enc.Encode(&Request{Query: Credit, Id: id, Size: n})
+	s.wlock.Unlock()
+	if err != nil {
+		s.fail(err)
+	}
+}
+
+// stream is the answer of a request in a session. As the server waits for
+// the credits of the client, the buffer of the stream never holds much
+// more than a Window of data.
+type stream struct {
+	s    *session
+	id   uint64
+	lock sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	read int   // bytes read that are not credited
+	err  error // io.EOF at the end of the answer
+}
+
+// Push adds data to the stream.
+func (st *stream) push(data []byte, end bool) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.buf.Write(data)
+	if end && (st.err == nil) {
+		st.err = io.EOF
+	}
+	st.cond.Broadcast()
+}
+
+// Fail finishes the stream with an error.
+func (st *stream) fail(err error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.err == nil {
+		st.err = err
+	}
+	st.cond.Broadcast()
+}
+
+// Read reads the answer, waiting until there is data available, or the
+// answer is finished. When half of the window is read, a credit is sent
+// to the server.
+func (st *stream) Read(p []byte) (int, error) {
+	st.lock.Lock()
+	for (st.buf.Len() == 0) && (st.err == nil) {
+		st.cond.Wait()
+	}
+	if st.buf.Len() == 0 {
+		err := st.err
+		st.lock.Unlock()
+		return 0, err
+	}
+	n, _ := st.buf.Read(p)
+	st.read += n
+	credit := 0
+	if (st.read >= Window/2) && (st.err == nil) {
+		credit = st.read
+		st.read = 0
+	}
+	st.lock.Unlock()
+	if credit > 0 {
+		st.s.credit(st.id, credit)
+	}
+	return n, nil
+}
+
+// Close closes the stream. Any remaining part of the answer will be
+// discarded.
+func (st *stream) Close() error {
+	st.s.lock.Lock()
+	delete(st.s.streams, st.id)
+	st.s.lock.Unlock()
+	st.lock.Lock()
+	finished := st.err != nil
+	st.lock.Unlock()
+	if !finished && (st.s.broken() == nil) {
+		st.s.credit(st.id, 0)
+	}
+	st.fail(io.EOF)
+	st.lock.Lock()
+	st.buf.Reset()
+	st.lock.Unlock()
+	return nil
+}
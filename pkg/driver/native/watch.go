@@ -0,0 +1,47 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package native
+
+import (
+	"encoding/json"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// Watch is the query used to receive the changes of a database. The
+// server answers the query, and then keeps the connection open, sending
+// an Event (of package jdh) each time an element of the table of the
+// request (or any table, if the table is empty) is modified. As the
+// answer never ends, the query must be sent in its own connection, and
+// not as part of a session. The watch is finished when the client closes
+// the connection.
+const Watch jdh.Query = "watch"
+
+// Watch returns a list with the events of the changes made in a table of
+// the database. If table is empty, the events of all the tables are
+// returned.
+func (db *DB) Watch(table jdh.Table) (jdh.ListScanner, error) {
+	req := &Request{
+		Query: Watch,
+		Table: table,
+		Token: db.token,
+		DB:    db.name,
+	}
+	r, err := db.single(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(r)
+	ans := &Answer{}
+	if err := dec.Decode(ans); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if _, err := ans.GetMessage(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &listScanner{c: r, d: dec}, nil
+}
@@ -496,7 +496,7 @@ func readTaxon(id string) (*taxon, error) {
 					case "parentTaxId":
 						tx.tax.Parent = at.Value
 					case "rank":
-						tx.tax.Rank = jdh.GetRank(at.Value)
+						tx.tax.Rank = getRank(at.Value)
 					}
 				}
 			case "lineage":
@@ -611,3 +611,19 @@ func readTaxonLineage(dec *xml.Decoder, tx *taxon) error {
 		}
 	}
 }
+
+// ncbiRanks are the ncbi ranks with a name different from the name used
+// in jdh.
+var ncbiRanks = map[string]jdh.Rank{
+	"varietas": jdh.Variety,
+	"forma":    jdh.Form,
+}
+
+// getRank returns the rank of a ncbi rank name.
+func getRank(s string) jdh.Rank {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if r, ok := ncbiRanks[s]; ok {
+		return r
+	}
+	return jdh.GetRank(s)
+}
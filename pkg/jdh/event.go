@@ -0,0 +1,34 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package jdh
+
+// Event is a notification of a change in an element of the database.
+type Event struct {
+	// table of the modified element.
+	Table Table
+
+	// identifier of the modified element.
+	Id string
+
+	// query that modified the element. If the change was reverted, it
+	// is Undo.
+	Query Query
+}
+
+// Watcher is implemented by databases that notify their changes. It is
+// an optional interface, a client should check if the database
+// implements it.
+type Watcher interface {
+	// Watch returns a list with the events of the changes made in a
+	// table of the database, after the call to Watch. The list is
+	// read until it is closed. If table is empty, the events of all
+	// the tables are returned.
+	//
+	// Events are only sent for finished changes, i.e. queries outside
+	// a transaction, and committed transactions. Slow readers can
+	// lose the watch, in that case the list is finished with an
+	// error.
+	Watch(table Table) (ListScanner, error)
+}
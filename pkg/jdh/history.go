@@ -0,0 +1,38 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package jdh
+
+import "time"
+
+// Change is a modification of the database, as stored in the history of
+// the database. A change is made by a single query, or by all the queries
+// of a transaction.
+type Change struct {
+	// identifier of the change.
+	Id string
+
+	// the time and date of the change.
+	Date time.Time
+
+	// queries of the change, in the order in which they were made.
+	Queries []ChangeQuery
+}
+
+// ChangeQuery is a query that modified the database.
+type ChangeQuery struct {
+	// query made.
+	Query Query
+
+	// table modified by the query.
+	Table Table
+
+	// arguments of the query. In Add queries, it is the id assigned to
+	// the added element.
+	Kvs []KeyValue
+}
+
+// History is the table that store the most recent changes of the
+// database, from the oldest to the newest.
+const History Table = "history"
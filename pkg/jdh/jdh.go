@@ -57,14 +57,16 @@ type DB interface {
 	// Exec executes a query without returning any row (e.g. Add, Delete),
 	// usually this executions require modification of the database data.
 	// It returns a string result (that depends on the query), or an
-	// error, if the query fail.
+	// error, if the query fail. On a Begin query, the result is the id
+	// of the new transaction.
 	Exec(query Query, table Table, param interface{}) (string, error)
 
 	// Get executes a query that is expected to return at most a single
 	// value.
 	Get(table Table, id string) (Scanner, error)
 
-	// List executes a query that returns a list.
+	// List executes a query that returns a list. The keys KeyOffset
+	// and KeyLimit can be used to retrieve only a part of the list.
 	List(table Table, args *Values) (ListScanner, error)
 }
 
@@ -101,7 +103,19 @@ const (
 	// parameter is the element to be added.
 	Add Query = "add"
 
-	// Commit requests the commit of the database.
+	// Begin requests the start of a transaction. Add, Delete and Set
+	// queries done after a Begin are part of the transaction, until the
+	// transaction is finished with a Commit or a Rollback. All the
+	// queries of a transaction are applied, or none of them. Transactions
+	// are not isolated: the queries of a transaction are seen by other
+	// clients of the database as soon as they are applied, and they are
+	// only reverted if the transaction is rolled back. The result is the
+	// id of the transaction, that can only be used by the same user.
+	Begin = "begin"
+
+	// Commit requests the commit of the database. If a transaction is in
+	// progress, it finishes the transaction keeping all of its queries,
+	// and then commits the database.
 	Commit = "commit"
 
 	// Close request the closing of the database.
@@ -118,10 +132,19 @@ const (
 	// List request a list of elements from the database.
 	List = "list"
 
+	// Rollback finishes a transaction, reverting all of its queries.
+	Rollback = "rollback"
+
 	// Set requests the setting of a value in the database. The param
 	// argument is a Values variable that must be included the id, all
 	// other values will be modified if they are valid.
 	Set = "set"
+
+	// Undo requests the reversion of a change stored in the History
+	// table. The param argument is a Values variable with the id of the
+	// change, the change and all the changes made after it will be
+	// reverted. If no id is given, the last change will be reverted.
+	Undo = "undo"
 )
 
 // Table is a request parameter that indicate the "table" in whinch an
@@ -216,6 +239,15 @@ const (
 
 	// A bibliographic reference.
 	KeyReference = "reference"
+
+	// Number of elements to be skipped at the start of a list query.
+	// Together with KeyLimit it can be used to retrieve a long list in
+	// pages.
+	KeyOffset = "offset"
+
+	// Maximum number of elements returned by a list query. If it is not
+	// defined, or it is 0, all the elements will be returned.
+	KeyLimit = "limit"
 )
 
 // ParseExtern parses an extern identifier. Extern identifiers are of the
@@ -0,0 +1,44 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package jdh
+
+import "time"
+
+// Status is the status of a database server.
+type Status struct {
+	// time at which the server was started.
+	Start time.Time
+
+	// time of the last commit of the database.
+	Commit time.Time
+
+	// status of each table of the database.
+	Tables []TableStatus
+
+	// number of transactions in progress.
+	Txs int
+
+	// number of requests answered by the server, by query.
+	Requests map[Query]int64
+
+	// number of requests answered with an error.
+	Errors int64
+}
+
+// TableStatus is the status of a table of the database.
+type TableStatus struct {
+	// the table.
+	Table Table
+
+	// number of elements in the table.
+	Count int
+
+	// true if the table has changes that are not yet committed.
+	Changed bool
+}
+
+// Stats is the table used to retrieve the status of the database. A get
+// query on this table (the id is ignored) returns a Status value.
+const Stats Table = "stats"
@@ -0,0 +1,51 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package jdh
+
+import "time"
+
+// TaxAct is a nomenclatural act on a taxon, i.e. a change of the parent or
+// the validity of the taxon (for example, when a taxon is set as a synonym,
+// or moved to another genus). Acts are recorded by the database each time
+// the parent or the validity of a taxon is set, so the previous placements
+// of the taxon are kept.
+type TaxAct struct {
+	// identifier of the act.
+	Id string
+
+	// id of the taxon.
+	Taxon string
+
+	// the time and date of the act.
+	Date time.Time
+
+	// bibliographic reference of the act.
+	Reference string
+
+	// id of the parent of the taxon before the act.
+	OldParent string
+
+	// id of the parent of the taxon after the act.
+	NewParent string
+
+	// true if the taxon was valid before the act.
+	OldValid bool
+
+	// true if the taxon is valid after the act.
+	NewValid bool
+}
+
+// TaxActs is the table that store the nomenclatural acts of the taxons.
+// In set operations of the Taxonomy table, the KeyReference key can be
+// used to define the reference of the act.
+const TaxActs Table = "taxacts"
+
+// Key values used in TaxActs table.
+const (
+	// Used in list operation to retrieve the acts of a taxon, ordered
+	// by its date. In delete operation, delete all the acts of the
+	// indicated taxon. The value is the id of the taxon.
+	ActTaxon Key = "taxon"
+)
@@ -5,6 +5,8 @@
 package jdh
 
 import (
+	"encoding/json"
+	"errors"
 	"strings"
 )
 
@@ -42,32 +44,76 @@ type Taxon struct {
 //     if rank < jdh.Genus {
 //         // do something
 //     }
+//
+// As new ranks can be added in between, ranks are encoded in JSON by its
+// name. Numeric values of old database files, that only used the main
+// ranks (from unranked to species), are also accepted.
 type Rank uint
 
 // Valid taxonomic ranks.
 const (
 	Unranked Rank = iota
 	Kingdom
+	Subkingdom
 	Phylum
+	Subphylum
+	Superclass
 	Class
+	Subclass
+	Infraclass
+	Superorder
 	Order
+	Suborder
+	Infraorder
+	Superfamily
 	Family
+	Subfamily
+	Tribe
+	Subtribe
 	Genus
+	Subgenus
+	Section
+	Series
 	Species
+	Subspecies
+	Variety
+	Form
 )
 
 // Ranks holds a list of the ranks accepted in jdh.
 var ranks = []string{
 	"unranked",
 	"kingdom",
+	"subkingdom",
 	"phylum",
+	"subphylum",
+	"superclass",
 	"class",
+	"subclass",
+	"infraclass",
+	"superorder",
 	"order",
+	"suborder",
+	"infraorder",
+	"superfamily",
 	"family",
+	"subfamily",
+	"tribe",
+	"subtribe",
 	"genus",
+	"subgenus",
+	"section",
+	"series",
 	"species",
+	"subspecies",
+	"variety",
+	"form",
 }
 
+// oldRanks are the ranks of the numeric values used before intermediate
+// ranks were added.
+var oldRanks = []Rank{Unranked, Kingdom, Phylum, Class, Order, Family, Genus, Species}
+
 // GetRank returns a rank id from a string.
 func GetRank(s string) Rank {
 	s = strings.ToLower(s)
@@ -88,6 +134,35 @@ func (r Rank) String() string {
 	return ranks[i]
 }
 
+// IsValid returns true if the rank is a rank accepted in jdh.
+func (r Rank) IsValid() bool {
+	return int(r) < len(ranks)
+}
+
+// MarshalJSON encodes a rank by its name.
+func (r Rank) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON decodes a rank from its name, or from the numeric value
+// used in old database files.
+func (r *Rank) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*r = GetRank(strings.TrimSpace(s))
+		return nil
+	}
+	var v uint
+	if err := json.Unmarshal(b, &v); err != nil {
+		return errors.New("invalid rank: " + string(b))
+	}
+	*r = Unranked
+	if v < uint(len(oldRanks)) {
+		*r = oldRanks[v]
+	}
+	return nil
+}
+
 // Taxonomy is the table that store taxon information.
 const Taxonomy Table = "taxonomy"
 
@@ -122,6 +197,11 @@ const (
 	// rank accepted in jdh.
 	TaxRank = "rank"
 
+	// Used in list operation to retrieve taxons with a name similar
+	// to the given name (e.g. a misspelling of the name), ordered by
+	// its similarity. It accepts the same filters of the name key.
+	TaxSimilar = "similar"
+
 	// Set a taxon as a synonym of the taxon id indicated in value.
 	// Only used during set opertation. If the value is empty, it will
 	// be assumed that the parent of the taxon its is new senior
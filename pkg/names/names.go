@@ -0,0 +1,472 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package names implements a parser of botanical and zoological
+// scientific names.
+//
+// A name is read as an uninomial, or a genus followed by an optional
+// infrageneric epithet, a specific epithet, and an optional infraspecific
+// epithet (with or without a rank marker), and the authorship of the name.
+// For example:
+//
+//	Carabidae Latreille, 1802
+//	Carabus (Tachypus) cancellatus Illiger, 1798
+//	Puma concolor couguar (Kerr, 1792)
+//	Abies alba subsp. apennina Brullo, Scelsi & Spamp.
+//	Quercus sect. Lobatae Loudon
+//	Solanum lycopersicum var. cerasiforme (Dunal) D.M.Spooner et al.
+//
+// Names in open nomenclature, with a qualifier ("sp.", "cf." or "aff."),
+// are also accepted. The qualifier and its filler (e.g. the number of an
+// undescribed species, or the compared species) are part of the name:
+//
+//	Carabus sp. 1
+//	Puma cf. concolor
+package names
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// Name is a parsed scientific name.
+type Name struct {
+	// genus of the name, or the uninomial of a name above the genus.
+	// The genus of a nothogenus starts with the hybrid sign (×).
+	Genus string
+
+	// infrageneric epithet (e.g. a subgenus or a section).
+	Infrageneric string
+
+	// rank marker of the infrageneric epithet (e.g. "subg." or "sect.").
+	// Zoological subgenera, written between parenthesis, are unmarked.
+	InfragenericMarker string
+
+	// specific epithet. The epithet of a nothospecies starts with the
+	// hybrid sign (×). In names with a "sp." qualifier, it is the
+	// filler of the qualifier (e.g. "1" in "Aus sp. 1"), if any.
+	Specific string
+
+	// qualifier of a name in open nomenclature ("sp.", "cf." or
+	// "aff.").
+	Qualifier string
+
+	// infraspecific epithet.
+	Infraspecific string
+
+	// rank marker of the infraspecific epithet (e.g. "subsp." or "var.").
+	// Zoological subspecies are unmarked.
+	InfraspecificMarker string
+
+	// authors of the name, without the parenthetical authors. In
+	// botanical names it includes the "ex" authors.
+	Authors string
+
+	// year of the name.
+	Year string
+
+	// parenthetical authors of the name (i.e. the authors of the
+	// basionym, or the original combination).
+	ParAuthors string
+
+	// year of the parenthetical authors.
+	ParYear string
+}
+
+// infragenericMarkers are the rank markers accepted for infrageneric
+// epithets.
+var infragenericMarkers = map[string]jdh.Rank{
+	"subg.":    jdh.Subgenus,
+	"subgen.":  jdh.Subgenus,
+	"sect.":    jdh.Section,
+	"ser.":     jdh.Series,
+	"subsect.": jdh.Section,
+	"subser.":  jdh.Series,
+}
+
+// infraspecificMarkers are the rank markers accepted for infraspecific
+// epithets.
+var infraspecificMarkers = map[string]jdh.Rank{
+	"subsp.":    jdh.Subspecies,
+	"ssp.":      jdh.Subspecies,
+	"var.":      jdh.Variety,
+	"subvar.":   jdh.Variety,
+	"f.":        jdh.Form,
+	"fo.":       jdh.Form,
+	"forma":     jdh.Form,
+	"subf.":     jdh.Form,
+	"morph":     jdh.Form,
+	"nothosp.":  jdh.Subspecies,
+	"nothovar.": jdh.Variety,
+}
+
+// qualifiers are the qualifiers of open nomenclature, and its canonical
+// form.
+var qualifiers = map[string]string{
+	"sp.":  "sp.",
+	"sp":   "sp.",
+	"cf.":  "cf.",
+	"cf":   "cf.",
+	"aff.": "aff.",
+	"aff":  "aff.",
+}
+
+// particles are lower case words that start the name of an author (e.g.
+// "de Candolle").
+var particles = map[string]bool{
+	"d'":    true,
+	"da":    true,
+	"de":    true,
+	"del":   true,
+	"della": true,
+	"der":   true,
+	"des":   true,
+	"di":    true,
+	"du":    true,
+	"la":    true,
+	"le":    true,
+	"van":   true,
+	"von":   true,
+	"zur":   true,
+}
+
+// Parse parses a scientific name. Underscores, as used in many phylogenetic
+// programs, are read as spaces.
+func Parse(s string) (*Name, error) {
+	if !balanced(s) {
+		return nil, errors.New("invalid name " + s + ": unbalanced parenthesis")
+	}
+	tk := tokens(strings.Replace(s, "_", " ", -1))
+	if len(tk) == 0 {
+		return nil, errors.New("empty name")
+	}
+	// hybrid sign of nothogenera.
+	hybrid := ""
+	if (tk[0] == "×") || (tk[0] == "x") {
+		hybrid = "×"
+		tk = tk[1:]
+		if len(tk) == 0 {
+			return nil, errors.New("empty name")
+		}
+	} else if strings.HasPrefix(tk[0], "×") {
+		hybrid = "×"
+		tk[0] = tk[0][len("×"):]
+	}
+	if !isCapitalized(tk[0]) {
+		return nil, errors.New("invalid name " + s + ": it must start with a capitalized word")
+	}
+	n := &Name{Genus: hybrid + tk[0]}
+	tk = tk[1:]
+
+	// infrageneric epithet
+	if len(tk) > 0 {
+		if in := inParen(tk[0]); isCapitalized(in) {
+			n.Infrageneric = in
+			tk = tk[1:]
+		} else if _, ok := infragenericMarkers[strings.ToLower(tk[0])]; ok && (len(tk) > 1) && isCapitalized(tk[1]) {
+			n.InfragenericMarker = strings.ToLower(tk[0])
+			n.Infrageneric = tk[1]
+			tk = tk[2:]
+		}
+	}
+
+	// qualifier of open nomenclature
+	if len(tk) > 0 {
+		if q, ok := qualifiers[strings.ToLower(tk[0])]; ok {
+			n.Qualifier = q
+			tk = tk[1:]
+			// the filler of "sp." is anything but an author (e.g.
+			// a number, or a letter).
+			if (q == "sp.") && (len(tk) > 0) && !isCapitalized(tk[0]) && (len(inParen(tk[0])) == 0) {
+				n.Specific = tk[0]
+				tk = tk[1:]
+			}
+		}
+	}
+
+	// specific epithet, the hybrid sign of a nothospecies can be
+	// separated from the epithet.
+	if (n.Qualifier != "sp.") && (len(tk) > 0) {
+		if ((tk[0] == "×") || (tk[0] == "x")) && (len(tk) > 1) && isEpithet(tk[1:]) {
+			n.Specific = "×" + strings.TrimPrefix(tk[1], "×")
+			tk = tk[2:]
+		} else if isEpithet(tk) {
+			n.Specific = tk[0]
+			tk = tk[1:]
+		}
+	}
+
+	// infraspecific epithet
+	if (n.Qualifier != "sp.") && (len(n.Specific) > 0) && (len(tk) > 0) {
+		if _, ok := infraspecificMarkers[strings.ToLower(tk[0])]; ok && (len(tk) > 1) && isEpithet(tk[1:]) {
+			n.InfraspecificMarker = strings.ToLower(tk[0])
+			n.Infraspecific = tk[1]
+			tk = tk[2:]
+		} else if isEpithet(tk) {
+			n.Infraspecific = tk[0]
+			tk = tk[1:]
+		}
+	}
+
+	// a hybrid sign between names is a hybrid formula (e.g. "Aus bus ×
+	// cus"), that is not a name.
+	if (len(tk) > 0) && ((tk[0] == "×") || (tk[0] == "x")) {
+		return nil, errors.New("invalid name " + s + ": hybrid formulas are not accepted")
+	}
+
+	// authorship
+	if (len(tk) > 0) && (len(inParen(tk[0])) > 0) {
+		n.ParAuthors, n.ParYear = authorship(inParen(tk[0]))
+		tk = tk[1:]
+	}
+	if len(tk) > 0 {
+		n.Authors, n.Year = authorship(strings.Join(tk, " "))
+	}
+	return n, nil
+}
+
+// Tokens splits a string in words, keeping together the words between
+// parenthesis.
+func tokens(s string) []string {
+	var tk []string
+	depth := 0
+	var w []rune
+	for _, r := range s {
+		switch {
+		case r == '(':
+			if (depth == 0) && (len(w) > 0) {
+				tk = append(tk, string(w))
+				w = nil
+			}
+			depth++
+		case r == ')':
+			if depth > 0 {
+				depth--
+			}
+		case unicode.IsSpace(r):
+			if depth == 0 {
+				if len(w) > 0 {
+					tk = append(tk, string(w))
+					w = nil
+				}
+				continue
+			}
+			if (len(w) == 0) || (w[len(w)-1] == ' ') {
+				continue
+			}
+			r = ' '
+		}
+		w = append(w, r)
+		if (r == ')') && (depth == 0) {
+			tk = append(tk, string(w))
+			w = nil
+		}
+	}
+	if len(w) > 0 {
+		tk = append(tk, string(w))
+	}
+	return tk
+}
+
+// Balanced returns true if the parenthesis of a string are balanced.
+func balanced(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return false
+			}
+			depth--
+		}
+	}
+	return depth == 0
+}
+
+// InParen returns the content of a token between parenthesis. If the token
+// is not between parenthesis, it returns an empty string.
+func inParen(tk string) string {
+	if !strings.HasPrefix(tk, "(") || !strings.HasSuffix(tk, ")") {
+		return ""
+	}
+	return strings.TrimSpace(tk[1 : len(tk)-1])
+}
+
+// IsCapitalized returns true if a word is a capitalized latin word, as in
+// a genus or a name above the genus.
+func isCapitalized(w string) bool {
+	for i, r := range w {
+		if i == 0 {
+			if !unicode.IsUpper(r) {
+				return false
+			}
+			continue
+		}
+		if !unicode.IsLower(r) && (r != '-') {
+			return false
+		}
+	}
+	return len(w) > 1
+}
+
+// IsEpithet returns true if the first token is a (lower case) specific or
+// infraspecific epithet, and not the start of an author's name.
+func isEpithet(tk []string) bool {
+	w := tk[0]
+	if particles[w] && (len(tk) > 1) && !isEpithet(tk[1:]) {
+		return false
+	}
+	if _, ok := infraspecificMarkers[w]; ok {
+		return false
+	}
+	if strings.HasPrefix(w, "×") {
+		w = w[len("×"):]
+	}
+	for _, r := range w {
+		if !unicode.IsLower(r) && (r != '-') {
+			return false
+		}
+	}
+	return len(w) > 1
+}
+
+// Authorship splits an authorship string in the authors and the year.
+func authorship(s string) (authors, year string) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndexAny(s, ", ")
+	if i < 0 {
+		if isYear(s) {
+			return "", strings.Trim(s, "[]")
+		}
+		return s, ""
+	}
+	if y := s[i+1:]; isYear(y) {
+		return strings.TrimRight(strings.TrimSpace(s[:i]), ","), strings.Trim(y, "[]")
+	}
+	return s, ""
+}
+
+// IsYear returns true if a word is a year, optionally between brackets
+// (e.g. "[1802]") or with a letter suffix (e.g. "1802a").
+func isYear(w string) bool {
+	w = strings.Trim(w, "[]")
+	if (len(w) > 0) && unicode.IsLower(rune(w[len(w)-1])) {
+		w = w[:len(w)-1]
+	}
+	if len(w) != 4 {
+		return false
+	}
+	for _, r := range w {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rank returns the rank of the name that is deduced from its parts. Names
+// above the genus are ranked by its standard endings, and unranked if the
+// ending is not distinctive. As the zoological subfamily, and the
+// botanical subtribe, share the same ending (-inae), it is read as a
+// subfamily.
+func (n *Name) Rank() jdh.Rank {
+	if len(n.Infraspecific) > 0 {
+		if r, ok := infraspecificMarkers[n.InfraspecificMarker]; ok {
+			return r
+		}
+		return jdh.Subspecies
+	}
+	if (len(n.Specific) > 0) || (len(n.Qualifier) > 0) {
+		return jdh.Species
+	}
+	if len(n.Infrageneric) > 0 {
+		if r, ok := infragenericMarkers[n.InfragenericMarker]; ok {
+			return r
+		}
+		return jdh.Subgenus
+	}
+	g := n.Genus
+	switch {
+	case strings.HasSuffix(g, "oidea"):
+		return jdh.Superfamily
+	case strings.HasSuffix(g, "idae"), strings.HasSuffix(g, "aceae"):
+		return jdh.Family
+	case strings.HasSuffix(g, "oideae"), strings.HasSuffix(g, "inae"):
+		return jdh.Subfamily
+	case strings.HasSuffix(g, "ineae"):
+		return jdh.Suborder
+	case strings.HasSuffix(g, "eae"), strings.HasSuffix(g, "ini"):
+		return jdh.Tribe
+	case strings.HasSuffix(g, "ales"):
+		return jdh.Order
+	}
+	return jdh.Unranked
+}
+
+// Canonical returns the name without the authorship. The infrageneric
+// epithet is only included in the names of infrageneric taxa.
+func (n *Name) Canonical() string {
+	nm := n.Genus
+	if len(n.Qualifier) > 0 {
+		nm += " " + n.Qualifier
+		if len(n.Specific) == 0 {
+			return nm
+		}
+	}
+	if len(n.Specific) == 0 {
+		if len(n.Infrageneric) == 0 {
+			return nm
+		}
+		if len(n.InfragenericMarker) == 0 {
+			return nm + " (" + n.Infrageneric + ")"
+		}
+		return nm + " " + n.InfragenericMarker + " " + n.Infrageneric
+	}
+	nm += " " + n.Specific
+	if len(n.Infraspecific) == 0 {
+		return nm
+	}
+	if len(n.InfraspecificMarker) > 0 {
+		nm += " " + n.InfraspecificMarker
+	}
+	return nm + " " + n.Infraspecific
+}
+
+// Authority returns the authority citation of the name.
+func (n *Name) Authority() string {
+	a := citation(n.Authors, n.Year)
+	if len(n.ParAuthors) == 0 && len(n.ParYear) == 0 {
+		return a
+	}
+	p := "(" + citation(n.ParAuthors, n.ParYear) + ")"
+	if len(a) == 0 {
+		return p
+	}
+	return p + " " + a
+}
+
+// Citation returns the citation of an author and a year.
+func citation(authors, year string) string {
+	if len(year) == 0 {
+		return authors
+	}
+	if len(authors) == 0 {
+		return year
+	}
+	return authors + ", " + year
+}
+
+// String returns the name with its authority citation.
+func (n *Name) String() string {
+	a := n.Authority()
+	if len(a) == 0 {
+		return n.Canonical()
+	}
+	return n.Canonical() + " " + a
+}
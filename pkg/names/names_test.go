@@ -0,0 +1,108 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package names
+
+import (
+	"testing"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		canonical string
+		authority string
+		rank      jdh.Rank
+	}{
+		// uninomials
+		{"Carabidae", "Carabidae", "", jdh.Family},
+		{"Carabidae Latreille, 1802", "Carabidae", "Latreille, 1802", jdh.Family},
+		{"Carabus Linnaeus 1758", "Carabus", "Linnaeus, 1758", jdh.Unranked},
+		{"Homo_sapiens", "Homo sapiens", "", jdh.Species},
+		{"Carabinae", "Carabinae", "", jdh.Subfamily},
+		{"Carabini Latreille, 1802", "Carabini", "Latreille, 1802", jdh.Tribe},
+		{"Pooideae", "Pooideae", "", jdh.Subfamily},
+		{"Triticeae Dumort.", "Triticeae", "Dumort.", jdh.Tribe},
+		{"Rosineae", "Rosineae", "", jdh.Suborder},
+		{"Caraboidea", "Caraboidea", "", jdh.Superfamily},
+		{"Poaceae", "Poaceae", "", jdh.Family},
+		{"Rosales", "Rosales", "", jdh.Order},
+
+		// authorities
+		{"Homo sapiens Linnaeus, 1758", "Homo sapiens", "Linnaeus, 1758", jdh.Species},
+		{"Aus bus de Candolle", "Aus bus", "de Candolle", jdh.Species},
+		{"Aus bus Smith, [1802]", "Aus bus", "Smith, 1802", jdh.Species},
+		{"Aus bus Smith, 1802a", "Aus bus", "Smith, 1802a", jdh.Species},
+		{"Abies alba Mill.", "Abies alba", "Mill.", jdh.Species},
+
+		// parentheses
+		{"Puma concolor (Linnaeus, 1771)", "Puma concolor", "(Linnaeus, 1771)", jdh.Species},
+		{"Carabus (Tachypus) cancellatus Illiger, 1798", "Carabus cancellatus", "Illiger, 1798", jdh.Species},
+		{"Carabus (Tachypus)", "Carabus (Tachypus)", "", jdh.Subgenus},
+		{"Solanum lycopersicum var. cerasiforme (Dunal) D.M.Spooner et al.", "Solanum lycopersicum var. cerasiforme", "(Dunal) D.M.Spooner et al.", jdh.Variety},
+
+		// infrageneric and infraspecific ranks
+		{"Quercus sect. Lobatae Loudon", "Quercus sect. Lobatae", "Loudon", jdh.Section},
+		{"Puma concolor couguar (Kerr, 1792)", "Puma concolor couguar", "(Kerr, 1792)", jdh.Subspecies},
+		{"Abies alba subsp. apennina Brullo, Scelsi & Spamp.", "Abies alba subsp. apennina", "Brullo, Scelsi & Spamp.", jdh.Subspecies},
+		{"Abies alba ssp. apennina", "Abies alba ssp. apennina", "", jdh.Subspecies},
+		{"Prunus serotina var. virens (Wooton & Standl.) McVaugh", "Prunus serotina var. virens", "(Wooton & Standl.) McVaugh", jdh.Variety},
+		{"Acer rubrum f. tomentosum", "Acer rubrum f. tomentosum", "", jdh.Form},
+
+		// hybrids
+		{"Mentha ×piperita L.", "Mentha ×piperita", "L.", jdh.Species},
+		{"Mentha x piperita L.", "Mentha ×piperita", "L.", jdh.Species},
+		{"× Agropogon littoralis", "×Agropogon littoralis", "", jdh.Species},
+		{"×Agropogon", "×Agropogon", "", jdh.Unranked},
+
+		// open nomenclature
+		{"Aus sp. 1", "Aus sp. 1", "", jdh.Species},
+		{"Aus sp. A", "Aus sp. A", "", jdh.Species},
+		{"Aus sp.", "Aus sp.", "", jdh.Species},
+		{"Aus sp 2", "Aus sp. 2", "", jdh.Species},
+		{"Aus cf. bus", "Aus cf. bus", "", jdh.Species},
+		{"Aus cf. bus Smith, 1900", "Aus cf. bus", "Smith, 1900", jdh.Species},
+		{"Aus aff. bus (Smith, 1900)", "Aus aff. bus", "(Smith, 1900)", jdh.Species},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.name)
+		if err != nil {
+			t.Errorf("parse %q: unexpected error: %v", test.name, err)
+			continue
+		}
+		if c := n.Canonical(); c != test.canonical {
+			t.Errorf("parse %q: canonical %q, want %q", test.name, c, test.canonical)
+		}
+		if a := n.Authority(); a != test.authority {
+			t.Errorf("parse %q: authority %q, want %q", test.name, a, test.authority)
+		}
+		if r := n.Rank(); r != test.rank {
+			t.Errorf("parse %q: rank %s, want %s", test.name, r, test.rank)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, nm := range []string{
+		"",
+		"   ",
+		"homo sapiens",
+		"carabidae",
+		"Clade1",
+		"AUS BUS",
+		"×",
+		"Aus )",
+		"Aus (Smith, 1900",
+		"Aus bus Smith)",
+		"Aus bus x cus",
+		"Aus bus × Aus cus",
+		"Aus x Bus",
+	} {
+		if n, err := Parse(nm); err == nil {
+			t.Errorf("parse %q: got %q, want error", nm, n.Canonical())
+		}
+	}
+}
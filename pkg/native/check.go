@@ -0,0 +1,187 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// quarantine file, it stores the records removed from the database by a
+// repair.
+const quarFile = "quarantine"
+
+// A Problem is an invalid record of a table file. Invalid records are
+// ignored when the database is opened, so, except for invalid nodes, that
+// are kept as they are, they are lost in the next commit.
+type Problem struct {
+	File  string // file of the record
+	Line  int    // line of the record in the file
+	Id    string `json:",omitempty"` // id of the record, if known
+	Error string // description of the problem
+}
+
+// Quarantined is a record removed from the database by a repair, as stored
+// in the quarantine file.
+type Quarantined struct {
+	Problem
+	Time   time.Time // time of the repair
+	Record string    // the record, as stored in the table file
+}
+
+// Check checks the integrity of the records of the table files of the
+// database stored in a given path, and returns the invalid records. The
+// records are checked in the same way as when the database is opened, so
+// a record that references an invalid record is also invalid.
+//
+// If repair is true, the invalid records are removed from the table files,
+// and stored in the quarantine file of the database. A database can only
+// be repaired if it is not used by another process.
+func Check(path string, repair bool) ([]*Problem, error) {
+	if repair {
+		lck, err := lockDir(path)
+		if err != nil {
+			if err == errLocked {
+				return nil, errors.New("database " + path + " in use by another process")
+			}
+			return nil, err
+		}
+		defer lck.Close()
+		if err := recoverCommit(path); err != nil {
+			return nil, err
+		}
+	}
+	if v, err := FormatVersion(path); err != nil {
+		return nil, err
+	} else if v != Version {
+		return nil, errors.New("database format version " + strconv.Itoa(v) + " is not the current version, the database must be migrated")
+	}
+	db := &DB{path: path}
+	db.d = newDatasets(db)
+	db.t = newTaxonomy(db)
+	db.s = newSpecimens(db)
+	db.rd = newDistros(db)
+	db.tr = newTrees(db)
+	db.ta = newTaxActs(db)
+	var probs []*Problem
+	var files []string
+	var quar []*Quarantined
+	now := time.Now()
+	for _, fn := range tabFiles {
+		var good bytes.Buffer
+		n := len(probs)
+		err := scanRecords(filepath.Join(path, fn), func(line int, rec []byte) {
+			p := db.checkRecord(fn, rec)
+			if p == nil {
+				good.Write(rec)
+				good.WriteByte('\n')
+				return
+			}
+			p.Line = line
+			probs = append(probs, p)
+			quar = append(quar, &Quarantined{Problem: *p, Time: now, Record: string(rec)})
+		})
+		if err != nil {
+			removeNew(path, files)
+			return nil, err
+		}
+		if !repair || (len(probs) == n) {
+			continue
+		}
+		p := filepath.Join(path, fn)
+		f, err := createNew(p)
+		if err == nil {
+			_, err = good.WriteTo(f)
+			err = closeNew(f, err)
+		}
+		files = append(files, fn)
+		if err != nil {
+			removeNew(path, files)
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return probs, nil
+	}
+	// the records are stored in the quarantine before they are removed
+	// from the tables, so they are never lost.
+	if err := writeQuarantine(path, quar); err != nil {
+		removeNew(path, files)
+		return nil, err
+	}
+	// the journal is kept, as the invalid records are ignored when the
+	// database is opened.
+	return probs, install(path, append(files, jourFile))
+}
+
+// CheckRecord checks a record of a table file, and adds it to the
+// database. It returns nil if the record is valid.
+func (db *DB) checkRecord(file string, rec []byte) *Problem {
+	p := &Problem{File: file}
+	var id struct{ Id string }
+	if err := json.Unmarshal(rec, &id); err != nil {
+		p.Error = "invalid record: " + err.Error()
+		return p
+	}
+	p.Id = id.Id
+	elem, err := decodeElem(fileTables[file], rec)
+	if err != nil {
+		p.Error = "invalid record: " + err.Error()
+		return p
+	}
+	if err := db.insert(elem); err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	return nil
+}
+
+// ScanRecords calls fn with each record of a table file, and its line
+// number. Records are stored one per line, empty lines are ignored.
+func scanRecords(p string, fn func(line int, rec []byte)) error {
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if rec := bytes.TrimSpace(b); len(rec) > 0 {
+			fn(line, rec)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// WriteQuarantine appends records to the quarantine file of a database.
+func writeQuarantine(path string, ls []*Quarantined) error {
+	f, err := os.OpenFile(filepath.Join(path, quarFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, q := range ls {
+		if err = enc.Encode(q); err != nil {
+			break
+		}
+	}
+	return closeNew(f, err)
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// commit file, it stores the list of files of a commit that is being
+// installed.
+const comFile = "commit"
+
+// extensions of the new and backup files.
+const (
+	newExt = ".new"
+	bakExt = ".bak"
+)
+
+// tabFiles is the list of all the files used to store the database tables.
+var tabFiles = []string{dsetFile, taxFile, speFile, distroFile, treFile, nodFile, actFile}
+
+// instFiles is the list of all the files that can be installed.
+var instFiles = append([]string{jourFile, histFile, metaFile}, tabFiles...)
+
+// IdLess returns true if the id a is sorted before the id b. Numeric ids
+// are sorted by its value, and before any other id.
+func idLess(a, b string) bool {
+	an, aerr := strconv.ParseInt(a, 10, 64)
+	bn, berr := strconv.ParseInt(b, 10, 64)
+	if (aerr == nil) && (berr == nil) {
+		return an < bn
+	}
+	if (aerr == nil) != (berr == nil) {
+		return aerr == nil
+	}
+	return a < b
+}
+
+// SortedList returns the values of a list sorted by its id. Records are
+// stored in the table files in a stable order, so an edit only changes
+// the lines of the edited records.
+func sortedList(l *list.List, id func(v interface{}) string) []interface{} {
+	ls := &valsById{id: id}
+	for e := l.Front(); e != nil; e = e.Next() {
+		ls.vals = append(ls.vals, e.Value)
+	}
+	sort.Sort(ls)
+	return ls.vals
+}
+
+// valsById sorts a list of values by its id.
+type valsById struct {
+	vals []interface{}
+	id   func(v interface{}) string
+}
+
+func (ls *valsById) Len() int      { return len(ls.vals) }
+func (ls *valsById) Swap(i, j int) { ls.vals[i], ls.vals[j] = ls.vals[j], ls.vals[i] }
+func (ls *valsById) Less(i, j int) bool {
+	return idLess(ls.id(ls.vals[i]), ls.id(ls.vals[j]))
+}
+
+// CreateNew creates the new version of a file.
+func createNew(p string) (*os.File, error) {
+	return os.Create(p + newExt)
+}
+
+// CloseNew flushes a new file into the hard disk and closes it. If err is
+// not nil, the file is closed and err is returned.
+func closeNew(f *os.File, err error) error {
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); (cerr != nil) && (err == nil) {
+		err = cerr
+	}
+	return err
+}
+
+// RemoveNew removes the new files of a failed commit.
+func removeNew(path string, files []string) {
+	for _, fn := range files {
+		os.Remove(filepath.Join(path, fn) + newExt)
+	}
+}
+
+// Install replaces the files of the database with the new ones. Before
+// any file is replaced, the list of files is stored in the commit file, so
+// an interrupted install can be completed when the database is opened
+// again.
+func install(path string, files []string) error {
+	p := filepath.Join(path, comFile)
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(files)
+	if err = closeNew(f, err); err != nil {
+		os.Remove(p)
+		return err
+	}
+	syncDir(path)
+	return finish(path, files)
+}
+
+// Finish moves the new files into place. The previous version of each file
+// is kept as a backup. As the operations of the journal are now stored in
+// the database files, the journal is truncated, except if the journal is
+// one of the installed files (e.g. in a migration, or a repair), in which
+// case it is replaced by its new version, if any.
+func finish(path string, files []string) error {
+	for _, fn := range files {
+		p := filepath.Join(path, fn)
+		if _, err := os.Stat(p + newExt); err != nil {
+			// already installed
+			continue
+		}
+		if _, err := os.Stat(p); err == nil {
+			if err := os.Rename(p, p+bakExt); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(p+newExt, p); err != nil {
+			return err
+		}
+	}
+	syncDir(path)
+	for _, fn := range files {
+		if fn == jourFile {
+			return os.Remove(filepath.Join(path, comFile))
+		}
+	}
+	if err := os.Truncate(filepath.Join(path, jourFile), 0); (err != nil) && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(filepath.Join(path, comFile))
+}
+
+// RecoverCommit completes an interrupted commit, or removes the new files
+// of a commit that was not completed.
+func recoverCommit(path string) error {
+	f, err := os.Open(filepath.Join(path, comFile))
+	if err != nil {
+		removeNew(path, instFiles)
+		return nil
+	}
+	var files []string
+	err = json.NewDecoder(f).Decode(&files)
+	f.Close()
+	if err != nil {
+		// the commit file is incomplete, so the new files were
+		// never installed.
+		removeNew(path, instFiles)
+		return os.Remove(filepath.Join(path, comFile))
+	}
+	return finish(path, files)
+}
+
+// SyncDir flushes the entries of a directory into the hard disk. In some
+// systems it is not possible to sync a directory, so errors are ignored.
+func syncDir(path string) {
+	if len(path) == 0 {
+		path = "."
+	}
+	d, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
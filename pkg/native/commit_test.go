@@ -0,0 +1,218 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// exists returns true if a file of a database exists.
+func exists(path, name string) bool {
+	_, err := os.Stat(filepath.Join(path, name))
+	return err == nil
+}
+
+// TestCommitFiles checks that a commit leaves a backup of the previous
+// version of the table files, and no temporary files.
+func TestCommitFiles(t *testing.T) {
+	path := newDir(t)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTaxon(t, db, "Aus", "genus", "")
+	if err := db.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	addTaxon(t, db, "Bus", "genus", "")
+	if err := db.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if !exists(path, taxFile+bakExt) {
+		t.Errorf("file %s not found", taxFile+bakExt)
+	}
+	for _, fn := range []string{taxFile + newExt, comFile} {
+		if exists(path, fn) {
+			t.Errorf("file %s not removed", fn)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(path, jourFile)); (err != nil) || (fi.Size() != 0) {
+		t.Errorf("journal not truncated")
+	}
+}
+
+// TestRecoverCommit checks that an interrupted commit is completed, or
+// discarded, when the database is opened.
+func TestRecoverCommit(t *testing.T) {
+	const (
+		aus = `{"Id":"1","Name":"Aus","Rank":"genus","IsValid":true}`
+		bus = `{"Id":"2","Name":"Bus","Rank":"genus","IsValid":true}`
+		cus = `{"Id":"3","Name":"Cus","Rank":"genus","IsValid":true}`
+
+		addBus = `{"Query":"add","Table":"taxonomy","Elem":` + bus + `}`
+		addCus = `{"Query":"add","Table":"taxonomy","Elem":` + cus + `}`
+	)
+	tests := []struct {
+		desc  string
+		files map[string][]string
+		names map[string]string // name of each taxon id
+		spes  int               // number of specimens
+		gone  []string          // files removed after the recovery
+	}{
+		{
+			desc: "new files without commit file",
+			files: map[string][]string{
+				taxFile:          {aus},
+				taxFile + newExt: {aus, bus},
+				jourFile:         {addCus},
+			},
+			names: map[string]string{"1": "Aus", "2": "", "3": "Cus"},
+			gone:  []string{taxFile + newExt},
+		},
+		{
+			desc: "incomplete commit file",
+			files: map[string][]string{
+				taxFile:          {aus},
+				taxFile + newExt: {aus, bus},
+				comFile:          {`["taxon`},
+				jourFile:         {addCus},
+			},
+			names: map[string]string{"1": "Aus", "2": "", "3": "Cus"},
+			gone:  []string{taxFile + newExt, comFile},
+		},
+		{
+			desc: "commit not installed",
+			files: map[string][]string{
+				taxFile:          {aus},
+				taxFile + newExt: {aus, bus},
+				comFile:          {`["taxonomy"]`},
+				jourFile:         {addBus},
+			},
+			names: map[string]string{"1": "Aus", "2": "Bus"},
+			gone:  []string{taxFile + newExt, comFile},
+		},
+		{
+			desc: "commit partially installed",
+			files: map[string][]string{
+				taxFile + bakExt: {aus},
+				taxFile:          {aus, bus},
+				speFile + newExt: {`{"Id":"1","Catalog":"MLP 1","Taxon":"2"}`},
+				comFile:          {`["taxonomy","specimens"]`},
+				jourFile:         {addBus, `{"Query":"add","Table":"specimens","Elem":{"Id":"1","Catalog":"MLP 1","Taxon":"2"}}`},
+			},
+			names: map[string]string{"1": "Aus", "2": "Bus"},
+			spes:  1,
+			gone:  []string{speFile + newExt, comFile},
+		},
+	}
+	for _, test := range tests {
+		path := newDir(t)
+		for fn, lines := range test.files {
+			writeFile(t, path, fn, lines...)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		for id, name := range test.names {
+			if nm := taxonName(db, id); nm != name {
+				t.Errorf("%s: taxon %s: got %q, want %q", test.desc, id, nm, name)
+			}
+		}
+		if n := db.s.count(); n != test.spes {
+			t.Errorf("%s: got %d specimens, want %d", test.desc, n, test.spes)
+		}
+		db.Close()
+		for _, fn := range test.gone {
+			if exists(path, fn) {
+				t.Errorf("%s: file %s not removed", test.desc, fn)
+			}
+		}
+	}
+}
+
+// TestCommitTx checks that the operations of a transaction in progress are
+// not committed, but kept in the journal, so they are committed only if
+// the transaction is committed.
+func TestCommitTx(t *testing.T) {
+	path := newDir(t)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aus := addTaxon(t, db, "Aus", "genus", "")
+	tx := db.Begin("editor")
+	if _, err := db.Tx(tx.Id(), "other"); err == nil {
+		t.Errorf("transaction retrieved by other user")
+	}
+	bus, err := tx.Add(jdh.Taxonomy, json.NewDecoder(strings.NewReader(`{"Name":"Bus","Rank":"genus","IsValid":true}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an operation that depends on the transaction.
+	kvs := []jdh.KeyValue{
+		{Key: jdh.KeyId, Value: []string{bus}},
+		{Key: jdh.KeyComment, Value: []string{"a comment"}},
+	}
+	if err := db.Set(jdh.Taxonomy, kvs); err != nil {
+		t.Fatal(err)
+	}
+	cus := addTaxon(t, db, "Cus", "genus", "")
+	if err := db.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// the committed files only have the operations without
+	// transactions.
+	c := &DB{path: path}
+	c.openTables()
+	for id, name := range map[string]string{aus: "Aus", bus: "", cus: "Cus"} {
+		if nm := taxonName(c, id); nm != name {
+			t.Errorf("committed taxon %s: got %q, want %q", id, nm, name)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	dus, err := db.Begin("editor").Add(jdh.Taxonomy, json.NewDecoder(strings.NewReader(`{"Name":"Dus","Rank":"genus","IsValid":true}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	c = &DB{path: path}
+	c.openTables()
+	for id, name := range map[string]string{bus: "Bus", dus: ""} {
+		if nm := taxonName(c, id); nm != name {
+			t.Errorf("committed taxon %s: got %q, want %q", id, nm, name)
+		}
+	}
+	if v, err := c.Get(jdh.Taxonomy, bus); err != nil {
+		t.Error(err)
+	} else if cm := v.(*jdh.Taxon).Comment; cm != "a comment" {
+		t.Errorf("committed taxon %s: comment %q, want %q", bus, cm, "a comment")
+	}
+
+	// the operations of the unfinished transaction are not applied.
+	if db, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for id, name := range map[string]string{aus: "Aus", bus: "Bus", cus: "Cus", dus: ""} {
+		if nm := taxonName(db, id); nm != name {
+			t.Errorf("taxon %s: got %q, want %q", id, nm, name)
+		}
+	}
+}
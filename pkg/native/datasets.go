@@ -38,14 +38,19 @@ type setData struct {
 // dataset file
 const dsetFile = "datasets"
 
-// OpenDatasets open dataset data.
-func openDatasets(db *DB) *datasets {
-	d := &datasets{
+// NewDatasets returns an empty table of datasets.
+func newDatasets(db *DB) *datasets {
+	return &datasets{
 		db:   db,
 		ids:  make(map[string]*setData),
 		ls:   list.New(),
 		next: 1,
 	}
+}
+
+// OpenDatasets open dataset data.
+func openDatasets(db *DB) *datasets {
+	d := newDatasets(db)
 	p := filepath.Join(db.path, dsetFile)
 	f, err := os.Open(p)
 	if err != nil {
@@ -153,6 +158,17 @@ func (d *datasets) add(set *jdh.Dataset) (string, error) {
 	return id, nil
 }
 
+// Insert adds a dataset to the database, preserving its id.
+func (d *datasets) insert(set *jdh.Dataset) error {
+	d.setNext(set.Id)
+	if err := d.validate(set); err != nil {
+		return err
+	}
+	d.addSet(set)
+	d.changed = true
+	return nil
+}
+
 // Commit saves the datasets to the hard disk.
 func (d *datasets) commit(e chan error) {
 	if !d.changed {
@@ -160,19 +176,24 @@ func (d *datasets) commit(e chan error) {
 		return
 	}
 	p := filepath.Join(d.db.path, dsetFile)
-	f, err := os.Create(p)
+	f, err := createNew(p)
 	if err != nil {
 		e <- err
 		return
 	}
-	defer f.Close()
 	enc := json.NewEncoder(f)
-	for e := d.ls.Front(); e != nil; e = e.Next() {
-		sd := e.Value.(*setData)
-		enc.Encode(sd.data)
+	for _, v := range sortedList(d.ls, setId) {
+		sd := v.(*setData)
+		if err = enc.Encode(sd.data); err != nil {
+			break
+		}
 	}
-	d.changed = false
-	e <- nil
+	e <- closeNew(f, err)
+}
+
+// SetId returns the id of a dataset.
+func setId(v interface{}) string {
+	return v.(*setData).data.Id
 }
 
 // Delete deletes a collection from the database.
@@ -205,6 +226,19 @@ func (d *datasets) delete(vals []jdh.KeyValue) error {
 	return nil
 }
 
+// Count returns the number of datasets in the database. As the datasets are
+// also indexed by its extern ids, only the entries of its ids are
+// counted.
+func (d *datasets) count() int {
+	n := 0
+	for id, v := range d.ids {
+		if id == v.data.Id {
+			n++
+		}
+	}
+	return n
+}
+
 // Get returns a dataset with a given id.
 func (d *datasets) get(id string) (*jdh.Dataset, error) {
 	if len(id) == 0 {
@@ -446,3 +480,14 @@ func (d *datasets) isInDB(id string) bool {
 	}
 	return false
 }
+
+// Restore sets a dataset to a previous state. If the dataset is not in the
+// database, it will be added.
+func (d *datasets) restore(set *jdh.Dataset) error {
+	if _, ok := d.ids[set.Id]; ok {
+		if err := d.delete(idVals(set.Id)); err != nil {
+			return err
+		}
+	}
+	return d.insert(set)
+}
@@ -48,15 +48,20 @@ type raster struct {
 // distributions file
 const distroFile = "distros"
 
-// OpenDistros open raster distribution data.
-func openDistros(db *DB) *distros {
-	d := &distros{
+// NewDistros returns an empty table of raster distributions.
+func newDistros(db *DB) *distros {
+	return &distros{
 		db:    db,
 		taxId: make(map[string]*rasTaxon),
 		taxLs: list.New(),
 		ids:   make(map[string]*raster),
 		next:  1,
 	}
+}
+
+// OpenDistros open raster distribution data.
+func openDistros(db *DB) *distros {
+	d := newDistros(db)
 	p := filepath.Join(db.path, distroFile)
 	f, err := os.Open(p)
 	if err != nil {
@@ -180,6 +185,17 @@ func (d *distros) add(ras *jdh.Raster) (string, error) {
 	return id, nil
 }
 
+// Insert adds a raster to the database, preserving its id.
+func (d *distros) insert(ras *jdh.Raster) error {
+	d.setNext(ras.Id)
+	if err := d.validate(ras); err != nil {
+		return err
+	}
+	d.addRaster(ras)
+	d.changed = true
+	return nil
+}
+
 // Commit saves the rasters into hard disk.
 func (d *distros) commit(e chan error) {
 	if !d.changed {
@@ -187,22 +203,35 @@ func (d *distros) commit(e chan error) {
 		return
 	}
 	p := filepath.Join(d.db.path, distroFile)
-	f, err := os.Create(p)
+	f, err := createNew(p)
 	if err != nil {
 		e <- err
 		return
 	}
-	defer f.Close()
 	enc := json.NewEncoder(f)
-	for et := d.taxLs.Front(); et != nil; et = et.Next() {
-		tax := et.Value.(*rasTaxon)
-		for e := tax.rsLs.Front(); e != nil; e = e.Next() {
-			rd := e.Value.(*raster)
-			enc.Encode(rd.data)
+	for _, vt := range sortedList(d.taxLs, rasTaxId) {
+		tax := vt.(*rasTaxon)
+		for _, v := range sortedList(tax.rsLs, rasId) {
+			rd := v.(*raster)
+			if err = enc.Encode(rd.data); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
 		}
 	}
-	d.changed = false
-	e <- nil
+	e <- closeNew(f, err)
+}
+
+// RasTaxId returns the id of a taxon of the rasters table.
+func rasTaxId(v interface{}) string {
+	return v.(*rasTaxon).id
+}
+
+// RasId returns the id of a raster.
+func rasId(v interface{}) string {
+	return v.(*raster).data.Id
 }
 
 // Delete deletes a raster or a set of rasters from the database.
@@ -279,6 +308,19 @@ func (d *distros) delRaster(rd *raster) *rasTaxon {
 	return tax
 }
 
+// Count returns the number of rasters in the database. As the rasters are
+// also indexed by its extern ids, only the entries of its ids are
+// counted.
+func (d *distros) count() int {
+	n := 0
+	for id, v := range d.ids {
+		if id == v.data.Id {
+			n++
+		}
+	}
+	return n
+}
+
 // Get returns a raster with a given id.
 func (d *distros) get(id string) (*jdh.Raster, error) {
 	if len(id) == 0 {
@@ -569,3 +611,28 @@ func (d *distros) delExtern(rd *raster, service string) bool {
 	}
 	return false
 }
+
+// Restore sets a raster to a previous state. If the raster is not in the
+// database, it will be added.
+func (d *distros) restore(ras *jdh.Raster) error {
+	if _, ok := d.ids[ras.Id]; ok {
+		if err := d.delete(idVals(ras.Id)); err != nil {
+			return err
+		}
+	}
+	return d.insert(ras)
+}
+
+// UndoTaxon returns the operations that add again the rasters of a taxon.
+func (d *distros) undoTaxon(id string) []*op {
+	tax, ok := d.taxId[id]
+	if !ok {
+		return nil
+	}
+	var undo []*op
+	for e := tax.rsLs.Front(); e != nil; e = e.Next() {
+		rd := e.Value.(*raster)
+		undo = append(undo, newOp(jdh.Add, jdh.RasDistros, rd.data))
+	}
+	return undo
+}
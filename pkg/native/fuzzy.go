@@ -0,0 +1,252 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fuzzy matching of taxon names. Names are compared word by word (genus,
+// specific and infraspecific epithets, rank markers and infrageneric
+// names between parenthesis are ignored). Two words match if they are
+// equal after a phonetic normalization, similar to the one used by
+// Taxamatch (Rees, 2014, PLoS ONE 9: e107510), or if the edit distance
+// between them is small compared with the length of the word.
+
+// Similar returns the taxons with names similar to a given name, ordered
+// by its similarity.
+func (t *taxonomy) similar(name string) *list.List {
+	l := list.New()
+	q := fuzzyWords(name)
+	if len(q) == 0 {
+		return l
+	}
+	qp := make([]string, len(q))
+	for i, w := range q {
+		qp[i] = phonetic(w, i > 0)
+	}
+	var ms []fuzzyMatch
+	for _, tx := range t.candidates(q[0], qp[0]) {
+		ws := fuzzyWords(tx.data.Name)
+		if len(ws) != len(q) {
+			continue
+		}
+		dist := 0
+		for i, w := range ws {
+			d := editDistance(q[i], w)
+			if (d > 0) && (qp[i] != phonetic(w, i > 0)) {
+				if (d > len(q[i])/4) || (q[i][0] != w[0]) {
+					dist = -1
+					break
+				}
+			}
+			dist += d
+		}
+		if dist < 0 {
+			continue
+		}
+		ms = append(ms, fuzzyMatch{tx, dist})
+	}
+	sort.Sort(byDistance(ms))
+	for _, m := range ms {
+		l.PushBack(m.tx.data)
+	}
+	return l
+}
+
+// Candidates returns the taxons that can be similar to a name, given the
+// first word of the name, and its phonetic normalization. As the first
+// words of similar names start with the same letter, or have the same
+// phonetic normalization, only the names that start with the letter of the
+// word, or with a letter with the same normalization, are searched in the
+// names of the taxonomy, so each taxon is found only once.
+func (t *taxonomy) candidates(w, p string) []*taxon {
+	first := func(s string) string {
+		for _, r := range s {
+			return string(r)
+		}
+		return ""
+	}
+	fw, fp := first(w), first(p)
+	starts := []string{fw, fp}
+	for _, in := range initials {
+		if first(in[1]) == fp {
+			starts = append(starts, in[0])
+		}
+	}
+	for r, d := range diacritics {
+		if (string(d) == fw) || (string(d) == fp) {
+			starts = append(starts, string(r))
+		}
+	}
+	seen := make(map[*taxon]bool)
+	var ls []*taxon
+	for _, s := range starts {
+		for e := t.names.Prefix(s).Front(); e != nil; e = e.Next() {
+			for _, tx := range e.Value.([]*taxon) {
+				if seen[tx] {
+					continue
+				}
+				seen[tx] = true
+				ls = append(ls, tx)
+			}
+		}
+	}
+	return ls
+}
+
+// FuzzyMatch is a taxon that matches a name.
+type fuzzyMatch struct {
+	tx   *taxon
+	dist int // sum of the edit distances of the words
+}
+
+// ByDistance sorts the matches by its distance, and then by name.
+type byDistance []fuzzyMatch
+
+func (b byDistance) Len() int      { return len(b) }
+func (b byDistance) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byDistance) Less(i, j int) bool {
+	if b[i].dist != b[j].dist {
+		return b[i].dist < b[j].dist
+	}
+	if b[i].tx.data.Name != b[j].tx.data.Name {
+		return b[i].tx.data.Name < b[j].tx.data.Name
+	}
+	return idLess(b[i].tx.data.Id, b[j].tx.data.Id)
+}
+
+// FuzzyWords returns the words of a name used in a fuzzy comparison, in
+// lower case and without diacritics.
+func fuzzyWords(name string) []string {
+	var ws []string
+	for _, w := range strings.Fields(strings.ToLower(name)) {
+		if strings.ContainsAny(w, ".()") {
+			continue
+		}
+		w = strings.Map(func(r rune) rune {
+			if d, ok := diacritics[r]; ok {
+				return d
+			}
+			if !unicode.IsLetter(r) && (r != '-') {
+				return -1
+			}
+			return r
+		}, w)
+		if len(w) == 0 {
+			continue
+		}
+		ws = append(ws, w)
+	}
+	return ws
+}
+
+// diacritics are the letters with diacritics, and its replacements.
+var diacritics = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o', 'ø': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ç': 'c', 'ñ': 'n', 'ý': 'y',
+}
+
+// initials are the replacements of the start of a word in the phonetic
+// normalization.
+var initials = [][2]string{
+	{"ae", "e"}, {"cn", "n"}, {"ct", "t"}, {"cz", "c"}, {"dj", "j"},
+	{"ea", "e"}, {"eu", "u"}, {"gn", "n"}, {"kn", "n"}, {"mc", "mac"},
+	{"mn", "n"}, {"oe", "e"}, {"qu", "q"}, {"ps", "s"}, {"pt", "t"},
+	{"ts", "t"}, {"wr", "r"}, {"x", "z"},
+}
+
+// phoneticRepl are the replacements of the rest of a word in the phonetic
+// normalization.
+var phoneticRepl = strings.NewReplacer(
+	"ae", "i", "ia", "a", "oi", "a", "oe", "i", "sc", "s", "ph", "f",
+	"e", "i", "o", "a", "u", "i", "y", "i", "j", "i", "k", "c", "z", "s",
+	"h", "",
+)
+
+// endings are the word endings that are ignored in epithets, as they
+// change with the grammatical gender of the genus.
+var endings = []string{"um", "us", "is", "os", "as", "es", "ys", "a", "e", "i", "o", "y"}
+
+// Phonetic returns the phonetic normalization of a word. If epithet is
+// true, the word is an epithet, and its ending is ignored.
+func phonetic(w string, epithet bool) string {
+	w = strings.Replace(w, "-", "", -1)
+	if epithet {
+		for _, e := range endings {
+			if (len(w) > len(e)+1) && strings.HasSuffix(w, e) {
+				w = w[:len(w)-len(e)]
+				break
+			}
+		}
+	}
+	for _, in := range initials {
+		if strings.HasPrefix(w, in[0]) {
+			w = in[1] + w[len(in[0]):]
+			break
+		}
+	}
+	if len(w) < 2 {
+		return w
+	}
+	w = w[:1] + phoneticRepl.Replace(w[1:])
+
+	// removes repeated letters
+	var b []byte
+	for i := 0; i < len(w); i++ {
+		if (i > 0) && (w[i] == w[i-1]) {
+			continue
+		}
+		b = append(b, w[i])
+	}
+	return string(b)
+}
+
+// EditDistance returns the edit distance between two words, in which the
+// transposition of two adjacent letters is a single edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if (i > 1) && (j > 1) && (ra[i-1] == rb[j-2]) && (ra[i-2] == rb[j-1]) {
+				if v := d[i-2][j-2] + 1; v < d[i][j] {
+					d[i][j] = v
+				}
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// Min3 returns the minimum of three values.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// history file
+const histFile = "history"
+
+// histSize is the maximum number of changes stored in the history.
+const histSize = 500
+
+// Change is a change of the database, with the operations that revert it.
+type change struct {
+	jdh.Change
+	Undo []*op
+}
+
+// History stores the most recent changes of the database. Unlike the
+// journal, the history is kept after a commit.
+type history struct {
+	path string
+	f    *os.File
+	ls   []*change
+	next uint64 // next valid id
+}
+
+// OpenHistory opens the history of the database.
+func openHistory(db *DB) (*history, error) {
+	h := &history{
+		path: filepath.Join(db.path, histFile),
+		next: 1,
+	}
+	if f, err := os.Open(h.path); err == nil {
+		dec := json.NewDecoder(f)
+		for {
+			c := &change{}
+			if err := dec.Decode(c); err != nil {
+				if err != io.EOF {
+					log.Printf("db-history: error: %v\n", err)
+				}
+				break
+			}
+			if v, err := strconv.ParseUint(c.Id, 10, 64); (err == nil) && (v >= h.next) {
+				h.next = v + 1
+			}
+			h.ls = append(h.ls, c)
+		}
+		f.Close()
+	}
+	var err error
+	if h.f, err = os.OpenFile(h.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Record adds a new change to the history. Undo is the list of inverse
+// operations of each query, in the order in which the queries were made.
+func (h *history) record(queries []jdh.ChangeQuery, undo [][]*op) error {
+	c := &change{
+		Change: jdh.Change{
+			Id:      strconv.FormatUint(h.next, 10),
+			Date:    time.Now(),
+			Queries: queries,
+		},
+	}
+	for i := len(undo) - 1; i >= 0; i-- {
+		c.Undo = append(c.Undo, undo[i]...)
+	}
+	h.next++
+	h.ls = append(h.ls, c)
+	if len(h.ls) > histSize {
+		h.ls = h.ls[len(h.ls)-histSize:]
+		return h.rewrite()
+	}
+	if err := json.NewEncoder(h.f).Encode(c); err != nil {
+		return err
+	}
+	return h.f.Sync()
+}
+
+// Index returns the position of a change in the history. If id is empty,
+// it returns the position of the last change.
+func (h *history) index(id string) (int, error) {
+	if len(h.ls) == 0 {
+		return 0, errors.New("empty history")
+	}
+	if len(id) == 0 {
+		return len(h.ls) - 1, nil
+	}
+	for i, c := range h.ls {
+		if c.Id == id {
+			return i, nil
+		}
+	}
+	return 0, errors.New("change " + id + " not in history")
+}
+
+// Truncate removes all the changes after the given position, including
+// it.
+func (h *history) truncate(i int) error {
+	for j := i; j < len(h.ls); j++ {
+		h.ls[j] = nil
+	}
+	h.ls = h.ls[:i]
+	return h.rewrite()
+}
+
+// Rewrite writes the complete history into a new file, and then replaces
+// the history file.
+func (h *history) rewrite() error {
+	f, err := createNew(h.path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, c := range h.ls {
+		if err = enc.Encode(c); err != nil {
+			break
+		}
+	}
+	if err := closeNew(f, err); err != nil {
+		os.Remove(h.path + newExt)
+		return err
+	}
+	h.f.Close()
+	if err := os.Rename(h.path+newExt, h.path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(h.path))
+	h.f, err = os.OpenFile(h.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	return err
+}
+
+// Get returns a change with a given id.
+func (h *history) get(id string) (*jdh.Change, error) {
+	if len(id) == 0 {
+		return nil, errors.New("change without identification")
+	}
+	for _, c := range h.ls {
+		if c.Id == id {
+			return &c.Change, nil
+		}
+	}
+	return nil, nil
+}
+
+// List returns the list of changes in the history.
+func (h *history) list(vals []jdh.KeyValue) (*list.List, error) {
+	l := list.New()
+	for _, c := range h.ls {
+		l.PushBack(&c.Change)
+	}
+	return l, nil
+}
+
+// Close closes the history file.
+func (h *history) close() error {
+	return h.f.Close()
+}
+
+// ChangeQuery returns the query of an operation, as stored in the history.
+// In additions, id is the id of the added element.
+func changeQuery(o *op, id string) jdh.ChangeQuery {
+	q := jdh.ChangeQuery{
+		Query: o.Query,
+		Table: o.Table,
+		Kvs:   o.Kvs,
+	}
+	if o.Query == jdh.Add {
+		q.Kvs = idVals(id)
+	}
+	return q
+}
+
+// Undo reverts a change of the history, and all the changes made after it.
+// If id is empty, the last change will be reverted. The reversion is
+// applied as a single transaction, and it is not stored in the history.
+// Changes can not be reverted if an element modified by them is also
+// modified by a transaction in progress (transactions idle for too long
+// are rolled back).
+func (db *DB) Undo(id string) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.expire()
+	i, err := db.hist.index(id)
+	if err != nil {
+		return err
+	}
+	if db.txConflict(db.hist.ls[i:]) {
+		return errors.New("transaction in progress on the changed elements")
+	}
+	tx := db.begin()
+	for j := len(db.hist.ls) - 1; j >= i; j-- {
+		for _, u := range db.hist.ls[j].Undo {
+			// the operation is copied, as it is modified by the
+			// transaction.
+			o := *u
+			if _, err := tx.exec(&o, nil); err != nil {
+				tx.rollback()
+				return err
+			}
+		}
+	}
+	if err := tx.commit(); err != nil {
+		tx.rollback()
+		return err
+	}
+	for _, c := range db.hist.ls[i:] {
+		db.notify(c.Queries, jdh.Undo)
+	}
+	return db.hist.truncate(i)
+}
+
+// TxConflict returns true if an element modified by the given changes is
+// also modified by a transaction in progress. It must be called with the
+// database locked.
+func (db *DB) txConflict(ls []*change) bool {
+	elems := make(map[string]bool)
+	for _, c := range ls {
+		for _, q := range c.Queries {
+			elems[string(q.Table)+" "+getVal(q.Kvs, jdh.KeyId)] = true
+		}
+	}
+	for _, tx := range db.txs {
+		for _, q := range tx.queries {
+			if elems[string(q.Table)+" "+getVal(q.Kvs, jdh.KeyId)] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// journal file
+const jourFile = "journal"
+
+// Op is a mutating operation done on the database, as stored in the
+// journal.
+type op struct {
+	Query jdh.Query
+	Table jdh.Table
+	Kvs   []jdh.KeyValue  `json:",omitempty"`
+	Elem  json.RawMessage `json:",omitempty"`
+	Tx    string          `json:",omitempty"` // transaction of the operation
+}
+
+// Journal is a write-ahead log of the operations done in the database
+// since the last commit.
+type journal struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+// OpenJournal opens the journal of the database, and replays any
+// operation stored on it.
+func openJournal(db *DB) (*journal, error) {
+	p := filepath.Join(db.path, jourFile)
+	end, complete := int64(0), true
+	if f, err := os.Open(p); err == nil {
+		end, complete = db.replay(f)
+		f.Close()
+	}
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		// the incomplete operation is removed, so the new operations
+		// can be read.
+		if err := f.Truncate(end); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if end > 0 {
+			if _, err := f.Write([]byte("\n")); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return &journal{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Replay applies the operations stored in a journal. Operations of a
+// transaction are only applied if the transaction was finished, either by a
+// commit, or by a rollback (in which case the operations that revert the
+// transaction follow the operations of the transaction), so the state of
+// the database is the same as when the operations were made, even if other
+// operations were interleaved with the transaction. It returns the offset
+// of the end of the last valid operation, and false if the journal ends
+// with an invalid operation.
+func (db *DB) replay(r io.Reader) (int64, bool) {
+	var ops []*op
+	done := make(map[string]bool)
+	dec := json.NewDecoder(r)
+	var end int64
+	complete := true
+	for {
+		o := &op{}
+		if err := dec.Decode(o); err != nil {
+			if err == io.EOF {
+				break
+			}
+			// an incomplete operation at the end of the journal is
+			// the expected result of a crash.
+			log.Printf("db-journal: error: %v\n", err)
+			complete = false
+			break
+		}
+		end = dec.InputOffset()
+		if (o.Query == jdh.Commit) || (o.Query == jdh.Rollback) {
+			done[o.Tx] = true
+			continue
+		}
+		ops = append(ops, o)
+	}
+	for _, o := range ops {
+		if (len(o.Tx) > 0) && !done[o.Tx] {
+			continue
+		}
+		if err := db.apply(o); err != nil {
+			log.Printf("db-journal: error: %v\n", err)
+		}
+	}
+	return end, complete
+}
+
+// ReplayCommitted applies the operations stored in a journal, except the
+// operations of the given transactions in progress, and the operations
+// that depend on them (i.e. operations on an element modified by a
+// transaction in progress, or operations that fail). It returns the
+// operations that were not applied, in the order of the journal. As the
+// transaction marks are not returned, the returned operations of finished
+// transactions are returned without its transaction.
+func (db *DB) replayCommitted(r io.Reader, open map[string]*Tx) ([]*op, error) {
+	var ops []*op
+	done := make(map[string]bool)
+	dec := json.NewDecoder(r)
+	for {
+		o := &op{}
+		if err := dec.Decode(o); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if (o.Query == jdh.Commit) || (o.Query == jdh.Rollback) {
+			done[o.Tx] = true
+			continue
+		}
+		ops = append(ops, o)
+	}
+	var kept []*op
+	pending := make(map[string]bool)
+	for _, o := range ops {
+		if len(o.Tx) > 0 {
+			if _, ok := open[o.Tx]; ok {
+				pending[string(o.Table)+" "+o.elemId()] = true
+				kept = append(kept, o)
+				continue
+			}
+			if !done[o.Tx] {
+				// an unfinished transaction of a previous
+				// session is never applied.
+				continue
+			}
+		}
+		if pending[string(o.Table)+" "+o.elemId()] || (db.apply(o) != nil) {
+			o.Tx = ""
+			kept = append(kept, o)
+		}
+	}
+	return kept, nil
+}
+
+// ElemId returns the id of the element modified by an operation.
+func (o *op) elemId() string {
+	if len(o.Elem) == 0 {
+		return getVal(o.Kvs, jdh.KeyId)
+	}
+	var e struct{ Id string }
+	json.Unmarshal(o.Elem, &e)
+	return e.Id
+}
+
+// Apply applies a journaled operation. Added elements preserve their
+// ids.
+func (db *DB) apply(o *op) error {
+	switch o.Query {
+	case jdh.Add:
+		elem, err := decodeElem(o.Table, o.Elem)
+		if err != nil {
+			return err
+		}
+		return db.insert(elem)
+	case jdh.Delete:
+		return db.delete(o.Table, o.Kvs)
+	case jdh.Set:
+		return db.set(o.Table, o.Kvs)
+	case restore:
+		return db.restore(o.Table, o.Elem)
+	}
+	return errors.New("invalid journal operation " + string(o.Query))
+}
+
+// Write writes an operation into the journal.
+func (j *journal) write(o *op) error {
+	if err := j.enc.Encode(o); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// Truncate removes all the operations from the journal.
+func (j *journal) truncate() error {
+	return j.f.Truncate(0)
+}
+
+// Close closes the journal file.
+func (j *journal) close() error {
+	return j.f.Close()
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// newDir returns the path of a new empty database.
+func newDir(t *testing.T) string {
+	path := t.TempDir()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	return path
+}
+
+// writeFile writes the lines of a file of a database.
+func writeFile(t *testing.T, path, name string, lines ...string) {
+	if err := ioutil.WriteFile(filepath.Join(path, name), []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// taxonName returns the name of a taxon of a database, or an empty string
+// if the taxon is not in the database.
+func taxonName(db *DB, id string) string {
+	v, err := db.Get(jdh.Taxonomy, id)
+	if err != nil {
+		return ""
+	}
+	tax, ok := v.(*jdh.Taxon)
+	if !ok || (tax == nil) {
+		return ""
+	}
+	return tax.Name
+}
+
+// TestReplay checks that the operations of the journal are applied when
+// the database is opened, except the operations of unfinished
+// transactions, and an incomplete operation at the end of the journal.
+func TestReplay(t *testing.T) {
+	path := newDir(t)
+	writeFile(t, path, jourFile,
+		`{"Query":"add","Table":"taxonomy","Elem":{"Id":"1","Name":"Aus","Rank":"genus","IsValid":true}}`,
+		// committed transaction
+		`{"Query":"add","Table":"taxonomy","Elem":{"Id":"2","Name":"Aus bus","Rank":"species","Parent":"1","IsValid":true},"Tx":"1"}`,
+		// unfinished transaction, interleaved with other operations
+		`{"Query":"add","Table":"taxonomy","Elem":{"Id":"3","Name":"Aus cus","Rank":"species","Parent":"1","IsValid":true},"Tx":"2"}`,
+		`{"Query":"commit","Tx":"1"}`,
+		// rolled back transaction
+		`{"Query":"add","Table":"taxonomy","Elem":{"Id":"4","Name":"Aus dus","Rank":"species","Parent":"1","IsValid":true},"Tx":"3"}`,
+		`{"Query":"delete","Table":"taxonomy","Kvs":[{"Key":"id","Value":["4"]}],"Tx":"3"}`,
+		`{"Query":"rollback","Tx":"3"}`,
+		`{"Query":"set","Table":"taxonomy","Kvs":[{"Key":"id","Value":["1"]},{"Key":"authority","Value":["Smith"]}]}`,
+		// interrupted write
+		`{"Query":"add","Table":"taxonomy","Elem":{"Id":"5","Na`,
+	)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { db.Close() }()
+	tests := []struct {
+		id, name string
+	}{
+		{"1", "Aus"},
+		{"2", "Aus bus"},
+		{"3", ""},
+		{"4", ""},
+		{"5", ""},
+	}
+	for _, test := range tests {
+		if nm := taxonName(db, test.id); nm != test.name {
+			t.Errorf("taxon %s: got %q, want %q", test.id, nm, test.name)
+		}
+	}
+	if v, err := db.Get(jdh.Taxonomy, "1"); err == nil {
+		if a := v.(*jdh.Taxon).Authority; a != "Smith" {
+			t.Errorf("taxon 1: authority %q, want %q", a, "Smith")
+		}
+	}
+
+	// operations written after an interrupted write are not lost.
+	id := addTaxon(t, db, "Aus eus", "species", "1")
+	db.Close()
+	if db, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if nm := taxonName(db, id); nm != "Aus eus" {
+		t.Errorf("taxon %s: got %q, want %q", id, nm, "Aus eus")
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import "errors"
+
+// lock file. While the database is open, the file is locked, so the
+// database can not be opened by another process (for example, a server
+// and a program using the database directly). The lock is released when
+// the database is closed, or the process ends.
+const lckFile = "lock"
+
+// errLocked is the error returned when the database is used by another
+// process.
+var errLocked = errors.New("database in use by another process")
@@ -0,0 +1,18 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package native
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// LockDir opens the lock file of a database. In this system the file is
+// not locked.
+func lockDir(path string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(path, lckFile), os.O_RDWR|os.O_CREATE, 0644)
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// errSharingViolation is the error returned by windows when a file is
+// opened by another process (ERROR_SHARING_VIOLATION).
+const errSharingViolation syscall.Errno = 32
+
+// LockDir locks the directory of a database. The lock file is opened
+// without sharing, so it can not be opened by another process.
+func lockDir(path string) (*os.File, error) {
+	name := filepath.Join(path, lckFile)
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if err == errSharingViolation {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), name), nil
+}
@@ -0,0 +1,724 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// DiffKind is the kind of a difference between two databases.
+type DiffKind string
+
+// Valid kinds of differences.
+const (
+	// Added is an element that is only in the second database.
+	Added DiffKind = "added"
+
+	// Removed is an element that is only in the first database.
+	Removed DiffKind = "removed"
+
+	// Changed is an element that is different in each database.
+	Changed DiffKind = "changed"
+
+	// Conflict is an element that can not be merged.
+	Conflict DiffKind = "conflict"
+)
+
+// A Diff is a difference of an element between two databases. Elements
+// of the databases are matched by its id, and then, by its extern ids, so
+// an element can have a different id in each database.
+type Diff struct {
+	Table  jdh.Table
+	Kind   DiffKind
+	Id     string   `json:",omitempty"` // id in the first database
+	Other  string   `json:",omitempty"` // id in the second database
+	Name   string   `json:",omitempty"` // name of the element, if any
+	Fields []string `json:",omitempty"` // fields with different values
+	Note   string   `json:",omitempty"` // reason of a conflict
+}
+
+// mergeTables are the tables compared and merged, in the order in which
+// they must be added to a database.
+var mergeTables = []jdh.Table{jdh.Datasets, jdh.Taxonomy, jdh.Specimens, jdh.RasDistros, jdh.Trees}
+
+// record is an element of a table, as used to compare databases. The
+// element of a tree is a treeImage, with its nodes ordered from the root.
+type record struct {
+	id     string
+	name   string
+	parent string // id of the parent, in the same table, if any
+	extern []string
+	elem   interface{}
+}
+
+// entry is an element matched in the databases of a merge. Any of the
+// records can be nil.
+type entry struct {
+	ours, other, base *record
+}
+
+// Compare compares the database stored in a given path with the database
+// stored in other path, and returns the differences between them. Both
+// databases are only read, so they can be used by other process.
+func Compare(path, other string) ([]*Diff, error) {
+	db, err := openReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	odb, err := openReadOnly(other)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[jdh.Table]map[string]string)
+	var diffs []*Diff
+	for _, table := range mergeTables {
+		ours, theirs := db.records(table), odb.records(table)
+		ids[table] = match(ours, theirs, nil)
+		for _, e := range entries(ours, theirs, nil, ids[table]) {
+			d := &Diff{Table: table}
+			switch {
+			case e.other == nil:
+				d.Kind, d.Id, d.Name = Removed, e.ours.id, e.ours.name
+			case e.ours == nil:
+				d.Kind, d.Other, d.Name = Added, e.other.id, e.other.name
+			default:
+				d.Fields = diffFields(e.ours.elem, translate(table, e.other.elem, ids))
+				if len(d.Fields) == 0 {
+					continue
+				}
+				d.Kind, d.Id, d.Other, d.Name = Changed, e.ours.id, e.other.id, e.ours.name
+			}
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs, nil
+}
+
+// Merge merges the database stored in a given path with the database
+// stored in other path, and stores the result in a new database in out
+// path. If base is defined, it is the path of the common ancestor of both
+// databases, and the merge is done in three ways: an element changed, or
+// removed, in only one of the databases, is merged with the change. If
+// base is not defined, elements that are only in the other database are
+// added, and all the other elements are taken from the first database.
+//
+// If an element is changed in both databases, or can not be added to the
+// merged database (e.g. its parent was removed), the element is taken
+// from the first database, and a conflict is reported. Merge returns the
+// changes taken from the other database, and the conflicts. Only the
+// merged database is modified.
+func Merge(path, other, base, out string) ([]*Diff, error) {
+	if !isEmpty(out) {
+		return nil, errors.New("database " + out + " already exists")
+	}
+	db, err := openReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	odb, err := openReadOnly(other)
+	if err != nil {
+		return nil, err
+	}
+	var bdb *DB
+	if len(base) > 0 {
+		if bdb, err = openReadOnly(base); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return nil, err
+	}
+	mdb, err := Open(out)
+	if err != nil {
+		return nil, err
+	}
+	defer mdb.Close()
+
+	m := &merger{
+		db:    mdb,
+		match: make(map[jdh.Table]map[string]string),
+		ids:   make(map[jdh.Table]map[string]string),
+	}
+	ents := make(map[jdh.Table][]*entry)
+	for _, table := range mergeTables {
+		ours, theirs := db.records(table), odb.records(table)
+		var bs map[string]*record
+		if bdb != nil {
+			bs = make(map[string]*record)
+			for _, r := range bdb.records(table) {
+				bs[r.id] = r
+			}
+		}
+		m.match[table] = match(ours, theirs, bs)
+		ents[table] = entries(ours, theirs, bs, m.match[table])
+		m.setIds(table, ours, theirs, m.match[table])
+	}
+	for _, table := range mergeTables {
+		var elems []interface{}
+		for _, e := range ents[table] {
+			if el := m.resolve(table, e); el != nil {
+				elems = append(elems, el)
+			}
+		}
+		m.add(table, elems)
+	}
+	m.addActs(db, odb)
+	return m.diffs, mdb.Commit()
+}
+
+// merger holds the state of a merge.
+type merger struct {
+	db    *DB                             // merged database
+	match map[jdh.Table]map[string]string // map of other-id:id
+	ids   map[jdh.Table]map[string]string // map of other-id:merged-id
+	diffs []*Diff
+
+	// node ids used in the merged database
+	nodes  map[string]bool
+	nxNode int64
+}
+
+// SetIds sets the ids of the elements of the other database in the merged
+// database. Elements matched with an element of the first database use its
+// id, other elements keep its id, except if the id is used in the first
+// database.
+func (m *merger) setIds(table jdh.Table, ours, theirs []*record, match map[string]string) {
+	used := make(map[string]bool)
+	var next int64 = 1
+	setNext := func(id string) {
+		if v, err := strconv.ParseInt(id, 10, 64); (err == nil) && (v >= next) {
+			next = v + 1
+		}
+	}
+	for _, r := range ours {
+		used[r.id] = true
+		setNext(r.id)
+	}
+	for _, r := range theirs {
+		setNext(r.id)
+	}
+	ids := make(map[string]string)
+	for _, r := range theirs {
+		if id, ok := match[r.id]; ok {
+			ids[r.id] = id
+			continue
+		}
+		if !used[r.id] {
+			ids[r.id] = r.id
+			used[r.id] = true
+			continue
+		}
+		ids[r.id] = strconv.FormatInt(next, 10)
+		next++
+	}
+	m.ids[table] = ids
+	if table != jdh.Trees {
+		return
+	}
+	// nodes
+	m.nodes = make(map[string]bool)
+	m.nxNode = 1
+	for _, r := range append(append([]*record{}, ours...), theirs...) {
+		for _, nd := range r.elem.(*treeImage).Nodes {
+			if v, err := strconv.ParseInt(nd.Id, 10, 64); (err == nil) && (v >= m.nxNode) {
+				m.nxNode = v + 1
+			}
+		}
+	}
+	for _, r := range ours {
+		for _, nd := range r.elem.(*treeImage).Nodes {
+			m.nodes[nd.Id] = true
+		}
+	}
+}
+
+// Resolve returns the element of an entry that will be added to the merged
+// database, or nil, if the element is not added.
+func (m *merger) resolve(table jdh.Table, e *entry) interface{} {
+	d := &Diff{Table: table}
+	var oel, bel interface{}
+	if e.ours != nil {
+		d.Id, d.Name = e.ours.id, e.ours.name
+		oel = e.ours.elem
+	}
+	if e.base != nil {
+		bel = e.base.elem
+	}
+	if e.other == nil {
+		if (e.ours == nil) || (bel == nil) {
+			return oel
+		}
+		if len(diffFields(oel, bel)) == 0 {
+			// removed in the other database
+			d.Kind = Removed
+			m.diffs = append(m.diffs, d)
+			return nil
+		}
+		d.Kind, d.Note = Conflict, "changed in the first database, removed in the other"
+		m.diffs = append(m.diffs, d)
+		return oel
+	}
+	d.Other = e.other.id
+	if len(d.Name) == 0 {
+		d.Name = e.other.name
+	}
+	tel := translate(table, e.other.elem, m.match)
+	if e.ours == nil {
+		if bel == nil {
+			d.Kind = Added
+			m.diffs = append(m.diffs, d)
+			return m.fromOther(table, e.other)
+		}
+		if len(diffFields(tel, bel)) > 0 {
+			d.Kind, d.Note = Conflict, "removed in the first database, changed in the other"
+			m.diffs = append(m.diffs, d)
+		}
+		return nil
+	}
+	d.Fields = diffFields(oel, tel)
+	if len(d.Fields) == 0 {
+		return oel
+	}
+	if bel != nil {
+		if len(diffFields(tel, bel)) == 0 {
+			// changed only in the first database
+			return oel
+		}
+		if len(diffFields(oel, bel)) == 0 {
+			// changed only in the other database
+			d.Kind = Changed
+			m.diffs = append(m.diffs, d)
+			return m.fromOther(table, e.other)
+		}
+	}
+	d.Kind, d.Note = Conflict, "changed in both databases"
+	m.diffs = append(m.diffs, d)
+	return oel
+}
+
+// FromOther returns the element of a record of the other database, with
+// the ids of the merged database.
+func (m *merger) fromOther(table jdh.Table, r *record) interface{} {
+	el := translate(table, r.elem, m.ids)
+	switch e := el.(type) {
+	case *jdh.Dataset:
+		e.Id = m.ids[table][r.id]
+	case *jdh.Taxon:
+		e.Id = m.ids[table][r.id]
+	case *jdh.Specimen:
+		e.Id = m.ids[table][r.id]
+	case *jdh.Raster:
+		e.Id = m.ids[table][r.id]
+	case *treeImage:
+		e.Tree.Id = m.ids[table][r.id]
+		nids := make(map[string]string)
+		for _, nd := range e.Nodes {
+			id := nd.Id
+			if m.nodes[id] {
+				id = strconv.FormatInt(m.nxNode, 10)
+				m.nxNode++
+			}
+			m.nodes[id] = true
+			nids[nd.Id] = id
+			nd.Id = id
+			nd.Tree = e.Tree.Id
+			if len(nd.Parent) > 0 {
+				nd.Parent = nids[nd.Parent]
+			}
+		}
+	}
+	return el
+}
+
+// Add adds the elements of a table to the merged database. Elements that
+// can not be added are reported as conflicts.
+func (m *merger) add(table jdh.Table, elems []interface{}) {
+	m.db.lock.Lock()
+	defer m.db.lock.Unlock()
+	if table == jdh.Taxonomy {
+		elems = sortTaxa(elems)
+	}
+	for _, el := range elems {
+		var err error
+		if img, ok := el.(*treeImage); ok {
+			img.Tree.Root = ""
+			if err = m.db.tr.insertTree(img.Tree); err == nil {
+				for _, nd := range img.Nodes {
+					if err = m.db.tr.insertNode(nd); err != nil {
+						break
+					}
+				}
+			}
+		} else {
+			err = m.db.insert(el)
+		}
+		if err != nil {
+			m.diffs = append(m.diffs, &Diff{
+				Table: table,
+				Kind:  Conflict,
+				Id:    elemId(el),
+				Note:  "not merged: " + err.Error(),
+			})
+		}
+	}
+}
+
+// AddActs adds the nomenclatural acts of both databases to the merged
+// database. Acts of the other database are added with new ids, except if
+// the act is also in the first database (e.g. an act made before the
+// databases diverged). Acts of taxa that are not in the merged database
+// are ignored.
+func (m *merger) addActs(db, odb *DB) {
+	m.db.lock.Lock()
+	defer m.db.lock.Unlock()
+	key := func(act *jdh.TaxAct) string {
+		return act.Taxon + " " + act.Date.UTC().String() + " " + act.NewParent
+	}
+	in := make(map[string]bool)
+	for _, act := range db.acts() {
+		cp := translate(jdh.TaxActs, act, nil).(*jdh.TaxAct)
+		if err := m.db.ta.insert(cp); err == nil {
+			in[key(cp)] = true
+		}
+	}
+	for _, act := range odb.acts() {
+		cp := translate(jdh.TaxActs, act, m.ids).(*jdh.TaxAct)
+		if in[key(cp)] {
+			continue
+		}
+		m.db.ta.add(cp)
+	}
+}
+
+// Acts returns the nomenclatural acts of a database, sorted by id.
+func (db *DB) acts() []*jdh.TaxAct {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	l := list.New()
+	for _, act := range db.ta.ids {
+		l.PushBack(act)
+	}
+	var ls []*jdh.TaxAct
+	for _, v := range sortedList(l, actId) {
+		ls = append(ls, v.(*jdh.TaxAct))
+	}
+	return ls
+}
+
+// SortTaxa sorts a list of taxa, so the parents are always before its
+// descendants.
+func sortTaxa(elems []interface{}) []interface{} {
+	waiting := make(map[string]bool)
+	for _, el := range elems {
+		waiting[el.(*jdh.Taxon).Id] = true
+	}
+	sorted := make([]interface{}, 0, len(elems))
+	for len(elems) > 0 {
+		var next []interface{}
+		for _, el := range elems {
+			tax := el.(*jdh.Taxon)
+			if (tax.Parent != tax.Id) && waiting[tax.Parent] {
+				next = append(next, el)
+				continue
+			}
+			sorted = append(sorted, el)
+			delete(waiting, tax.Id)
+		}
+		if len(next) == len(elems) {
+			// a cycle, the taxa will fail when added.
+			return append(sorted, next...)
+		}
+		elems = next
+	}
+	return sorted
+}
+
+// Records returns the records of a table, sorted by id.
+func (db *DB) records(table jdh.Table) []*record {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	var ls []*record
+	switch table {
+	case jdh.Datasets:
+		for _, d := range db.d.ids {
+			ls = append(ls, &record{id: d.data.Id, name: d.data.Title, extern: d.data.Extern, elem: d.data})
+		}
+	case jdh.Taxonomy:
+		for _, t := range db.t.ids {
+			ls = append(ls, &record{id: t.data.Id, name: t.data.Name, parent: t.data.Parent, extern: t.data.Extern, elem: t.data})
+		}
+	case jdh.Specimens:
+		for _, s := range db.s.ids {
+			ls = append(ls, &record{id: s.data.Id, name: s.data.Catalog, extern: s.data.Extern, elem: s.data})
+		}
+	case jdh.RasDistros:
+		for _, r := range db.rd.ids {
+			ls = append(ls, &record{id: r.data.Id, extern: r.data.Extern, elem: r.data})
+		}
+	case jdh.Trees:
+		for _, ph := range db.tr.ids {
+			img := &treeImage{Tree: ph.data}
+			if ph.root != nil {
+				ph.root.visit(func(nd *node) {
+					img.Nodes = append(img.Nodes, nd.data)
+				})
+			}
+			ls = append(ls, &record{id: ph.data.Id, name: ph.data.Name, extern: ph.data.Extern, elem: img})
+		}
+	}
+	ls = uniqRecords(ls)
+	sort.Sort(recsById(ls))
+	// the records are copies, so they can be modified.
+	for _, r := range ls {
+		r.elem = translate(table, r.elem, nil)
+	}
+	return ls
+}
+
+// UniqRecords removes the repeated records of a list. Some tables index
+// the elements by its extern ids, or catalog codes, so an element can be
+// found more than once.
+func uniqRecords(ls []*record) []*record {
+	seen := make(map[string]bool)
+	u := ls[:0]
+	for _, r := range ls {
+		if seen[r.id] {
+			continue
+		}
+		seen[r.id] = true
+		u = append(u, r)
+	}
+	return u
+}
+
+// recsById sorts records by its id. Numeric ids are sorted by its value.
+type recsById []*record
+
+func (ls recsById) Len() int           { return len(ls) }
+func (ls recsById) Swap(i, j int)      { ls[i], ls[j] = ls[j], ls[i] }
+func (ls recsById) Less(i, j int) bool { return idLess(ls[i].id, ls[j].id) }
+
+// Match matches the records of the other database with the records of the
+// first database, and returns a map of other-id:id. Records are matched by
+// its id, and then by its extern ids. Records that are not in the base (all
+// the records, if there is no base) can be added independently in each
+// database, so they are only matched by id if they have the same name, and
+// the same parent. The records of the other database that are not matched
+// receive a new id if its id is used in the first database (see setIds).
+func match(ours, theirs []*record, base map[string]*record) map[string]string {
+	ids := make(map[string]*record)
+	ext := make(map[string]string)
+	for _, r := range ours {
+		ids[r.id] = r
+		for _, e := range r.extern {
+			ext[e] = r.id
+		}
+	}
+	m := make(map[string]string)
+	used := make(map[string]bool)
+	added := make(map[string]bool) // records matched by id, not in base
+	for _, r := range theirs {
+		o, ok := ids[r.id]
+		if !ok {
+			continue
+		}
+		if _, ok := base[r.id]; !ok {
+			if o.name != r.name {
+				continue
+			}
+			added[r.id] = true
+		}
+		m[r.id] = o.id
+		used[o.id] = true
+	}
+	for _, r := range theirs {
+		if _, ok := m[r.id]; ok {
+			continue
+		}
+		for _, e := range r.extern {
+			if id, ok := ext[e]; ok && !used[id] {
+				m[r.id] = id
+				used[id] = true
+				break
+			}
+		}
+	}
+	// the parents are compared after all the records are matched, and
+	// a record without the same parent can make its descendants
+	// different.
+	for changed := true; changed; {
+		changed = false
+		for _, r := range theirs {
+			if !added[r.id] {
+				continue
+			}
+			p := ids[m[r.id]].parent
+			if (len(r.parent) == 0) && (len(p) == 0) {
+				continue
+			}
+			if id, ok := m[r.parent]; ok && (id == p) {
+				continue
+			}
+			delete(m, r.id)
+			delete(added, r.id)
+			changed = true
+		}
+	}
+	return m
+}
+
+// Entries returns the entries of matched records.
+func entries(ours, theirs []*record, base map[string]*record, match map[string]string) []*entry {
+	var ls []*entry
+	idx := make(map[string]*entry)
+	for _, r := range ours {
+		e := &entry{ours: r, base: base[r.id]}
+		idx[r.id] = e
+		ls = append(ls, e)
+	}
+	for _, r := range theirs {
+		if id, ok := match[r.id]; ok {
+			idx[id].other = r
+			continue
+		}
+		ls = append(ls, &entry{other: r, base: base[r.id]})
+	}
+	return ls
+}
+
+// ref is a reference to an element of a table.
+type ref struct {
+	table jdh.Table
+	id    *string
+}
+
+// Refs returns the references of an element to elements of other tables.
+func refs(elem interface{}) []ref {
+	switch e := elem.(type) {
+	case *jdh.Taxon:
+		return []ref{{jdh.Taxonomy, &e.Parent}}
+	case *jdh.Specimen:
+		return []ref{{jdh.Taxonomy, &e.Taxon}, {jdh.Datasets, &e.Dataset}}
+	case *jdh.Raster:
+		return []ref{{jdh.Taxonomy, &e.Taxon}}
+	case *jdh.TaxAct:
+		return []ref{{jdh.Taxonomy, &e.Taxon}, {jdh.Taxonomy, &e.OldParent}, {jdh.Taxonomy, &e.NewParent}}
+	case *treeImage:
+		var rs []ref
+		for _, nd := range e.Nodes {
+			rs = append(rs, ref{jdh.Taxonomy, &nd.Taxon})
+		}
+		return rs
+	}
+	return nil
+}
+
+// Translate returns a copy of an element, in which its references are
+// translated using a map of id:new-id of each table.
+func translate(table jdh.Table, elem interface{}, ids map[jdh.Table]map[string]string) interface{} {
+	b, err := json.Marshal(elem)
+	if err != nil {
+		// all database elements can be encoded.
+		panic(err)
+	}
+	var cp interface{}
+	if table == jdh.Trees {
+		img := &treeImage{}
+		err = json.Unmarshal(b, img)
+		cp = img
+	} else {
+		cp, err = decodeElem(table, b)
+	}
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range refs(cp) {
+		if id, ok := ids[r.table][*r.id]; ok {
+			*r.id = id
+		}
+	}
+	return cp
+}
+
+// DiffFields returns the fields with different values in two elements.
+// Ids are ignored. Trees are compared with its nodes, and nodes are
+// compared by its position in the tree.
+func diffFields(a, b interface{}) []string {
+	fa, fb := fields(a), fields(b)
+	var fs []string
+	for k, v := range fa {
+		if w, ok := fb[k]; !ok || !bytes.Equal(v, w) {
+			fs = append(fs, k)
+		}
+	}
+	for k := range fb {
+		if _, ok := fa[k]; !ok {
+			fs = append(fs, k)
+		}
+	}
+	sort.Strings(fs)
+	return fs
+}
+
+// Fields returns the encoded fields of an element, without its id.
+func fields(elem interface{}) map[string]json.RawMessage {
+	f := make(map[string]json.RawMessage)
+	img, isTree := elem.(*treeImage)
+	if isTree {
+		elem = img.Tree
+	}
+	b, _ := json.Marshal(elem)
+	json.Unmarshal(b, &f)
+	delete(f, "Id")
+	if !isTree {
+		return f
+	}
+	delete(f, "Root")
+	type canonNode struct {
+		Parent   int
+		Taxon    string
+		Len, Age uint
+		Comment  string
+	}
+	pos := make(map[string]int)
+	nodes := make([]canonNode, 0, len(img.Nodes))
+	for i, nd := range img.Nodes {
+		pos[nd.Id] = i + 1
+		nodes = append(nodes, canonNode{
+			Parent:  pos[nd.Parent],
+			Taxon:   nd.Taxon,
+			Len:     nd.Len,
+			Age:     nd.Age,
+			Comment: nd.Comment,
+		})
+	}
+	f["Nodes"], _ = json.Marshal(nodes)
+	return f
+}
+
+// ElemId returns the id of an element.
+func elemId(elem interface{}) string {
+	switch e := elem.(type) {
+	case *jdh.Dataset:
+		return e.Id
+	case *jdh.Taxon:
+		return e.Id
+	case *jdh.Specimen:
+		return e.Id
+	case *jdh.Raster:
+		return e.Id
+	case *treeImage:
+		return e.Tree.Id
+	}
+	return ""
+}
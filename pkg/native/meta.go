@@ -0,0 +1,73 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// meta file, it stores the metadata of the database.
+const metaFile = "meta"
+
+// Version is the version of the format of the database files. Databases
+// created before the meta file was introduced have version 0.
+const Version = 2
+
+// Meta is the metadata of a database.
+type Meta struct {
+	// version of the format of the database files.
+	Version int
+}
+
+// FormatVersion returns the version of the format of the database stored
+// in a given path. A directory without a database has the current
+// version.
+func FormatVersion(path string) (int, error) {
+	f, err := os.Open(filepath.Join(path, metaFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+		if isEmpty(path) {
+			return Version, nil
+		}
+		return 0, nil
+	}
+	defer f.Close()
+	m := &Meta{}
+	if err := json.NewDecoder(f).Decode(m); err != nil {
+		return 0, errors.New("invalid meta file: " + err.Error())
+	}
+	return m.Version, nil
+}
+
+// IsEmpty returns true if there is no data in the database stored in a
+// given path.
+func isEmpty(path string) bool {
+	for _, fn := range append([]string{jourFile, histFile}, tabFiles...) {
+		if fi, err := os.Stat(filepath.Join(path, fn)); (err == nil) && (fi.Size() > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteMeta writes the meta file of a database.
+func writeMeta(path string, version int) error {
+	p := filepath.Join(path, metaFile)
+	f, err := createNew(p)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(&Meta{Version: version})
+	if err = closeNew(f, err); err != nil {
+		os.Remove(p + newExt)
+		return err
+	}
+	return os.Rename(p+newExt, p)
+}
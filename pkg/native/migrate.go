@@ -0,0 +1,278 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// A migration is a step that transforms the files of a database from a
+// format version into the next one.
+type migration struct {
+	desc string // description of the step
+	auto bool   // if true, the step is applied when the database is opened
+
+	// elem transforms an element of a table, decoded as a JSON object.
+	// It is applied to the elements stored in the table files, the
+	// journal and the history. If nil, the elements are not modified.
+	elem func(table jdh.Table, e map[string]json.RawMessage) error
+}
+
+// migrations is the registry of migration steps. The step at index i
+// transforms a database of version i into a database of version i+1.
+var migrations = []migration{
+	{desc: "add the meta file", auto: true},
+	{desc: "encode taxon ranks by name", auto: true, elem: rankNames},
+}
+
+// fileTables is the table stored in each table file.
+var fileTables = map[string]jdh.Table{
+	dsetFile:   jdh.Datasets,
+	taxFile:    jdh.Taxonomy,
+	speFile:    jdh.Specimens,
+	distroFile: jdh.RasDistros,
+	treFile:    jdh.Trees,
+	nodFile:    jdh.Nodes,
+	actFile:    jdh.TaxActs,
+}
+
+// Migrate migrates the database stored in a given path to the current
+// format version, and returns the version of the database before the
+// migration. The database can not be used by another process during the
+// migration.
+func Migrate(path string) (int, error) {
+	lck, err := lockDir(path)
+	if err != nil {
+		if err == errLocked {
+			return 0, errors.New("database " + path + " in use by another process")
+		}
+		return 0, err
+	}
+	defer lck.Close()
+	if err := recoverCommit(path); err != nil {
+		return 0, err
+	}
+	return upgrade(path, false)
+}
+
+// Upgrade applies the migration steps required by the database stored in
+// a given path, and returns the version of the database before the
+// upgrade. If auto is true, only the steps that are applied when the
+// database is opened are allowed.
+func upgrade(path string, auto bool) (int, error) {
+	v, err := FormatVersion(path)
+	if err != nil {
+		return 0, err
+	}
+	if v > Version {
+		return v, errors.New("database format version " + strconv.Itoa(v) + " not supported (newer than " + strconv.Itoa(Version) + ")")
+	}
+	for i := v; i < Version; i++ {
+		if auto && !migrations[i].auto {
+			return v, errors.New("database format version " + strconv.Itoa(i) + " is outdated, the database must be migrated")
+		}
+		if err := migrations[i].apply(path, i); err != nil {
+			return v, errors.New("migration to version " + strconv.Itoa(i+1) + " (" + migrations[i].desc + "): " + err.Error())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(path, metaFile)); os.IsNotExist(err) {
+		return v, writeMeta(path, Version)
+	}
+	return v, nil
+}
+
+// Apply applies a migration step on the database stored in a given path,
+// that is in the version v. The new files are installed as in a commit,
+// so an interrupted migration is completed when the database is opened
+// again.
+func (m *migration) apply(path string, v int) error {
+	if m.elem == nil {
+		return writeMeta(path, v+1)
+	}
+	var files []string
+	for _, fn := range tabFiles {
+		table := fileTables[fn]
+		ok, err := rewrite(filepath.Join(path, fn), func(dec *json.Decoder) (interface{}, error) {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			return m.convert(table, raw)
+		})
+		if err != nil {
+			removeNew(path, files)
+			return err
+		}
+		if ok {
+			files = append(files, fn)
+		}
+	}
+	ok, err := rewrite(filepath.Join(path, jourFile), func(dec *json.Decoder) (interface{}, error) {
+		o := &op{}
+		if err := dec.Decode(o); err != nil {
+			// as in a replay, the journal ends at the first
+			// invalid operation.
+			return nil, io.EOF
+		}
+		return o, m.convertOp(o)
+	})
+	if err != nil {
+		removeNew(path, files)
+		return err
+	}
+	if ok {
+		files = append(files, jourFile)
+	}
+	ok, err = rewrite(filepath.Join(path, histFile), func(dec *json.Decoder) (interface{}, error) {
+		c := &change{}
+		if err := dec.Decode(c); err != nil {
+			return nil, io.EOF
+		}
+		for _, o := range c.Undo {
+			if err := m.convertOp(o); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	})
+	if err != nil {
+		removeNew(path, files)
+		return err
+	}
+	if ok {
+		files = append(files, histFile)
+	}
+	p := filepath.Join(path, metaFile)
+	f, err := createNew(p)
+	if err == nil {
+		err = closeNew(f, json.NewEncoder(f).Encode(&Meta{Version: v + 1}))
+	}
+	files = append(files, metaFile)
+	if err != nil {
+		removeNew(path, files)
+		return err
+	}
+	return install(path, files)
+}
+
+// RankNames encodes the rank of a taxon by its name, instead of the numeric
+// value used before the intermediate ranks were added.
+func rankNames(table jdh.Table, e map[string]json.RawMessage) error {
+	if table != jdh.Taxonomy {
+		return nil
+	}
+	raw, ok := e["Rank"]
+	if !ok {
+		return nil
+	}
+	var r jdh.Rank
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return err
+	}
+	var err error
+	e["Rank"], err = json.Marshal(r)
+	return err
+}
+
+// Rewrite writes the new version of a file, in which each value is read
+// from the original file with next. It returns false if the file does not
+// exist.
+func rewrite(p string, next func(dec *json.Decoder) (interface{}, error)) (bool, error) {
+	in, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer in.Close()
+	f, err := createNew(p)
+	if err != nil {
+		return false, err
+	}
+	dec := json.NewDecoder(in)
+	enc := json.NewEncoder(f)
+	for {
+		v, err := next(dec)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			if err = closeNew(f, err); err != nil {
+				os.Remove(p + newExt)
+				return false, err
+			}
+			return true, nil
+		}
+		if err := enc.Encode(v); err != nil {
+			closeNew(f, err)
+			os.Remove(p + newExt)
+			return false, err
+		}
+	}
+}
+
+// Convert applies the migration to an encoded element of a table. The
+// converted element is decoded into the type of the elements of the table,
+// so it is encoded with the same field order used in the table files.
+func (m *migration) convert(table jdh.Table, raw json.RawMessage) (json.RawMessage, error) {
+	if (len(raw) == 0) || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return raw, nil
+	}
+	e := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, err
+	}
+	if err := m.elem(table, e); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	elem, err := decodeElem(table, b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(elem)
+}
+
+// ConvertOp applies the migration to the element of an operation.
+func (m *migration) convertOp(o *op) error {
+	if len(o.Elem) == 0 {
+		return nil
+	}
+	if (o.Query == restore) && (o.Table == jdh.Trees) {
+		img := &struct {
+			Tree  json.RawMessage
+			Nodes []json.RawMessage
+		}{}
+		if err := json.Unmarshal(o.Elem, img); err != nil {
+			return err
+		}
+		var err error
+		if img.Tree, err = m.convert(jdh.Trees, img.Tree); err != nil {
+			return err
+		}
+		for i, nd := range img.Nodes {
+			if img.Nodes[i], err = m.convert(jdh.Nodes, nd); err != nil {
+				return err
+			}
+		}
+		o.Elem, err = json.Marshal(img)
+		return err
+	}
+	var err error
+	o.Elem, err = m.convert(o.Table, o.Elem)
+	return err
+}
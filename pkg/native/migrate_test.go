@@ -0,0 +1,63 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// TestMigrateRanks checks that a database of version 1, with numeric
+// ranks, is migrated to the current version, with the rank names.
+func TestMigrateRanks(t *testing.T) {
+	path := t.TempDir()
+	writeFile(t, path, metaFile, `{"Version":1}`)
+	writeFile(t, path, taxFile,
+		`{"Id":"1","Name":"Animalia","Rank":1,"IsValid":true}`,
+		`{"Id":"2","Name":"Carabus","Rank":6,"Parent":"1","IsValid":true}`,
+		`{"Id":"3","Name":"Carabus auratus","Rank":7,"Parent":"2","IsValid":true}`,
+		`{"Id":"4","Name":"Aus","Rank":99,"Parent":"1","IsValid":true}`,
+	)
+	writeFile(t, path, jourFile,
+		`{"Query":"add","Table":"taxonomy","Elem":{"Id":"5","Name":"Carabidae","Rank":5,"Parent":"1","IsValid":true}}`,
+	)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if v, err := FormatVersion(path); err != nil {
+		t.Fatal(err)
+	} else if v != Version {
+		t.Errorf("version: got %d, want %d", v, Version)
+	}
+	for id, rank := range map[string]jdh.Rank{
+		"1": jdh.Kingdom,
+		"2": jdh.Genus,
+		"3": jdh.Species,
+		"4": jdh.Unranked,
+		"5": jdh.Family,
+	} {
+		v, err := db.Get(jdh.Taxonomy, id)
+		if err != nil {
+			t.Errorf("taxon %s: %v", id, err)
+			continue
+		}
+		if r := v.(*jdh.Taxon).Rank; r != rank {
+			t.Errorf("taxon %s: rank %s, want %s", id, r, rank)
+		}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(path, taxFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"Rank":"genus"`) {
+		t.Errorf("ranks of the taxonomy file not encoded by name:\n%s", b)
+	}
+}
@@ -9,7 +9,12 @@ import (
 	"container/list"
 	"encoding/json"
 	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/js-arias/jdh/pkg/jdh"
 )
@@ -24,17 +29,97 @@ type DB struct {
 	s  *specimens
 	rd *distros
 	tr *trees
+	ta *taxActs
 
-	lock sync.Mutex
+	jour   *journal       // journal of uncommitted operations
+	hist   *history       // most recent changes
+	txs    map[string]*Tx // transactions in progress
+	commit time.Time      // time of the last commit
+	lck    *os.File       // lock file
+
+	// subscriptions to the changes of the database
+	watches map[*Watch]bool
+	wlock   sync.Mutex
+
+	// the database can be read by many goroutines at the same time,
+	// but any modification is exclusive.
+	lock sync.RWMutex
 }
 
-// Open opens a database in a given path.
-func Open(path string) *DB {
+// Open opens a database in a given path. If a previous commit was
+// interrupted, it will be completed, and then, any operation stored in the
+// journal of the database will be applied. The database can only be
+// opened by a single process at the same time. Databases with an outdated
+// format are only opened if they can be upgraded automatically, otherwise
+// they must be migrated with Migrate.
+func Open(path string) (*DB, error) {
+	lck, err := lockDir(path)
+	if err != nil {
+		if err == errLocked {
+			return nil, errors.New("database " + path + " in use by another process")
+		}
+		return nil, err
+	}
+	if err := recoverCommit(path); err != nil {
+		lck.Close()
+		return nil, err
+	}
+	if _, err := upgrade(path, true); err != nil {
+		lck.Close()
+		return nil, err
+	}
+	db := &DB{path: path, lck: lck}
+	db.openTables()
+	if db.jour, err = openJournal(db); err != nil {
+		lck.Close()
+		return nil, err
+	}
+	if db.hist, err = openHistory(db); err != nil {
+		db.jour.close()
+		lck.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenReadOnly opens the database stored in a given path only to read it
+// (e.g. to compare it with other database). The database is not locked,
+// and its files are never modified, so it can be read while it is used by
+// other process. The operations stored in the journal are only applied in
+// memory. Databases with an interrupted commit, or with an outdated
+// format, must be opened with Open before they can be read. The returned
+// database must not be modified, nor closed.
+func openReadOnly(path string) (*DB, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, errors.New("database " + path + " is not a directory")
+	}
+	if _, err := os.Stat(filepath.Join(path, comFile)); err == nil {
+		return nil, errors.New("database " + path + " has an interrupted commit, it must be opened to complete the commit")
+	}
+	if v, err := FormatVersion(path); err != nil {
+		return nil, err
+	} else if v != Version {
+		return nil, errors.New("database " + path + " format version " + strconv.Itoa(v) + " is not the current version, the database must be migrated")
+	}
 	db := &DB{path: path}
+	db.openTables()
+	if f, err := os.Open(filepath.Join(path, jourFile)); err == nil {
+		db.replay(f)
+		f.Close()
+	}
+	return db, nil
+}
+
+// OpenTables reads the tables of the database.
+func (db *DB) openTables() {
 	db.d = openDatasets(db)
 	db.t = openTaxonomy(db)
 	var done sync.WaitGroup
-	done.Add(3)
+	done.Add(4)
 	go func() {
 		db.s = openSpecimens(db)
 		done.Done()
@@ -47,53 +132,106 @@ func Open(path string) *DB {
 		db.tr = openTrees(db)
 		done.Done()
 	}()
+	go func() {
+		db.ta = openTaxActs(db)
+		done.Done()
+	}()
 	done.Wait()
-	return db
+	// the time of the last commit is the time of the most recent table
+	// file.
+	for _, f := range []string{dsetFile, taxFile, speFile, distroFile, treFile, nodFile, actFile} {
+		if fi, err := os.Stat(filepath.Join(db.path, f)); (err == nil) && fi.ModTime().After(db.commit) {
+			db.commit = fi.ModTime()
+		}
+	}
 }
 
-// Add adds a new element to the database.
-func (db *DB) Add(table jdh.Table, dec *json.Decoder) (string, error) {
+// Close closes the database. Uncommitted operations are kept in the
+// journal, and any watch of the database is closed.
+func (db *DB) Close() error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
+	err := db.jour.close()
+	if herr := db.hist.close(); (herr != nil) && (err == nil) {
+		err = herr
+	}
+	db.lck.Close()
+	db.closeWatches()
+	return err
+}
+
+// Add adds a new element to the database.
+func (db *DB) Add(table jdh.Table, dec *json.Decoder) (string, error) {
+	return db.doAdd(nil, table, dec)
+}
+
+// DecodeElem decodes an element of a given table.
+func decodeElem(table jdh.Table, blob json.RawMessage) (interface{}, error) {
+	var elem interface{}
 	switch table {
 	case jdh.Datasets:
-		set := &jdh.Dataset{}
-		if err := dec.Decode(set); err != nil {
-			return "", err
-		}
-		return db.d.add(set)
+		elem = &jdh.Dataset{}
 	case jdh.Nodes:
-		nod := &jdh.Node{}
-		if err := dec.Decode(nod); err != nil {
-			return "", err
-		}
-		return db.tr.addNode(nod)
+		elem = &jdh.Node{}
 	case jdh.RasDistros:
-		ras := &jdh.Raster{}
-		if err := dec.Decode(ras); err != nil {
-			return "", err
-		}
-		return db.rd.add(ras)
+		elem = &jdh.Raster{}
 	case jdh.Specimens:
-		spe := &jdh.Specimen{}
-		if err := dec.Decode(spe); err != nil {
-			return "", err
-		}
-		return db.s.add(spe)
+		elem = &jdh.Specimen{}
+	case jdh.TaxActs:
+		elem = &jdh.TaxAct{}
 	case jdh.Taxonomy:
-		tax := &jdh.Taxon{}
-		if err := dec.Decode(tax); err != nil {
-			return "", err
-		}
-		return db.t.add(tax)
+		elem = &jdh.Taxon{}
 	case jdh.Trees:
-		phy := &jdh.Phylogeny{}
-		if err := dec.Decode(phy); err != nil {
-			return "", err
-		}
-		return db.tr.addTree(phy)
+		elem = &jdh.Phylogeny{}
+	default:
+		return nil, errors.New("add not implemented for table " + string(table))
+	}
+	if err := json.Unmarshal(blob, elem); err != nil {
+		return nil, err
+	}
+	return elem, nil
+}
+
+// Add adds a new element to its table, assigning a new id to the element.
+func (db *DB) add(elem interface{}) (string, error) {
+	switch e := elem.(type) {
+	case *jdh.Dataset:
+		return db.d.add(e)
+	case *jdh.Node:
+		return db.tr.addNode(e)
+	case *jdh.Raster:
+		return db.rd.add(e)
+	case *jdh.Specimen:
+		return db.s.add(e)
+	case *jdh.TaxAct:
+		return db.ta.add(e)
+	case *jdh.Taxon:
+		return db.t.add(e)
+	case *jdh.Phylogeny:
+		return db.tr.addTree(e)
+	}
+	return "", errors.New("add not implemented for element")
+}
+
+// Insert adds an element to its table, preserving the id of the element.
+func (db *DB) insert(elem interface{}) error {
+	switch e := elem.(type) {
+	case *jdh.Dataset:
+		return db.d.insert(e)
+	case *jdh.Node:
+		return db.tr.insertNode(e)
+	case *jdh.Raster:
+		return db.rd.insert(e)
+	case *jdh.Specimen:
+		return db.s.insert(e)
+	case *jdh.TaxAct:
+		return db.ta.insert(e)
+	case *jdh.Taxon:
+		return db.t.insert(e)
+	case *jdh.Phylogeny:
+		return db.tr.insertTree(e)
 	}
-	return "", errors.New("add not implemented for table " + string(table))
+	return errors.New("insert not implemented for element")
 }
 
 // commiter is a type that commits its data.
@@ -110,10 +248,102 @@ func doCommit(c commiter, done *sync.WaitGroup, e chan error) {
 	}()
 }
 
-// Commit commits the database.
+// Commit commits the database. The tables are written into new files, and
+// then, all of them are moved into place in a single step. If a
+// transaction is in progress, its operations are not committed, and they
+// are kept in the journal (transactions idle for too long are rolled
+// back).
 func (db *DB) Commit() error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
+	db.expire()
+	if len(db.txs) > 0 {
+		return db.commitOpen()
+	}
+	files := db.changedFiles()
+	if len(files) == 0 {
+		if err := db.jour.truncate(); err != nil {
+			return err
+		}
+		db.commit = time.Now()
+		return nil
+	}
+	if err := db.writeTables(files); err != nil {
+		return err
+	}
+	if err := install(db.path, files); err != nil {
+		return err
+	}
+	db.d.changed = false
+	db.t.changed = false
+	db.s.changed = false
+	db.rd.changed = false
+	db.tr.changed = false
+	db.ta.changed = false
+	db.commit = time.Now()
+	return nil
+}
+
+// CommitOpen commits the database while a transaction is in progress. The
+// tables are read again from the hard disk, and the operations of the
+// journal are applied on them, except the operations of the transactions
+// in progress, and the operations that depend on them (see
+// replayCommitted). The committed tables are written into new
+// files, and the operations that were not applied are written into a new
+// journal, then, all of them are moved into place in a single step. It
+// must be called with the database locked.
+func (db *DB) commitOpen() error {
+	p := filepath.Join(db.path, jourFile)
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	c := &DB{path: db.path}
+	c.openTables()
+	kept, err := c.replayCommitted(f, db.txs)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	files := c.changedFiles()
+	if err := c.writeTables(files); err != nil {
+		return err
+	}
+	files = append(files, jourFile)
+	nf, err := createNew(p)
+	if err != nil {
+		removeNew(db.path, files)
+		return err
+	}
+	enc := json.NewEncoder(nf)
+	for _, o := range kept {
+		if err = enc.Encode(o); err != nil {
+			break
+		}
+	}
+	if err := closeNew(nf, err); err != nil {
+		removeNew(db.path, files)
+		return err
+	}
+	if err := install(db.path, files); err != nil {
+		return err
+	}
+	// the old journal is now the backup, so the new journal is used.
+	nj, err := os.OpenFile(p, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	db.jour.close()
+	db.jour = &journal{f: nj, enc: json.NewEncoder(nj)}
+	// as the tables in memory include the operations of the
+	// transactions, they are still marked as changed.
+	db.commit = time.Now()
+	return nil
+}
+
+// WriteTables writes the given tables files into new files. If the
+// writing fails, the new files are removed.
+func (db *DB) writeTables(files []string) error {
 	ec := make(chan error)
 	go func() {
 		var done sync.WaitGroup
@@ -122,6 +352,7 @@ func (db *DB) Commit() error {
 		doCommit(db.s, &done, ec)
 		doCommit(db.rd, &done, ec)
 		doCommit(db.tr, &done, ec)
+		doCommit(db.ta, &done, ec)
 		done.Wait()
 		close(ec)
 	}()
@@ -131,13 +362,64 @@ func (db *DB) Commit() error {
 			err = e
 		}
 	}
-	return err
+	if err != nil {
+		removeNew(db.path, files)
+		return err
+	}
+	return nil
+}
+
+// ChangedFiles returns the files of the tables that has changed.
+func (db *DB) changedFiles() []string {
+	var files []string
+	if db.d.changed {
+		files = append(files, dsetFile)
+	}
+	if db.t.changed {
+		files = append(files, taxFile)
+	}
+	if db.s.changed {
+		files = append(files, speFile)
+	}
+	if db.rd.changed {
+		files = append(files, distroFile)
+	}
+	if db.tr.changed {
+		files = append(files, treFile, nodFile)
+	}
+	if db.ta.changed {
+		files = append(files, actFile)
+	}
+	return files
+}
+
+// Status returns the status of the tables of the database.
+func (db *DB) Status() *jdh.Status {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return &jdh.Status{
+		Commit: db.commit,
+		Tables: []jdh.TableStatus{
+			{Table: jdh.Datasets, Count: db.d.count(), Changed: db.d.changed},
+			{Table: jdh.Taxonomy, Count: db.t.count(), Changed: db.t.changed},
+			{Table: jdh.Specimens, Count: db.s.count(), Changed: db.s.changed},
+			{Table: jdh.RasDistros, Count: db.rd.count(), Changed: db.rd.changed},
+			{Table: jdh.Trees, Count: db.tr.countTrees(), Changed: db.tr.changed},
+			{Table: jdh.Nodes, Count: len(db.tr.nodes), Changed: db.tr.changed},
+			{Table: jdh.TaxActs, Count: len(db.ta.ids), Changed: db.ta.changed},
+		},
+		Txs: len(db.txs),
+	}
 }
 
 // Delete removes an element from the database.
 func (db *DB) Delete(table jdh.Table, vals []jdh.KeyValue) error {
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	_, err := db.do(nil, &op{Query: jdh.Delete, Table: table, Kvs: vals}, nil)
+	return err
+}
+
+// Delete removes an element from a table.
+func (db *DB) delete(table jdh.Table, vals []jdh.KeyValue) error {
 	switch table {
 	case jdh.Datasets:
 		return db.d.delete(vals)
@@ -147,6 +429,8 @@ func (db *DB) Delete(table jdh.Table, vals []jdh.KeyValue) error {
 		return db.rd.delete(vals)
 	case jdh.Specimens:
 		return db.s.delete(vals)
+	case jdh.TaxActs:
+		return db.ta.delete(vals)
 	case jdh.Taxonomy:
 		return db.t.delete(vals)
 	case jdh.Trees:
@@ -157,17 +441,39 @@ func (db *DB) Delete(table jdh.Table, vals []jdh.KeyValue) error {
 
 // Get returns an element from the database.
 func (db *DB) Get(table jdh.Table, id string) (interface{}, error) {
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return db.get(table, id)
+}
+
+// EncodeGet encodes an element of the database into a writer. As the
+// element is encoded while the database is locked, the element can not be
+// modified during the encoding.
+func (db *DB) EncodeGet(table jdh.Table, id string, w io.Writer) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	v, err := db.get(table, id)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Get returns an element from a table.
+func (db *DB) get(table jdh.Table, id string) (interface{}, error) {
 	switch table {
 	case jdh.Datasets:
 		return db.d.get(id)
+	case jdh.History:
+		return db.hist.get(id)
 	case jdh.Nodes:
 		return db.tr.getNode(id)
 	case jdh.RasDistros:
 		return db.rd.get(id)
 	case jdh.Specimens:
 		return db.s.get(id)
+	case jdh.TaxActs:
+		return db.ta.get(id)
 	case jdh.Taxonomy:
 		return db.t.get(id)
 	case jdh.Trees:
@@ -176,31 +482,177 @@ func (db *DB) Get(table jdh.Table, id string) (interface{}, error) {
 	return nil, errors.New("get not implemented for table " + string(table))
 }
 
-// List returns a list of elements from the database.
+// List returns a list of elements from the database. The keys KeyOffset
+// and KeyLimit can be used to retrieve only a part of the list.
 func (db *DB) List(table jdh.Table, vals []jdh.KeyValue) (*list.List, error) {
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return db.list(table, vals)
+}
+
+// EncodeList encodes the elements of a list query into a writer, and
+// returns the number of encoded elements. As the elements are encoded
+// while the database is locked, it should be used with small pages of the
+// list (using KeyOffset and KeyLimit keys), so the writer can be a
+// buffer, and the database is not blocked by a slow connection.
+func (db *DB) EncodeList(table jdh.Table, vals []jdh.KeyValue, w io.Writer) (int, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	l, err := db.list(table, vals)
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	for e := l.Front(); e != nil; e = e.Next() {
+		if err := enc.Encode(e.Value); err != nil {
+			return 0, err
+		}
+	}
+	return l.Len(), nil
+}
+
+// Cursor is a snapshot of the elements of a list query, that can be
+// encoded by pages. The elements of the list are retrieved only once, when
+// the cursor is created, so a change in the database between two pages
+// does not skip nor repeat elements of the list. Each element is encoded
+// with its values at the moment its page is encoded.
+type Cursor struct {
+	db    *DB
+	elems []interface{}
+	next  int // next element to be encoded
+}
+
+// Cursor returns a cursor with the elements of a list query. The keys
+// KeyOffset and KeyLimit can be used to retrieve only a part of the list.
+func (db *DB) Cursor(table jdh.Table, vals []jdh.KeyValue) (*Cursor, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	l, offset, limit, err := db.tableList(table, vals)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cursor{db: db}
+	page(l, offset, limit, func(v interface{}) {
+		c.elems = append(c.elems, v)
+	})
+	return c, nil
+}
+
+// Len returns the number of elements of the cursor that are not encoded.
+func (c *Cursor) Len() int {
+	return len(c.elems) - c.next
+}
+
+// Encode encodes up to n of the remaining elements of the cursor into a
+// writer, and returns the number of encoded elements. As the elements are
+// encoded while the database is locked, n should be small, and the writer
+// should be a buffer, so the database is not blocked by a slow connection.
+func (c *Cursor) Encode(w io.Writer, n int) (int, error) {
+	if n > c.Len() {
+		n = c.Len()
+	}
+	c.db.lock.RLock()
+	defer c.db.lock.RUnlock()
+	enc := json.NewEncoder(w)
+	for _, v := range c.elems[c.next : c.next+n] {
+		if err := enc.Encode(v); err != nil {
+			return 0, err
+		}
+	}
+	c.next += n
+	return n, nil
+}
+
+// List returns a list of elements from a table.
+func (db *DB) list(table jdh.Table, vals []jdh.KeyValue) (*list.List, error) {
+	l, offset, limit, err := db.tableList(table, vals)
+	if err != nil {
+		return nil, err
+	}
+	if (offset == 0) && (limit == 0) {
+		return l, nil
+	}
+	pl := list.New()
+	page(l, offset, limit, func(v interface{}) {
+		pl.PushBack(v)
+	})
+	return pl, nil
+}
+
+// TableList returns all the elements of a table that match a list query,
+// and the offset and limit values of the query.
+func (db *DB) tableList(table jdh.Table, vals []jdh.KeyValue) (l *list.List, offset, limit int, err error) {
+	if offset, limit, err = pageVals(vals); err != nil {
+		return nil, 0, 0, err
+	}
 	switch table {
 	case jdh.Datasets:
-		return db.d.list(vals)
+		l, err = db.d.list(vals)
+	case jdh.History:
+		l, err = db.hist.list(vals)
 	case jdh.Nodes:
-		return db.tr.listNode(vals)
+		l, err = db.tr.listNode(vals)
 	case jdh.RasDistros:
-		return db.rd.list(vals)
+		l, err = db.rd.list(vals)
 	case jdh.Specimens:
-		return db.s.list(vals)
+		l, err = db.s.list(vals)
+	case jdh.TaxActs:
+		l, err = db.ta.list(vals)
 	case jdh.Taxonomy:
-		return db.t.list(vals)
+		l, err = db.t.list(vals)
 	case jdh.Trees:
-		return db.tr.listTree(vals)
+		l, err = db.tr.listTree(vals)
+	default:
+		return nil, 0, 0, errors.New("list not implemented for table " + string(table))
+	}
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return l, offset, limit, nil
+}
+
+// Page calls fn with each element of a list in a page that starts at the
+// given offset, with up to limit elements (if limit is 0, the page has
+// all the elements after the offset).
+func page(l *list.List, offset, limit int, fn func(v interface{})) {
+	e := l.Front()
+	for i := 0; (i < offset) && (e != nil); i++ {
+		e = e.Next()
+	}
+	for n := 0; (e != nil) && ((limit == 0) || (n < limit)); n++ {
+		fn(e.Value)
+		e = e.Next()
+	}
+}
+
+// PageVals returns the offset and limit values of a list query.
+func pageVals(vals []jdh.KeyValue) (offset, limit int, err error) {
+	for _, kv := range vals {
+		if len(kv.Value) == 0 {
+			continue
+		}
+		switch kv.Key {
+		case jdh.KeyOffset:
+			if offset, err = strconv.Atoi(kv.Value[0]); (err != nil) || (offset < 0) {
+				return 0, 0, errors.New("invalid offset value: " + kv.Value[0])
+			}
+		case jdh.KeyLimit:
+			if limit, err = strconv.Atoi(kv.Value[0]); (err != nil) || (limit < 0) {
+				return 0, 0, errors.New("invalid limit value: " + kv.Value[0])
+			}
+		}
 	}
-	return nil, errors.New("list not implemented for table " + string(table))
+	return offset, limit, nil
 }
 
 // Set sets one or more values for a given element in the database.
 func (db *DB) Set(table jdh.Table, vals []jdh.KeyValue) error {
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	_, err := db.do(nil, &op{Query: jdh.Set, Table: table, Kvs: vals}, nil)
+	return err
+}
+
+// Set sets one or more values of an element in a table.
+func (db *DB) set(table jdh.Table, vals []jdh.KeyValue) error {
 	switch table {
 	case jdh.Datasets:
 		return db.d.set(vals)
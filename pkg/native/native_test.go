@@ -0,0 +1,395 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// addTaxon adds a taxon to a database.
+func addTaxon(t *testing.T, db *DB, name, rank, parent string) string {
+	blob := fmt.Sprintf(`{"Name":%q,"Rank":%q,"Parent":%q,"IsValid":true}`, name, rank, parent)
+	id, err := db.Add(jdh.Taxonomy, json.NewDecoder(strings.NewReader(blob)))
+	if err != nil {
+		t.Fatalf("add %s: %v", name, err)
+	}
+	return id
+}
+
+// TestConcurrent runs reads of the database at the same time as writes,
+// transactions and commits, so it should be run with the race detector.
+func TestConcurrent(t *testing.T) {
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := addTaxon(t, db, "Aus", "genus", "")
+	var ids []string
+	for i := 0; i < 50; i++ {
+		ids = append(ids, addTaxon(t, db, fmt.Sprintf("Aus sp%d", i), "species", gen))
+	}
+
+	const steps = 100
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	run := func(f func(i int) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < steps; i++ {
+				if err := f(i); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	run(func(i int) error {
+		v, err := db.Get(jdh.Taxonomy, ids[i%len(ids)])
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		return json.NewEncoder(&buf).Encode(v)
+	})
+	run(func(i int) error {
+		var buf bytes.Buffer
+		_, err := db.EncodeList(jdh.Taxonomy, []jdh.KeyValue{{Key: jdh.TaxChildren, Value: []string{gen}}}, &buf)
+		return err
+	})
+	run(func(i int) error {
+		cur, err := db.Cursor(jdh.Taxonomy, []jdh.KeyValue{{Key: jdh.TaxChildren, Value: []string{gen}}})
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		for cur.Len() > 0 {
+			if _, err := cur.Encode(&buf, 7); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	run(func(i int) error {
+		db.Status()
+		_, err := db.List(jdh.History, nil)
+		return err
+	})
+	run(func(i int) error {
+		blob := fmt.Sprintf(`{"Name":"Aus new%d","Rank":"species","Parent":%q,"IsValid":true}`, i, gen)
+		_, err := db.Add(jdh.Taxonomy, json.NewDecoder(strings.NewReader(blob)))
+		return err
+	})
+	run(func(i int) error {
+		kvs := []jdh.KeyValue{
+			{Key: jdh.KeyId, Value: []string{ids[i%len(ids)]}},
+			{Key: jdh.KeyComment, Value: []string{fmt.Sprintf("comment %d", i)}},
+		}
+		return db.Set(jdh.Taxonomy, kvs)
+	})
+	run(func(i int) error {
+		tx := db.Begin("")
+		kvs := []jdh.KeyValue{
+			{Key: jdh.KeyId, Value: []string{ids[(i+25)%len(ids)]}},
+			{Key: jdh.TaxAuthority, Value: []string{fmt.Sprintf("Author %d", i)}},
+		}
+		if err := tx.Set(jdh.Taxonomy, kvs); err != nil {
+			return err
+		}
+		if i%2 == 0 {
+			return tx.Rollback()
+		}
+		return tx.Commit()
+	})
+	run(func(i int) error {
+		if i%10 != 0 {
+			return nil
+		}
+		// commits are accepted while a transaction is in progress
+		return db.Commit()
+	})
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if err := db.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if db, err = Open(db.path); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	l, err := db.List(jdh.Taxonomy, []jdh.KeyValue{{Key: jdh.TaxChildren, Value: []string{gen}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ids) + steps; l.Len() != n {
+		t.Errorf("children of %s: got %d, want %d", gen, l.Len(), n)
+	}
+}
+
+// TestCursor checks that a cursor is not changed by additions to the
+// database made between its pages.
+func TestCursor(t *testing.T) {
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	gen := addTaxon(t, db, "Aus", "genus", "")
+	for i := 0; i < 10; i++ {
+		addTaxon(t, db, fmt.Sprintf("Aus sp%d", i), "species", gen)
+	}
+	vals := []jdh.KeyValue{
+		{Key: jdh.TaxChildren, Value: []string{gen}},
+		{Key: jdh.KeyOffset, Value: []string{"2"}},
+	}
+	cur, err := db.Cursor(jdh.Taxonomy, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cur.Len() != 8 {
+		t.Fatalf("cursor length: got %d, want 8", cur.Len())
+	}
+	var buf bytes.Buffer
+	seen := make(map[string]bool)
+	for cur.Len() > 0 {
+		buf.Reset()
+		if _, err := cur.Encode(&buf, 3); err != nil {
+			t.Fatal(err)
+		}
+		// additions sorted before the cursor elements
+		addTaxon(t, db, fmt.Sprintf("Aaa sp%d", cur.Len()), "species", gen)
+		dec := json.NewDecoder(&buf)
+		for {
+			tax := &jdh.Taxon{}
+			if err := dec.Decode(tax); err != nil {
+				break
+			}
+			if seen[tax.Id] {
+				t.Errorf("taxon %s (%s) encoded twice", tax.Id, tax.Name)
+			}
+			seen[tax.Id] = true
+		}
+	}
+	if len(seen) != 8 {
+		t.Errorf("encoded taxa: got %d, want 8", len(seen))
+	}
+
+	vals = append(vals, jdh.KeyValue{Key: jdh.KeyLimit, Value: []string{"4"}})
+	if cur, err = db.Cursor(jdh.Taxonomy, vals); err != nil {
+		t.Fatal(err)
+	}
+	if cur.Len() != 4 {
+		t.Errorf("cursor length with limit: got %d, want 4", cur.Len())
+	}
+}
+
+// TestStatus checks that the elements indexed by its extern ids are counted
+// only once.
+func TestStatus(t *testing.T) {
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	blob := `{"Name":"Aus","Rank":"genus","IsValid":true,"Extern":["gbif:1","ncbi:2"]}`
+	if _, err := db.Add(jdh.Taxonomy, json.NewDecoder(strings.NewReader(blob))); err != nil {
+		t.Fatal(err)
+	}
+	blob = `{"Catalog":"MLP 1","Taxon":"1","Extern":["gbif:3"]}`
+	if _, err := db.Add(jdh.Specimens, json.NewDecoder(strings.NewReader(blob))); err != nil {
+		t.Fatal(err)
+	}
+	for _, ts := range db.Status().Tables {
+		want := 0
+		if (ts.Table == jdh.Taxonomy) || (ts.Table == jdh.Specimens) {
+			want = 1
+		}
+		if ts.Count != want {
+			t.Errorf("status of %s: got %d elements, want %d", ts.Table, ts.Count, want)
+		}
+	}
+}
+
+// TestInvalidNodes checks that invalid nodes are kept in the nodes file
+// when the database is committed, and that they are reported by Check.
+func TestInvalidNodes(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if err := ioutil.WriteFile(filepath.Join(path, nodFile), []byte(`{"Id":"7","Tree":"9"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Add(jdh.Trees, json.NewDecoder(strings.NewReader(`{"Name":"tree"}`))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	probs, err := Check(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(probs) != 1) || (probs[0].File != nodFile) || (probs[0].Id != "7") {
+		t.Errorf("check: got %v, want node 7 reported", probs)
+	}
+}
+
+// TestMergeAdded checks that taxa added with the same id in each database
+// are not matched if they are different, and that the merged databases are
+// only read.
+func TestMergeAdded(t *testing.T) {
+	path, other := t.TempDir(), t.TempDir()
+	odb, err := Open(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := addTaxon(t, odb, "Aus", "genus", "")
+	sp := addTaxon(t, odb, "Aus cus", "species", gen)
+	blob := fmt.Sprintf(`{"Catalog":"MLP 1","Taxon":%q}`, sp)
+	if _, err := odb.Add(jdh.Specimens, json.NewDecoder(strings.NewReader(blob))); err != nil {
+		t.Fatal(err)
+	}
+	if err := odb.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	odb.Close()
+
+	// the first database is kept open while it is merged.
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	gen = addTaxon(t, db, "Aus", "genus", "")
+	if id := addTaxon(t, db, "Aus bus", "species", gen); id != sp {
+		t.Fatalf("taxon Aus bus: got id %s, want %s", id, sp)
+	}
+	if err := db.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "merged")
+	if _, err := Merge(path, other, "", out); err != nil {
+		t.Fatal(err)
+	}
+	mdb, err := Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+	ls := mdb.records(jdh.Taxonomy)
+	if len(ls) != 3 {
+		t.Fatalf("merged taxa: got %d, want 3", len(ls))
+	}
+	cus := ""
+	for _, r := range ls {
+		if r.name == "Aus cus" {
+			cus = r.id
+		}
+	}
+	if (len(cus) == 0) || (cus == sp) {
+		t.Fatalf("taxon Aus cus: got id %q, want a new id", cus)
+	}
+	spe := mdb.records(jdh.Specimens)
+	if (len(spe) != 1) || (spe[0].elem.(*jdh.Specimen).Taxon != cus) {
+		t.Errorf("specimen MLP 1: want taxon %s", cus)
+	}
+}
+
+// TestSimilar checks that similar taxa are found only once, even if they
+// have extern ids, and that names that start with a different letter, but
+// with the same pronunciation, are found.
+func TestSimilar(t *testing.T) {
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	blob := `{"Name":"Aus","Rank":"genus","IsValid":true,"Extern":["gbif:1","ncbi:2"]}`
+	gen, err := db.Add(jdh.Taxonomy, json.NewDecoder(strings.NewReader(blob)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTaxon(t, db, "Aus bus", "species", gen)
+	addTaxon(t, db, "Xenus", "genus", "")
+	addTaxon(t, db, "Bus", "genus", "")
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Aus", []string{"Aus"}},
+		{"Ause", []string{"Aus"}},
+		{"Aus bis", []string{"Aus bus"}},
+		{"Zenus", []string{"Xenus"}},
+		{"Cus", nil},
+	}
+	for _, test := range tests {
+		l, err := db.List(jdh.Taxonomy, []jdh.KeyValue{{Key: jdh.TaxSimilar, Value: []string{test.name}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for e := l.Front(); e != nil; e = e.Next() {
+			got = append(got, e.Value.(*jdh.Taxon).Name)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("similar to %q: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestUndoTx checks that a change can be reverted while a transaction is
+// in progress, unless the transaction modifies the changed elements.
+func TestUndoTx(t *testing.T) {
+	db, err := Open(newDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	aus := addTaxon(t, db, "Aus", "genus", "")
+	bus := addTaxon(t, db, "Bus", "genus", "")
+	tx := db.Begin("")
+	kvs := []jdh.KeyValue{
+		{Key: jdh.KeyId, Value: []string{aus}},
+		{Key: jdh.KeyComment, Value: []string{"a comment"}},
+	}
+	if err := tx.Set(jdh.Taxonomy, kvs); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Undo(""); err != nil {
+		t.Errorf("undo: %v", err)
+	}
+	if nm := taxonName(db, bus); nm != "" {
+		t.Errorf("taxon %s not reverted", bus)
+	}
+	if err := db.Undo(""); err == nil {
+		t.Errorf("undo: reverted a taxon modified by a transaction")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
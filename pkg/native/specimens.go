@@ -49,15 +49,20 @@ type specimen struct {
 // specimens file
 const speFile = "specimens"
 
-// OpenSpecimens open specimen data.
-func openSpecimens(db *DB) *specimens {
-	s := &specimens{
+// NewSpecimens returns an empty table of specimens.
+func newSpecimens(db *DB) *specimens {
+	return &specimens{
 		db:    db,
 		taxId: make(map[string]*speTaxon),
 		taxLs: list.New(),
 		ids:   make(map[string]*specimen),
 		next:  1,
 	}
+}
+
+// OpenSpecimens open specimen data.
+func openSpecimens(db *DB) *specimens {
+	s := newSpecimens(db)
 	p := filepath.Join(db.path, speFile)
 	f, err := os.Open(p)
 	if err != nil {
@@ -201,6 +206,17 @@ func (s *specimens) add(spe *jdh.Specimen) (string, error) {
 	return id, nil
 }
 
+// Insert adds an specimen to the database, preserving its id.
+func (s *specimens) insert(spe *jdh.Specimen) error {
+	s.setNext(spe.Id)
+	if err := s.validate(spe); err != nil {
+		return err
+	}
+	s.addSpecimen(spe)
+	s.changed = true
+	return nil
+}
+
 // Commit saves the occurrences into hard disk.
 func (s *specimens) commit(e chan error) {
 	if !s.changed {
@@ -208,22 +224,35 @@ func (s *specimens) commit(e chan error) {
 		return
 	}
 	p := filepath.Join(s.db.path, speFile)
-	f, err := os.Create(p)
+	f, err := createNew(p)
 	if err != nil {
 		e <- err
 		return
 	}
-	defer f.Close()
 	enc := json.NewEncoder(f)
-	for et := s.taxLs.Front(); et != nil; et = et.Next() {
-		tax := et.Value.(*speTaxon)
-		for e := tax.specs.Front(); e != nil; e = e.Next() {
-			sp := e.Value.(*specimen)
-			enc.Encode(sp.data)
+	for _, vt := range sortedList(s.taxLs, speTaxId) {
+		tax := vt.(*speTaxon)
+		for _, v := range sortedList(tax.specs, speId) {
+			sp := v.(*specimen)
+			if err = enc.Encode(sp.data); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
 		}
 	}
-	s.changed = false
-	e <- nil
+	e <- closeNew(f, err)
+}
+
+// SpeTaxId returns the id of a taxon of the specimens table.
+func speTaxId(v interface{}) string {
+	return v.(*speTaxon).id
+}
+
+// SpeId returns the id of an specimen.
+func speId(v interface{}) string {
+	return v.(*specimen).data.Id
 }
 
 // Delete deletes an specimen or a group of specimens from the database.
@@ -303,6 +332,19 @@ func (s *specimens) delSpecimen(sp *specimen) *speTaxon {
 	return tax
 }
 
+// Count returns the number of specimens in the database. As the specimens are
+// also indexed by its extern ids, and catalog codes, only the entries of its ids are
+// counted.
+func (s *specimens) count() int {
+	n := 0
+	for id, v := range s.ids {
+		if id == v.data.Id {
+			n++
+		}
+	}
+	return n
+}
+
 // Get returns an specimen with a given id.
 func (s *specimens) get(id string) (*jdh.Specimen, error) {
 	if len(id) == 0 {
@@ -750,3 +792,29 @@ func (s *specimens) delExtern(sp *specimen, service string) bool {
 	}
 	return false
 }
+
+// Restore sets an specimen to a previous state. If the specimen is not in
+// the database, it will be added.
+func (s *specimens) restore(spe *jdh.Specimen) error {
+	if _, ok := s.ids[spe.Id]; ok {
+		if err := s.delete(idVals(spe.Id)); err != nil {
+			return err
+		}
+	}
+	return s.insert(spe)
+}
+
+// UndoTaxon returns the operations that add again the specimens of a
+// taxon.
+func (s *specimens) undoTaxon(id string) []*op {
+	tax, ok := s.taxId[id]
+	if !ok {
+		return nil
+	}
+	var undo []*op
+	for e := tax.specs.Front(); e != nil; e = e.Next() {
+		sp := e.Value.(*specimen)
+		undo = append(undo, newOp(jdh.Add, jdh.Specimens, sp.data))
+	}
+	return undo
+}
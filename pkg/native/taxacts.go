@@ -0,0 +1,309 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// TaxActs holds the nomenclatural acts of the taxons.
+type taxActs struct {
+	db      *DB
+	ids     map[string]*jdh.TaxAct   // map of id:act
+	taxId   map[string][]*jdh.TaxAct // map of taxon-id:acts, ordered by date
+	changed bool                     // if true, the database has changed
+	next    int64                    // next valid id
+}
+
+// nomenclatural acts file
+const actFile = "taxacts"
+
+// NewTaxActs returns an empty table of nomenclatural acts.
+func newTaxActs(db *DB) *taxActs {
+	return &taxActs{
+		db:    db,
+		ids:   make(map[string]*jdh.TaxAct),
+		taxId: make(map[string][]*jdh.TaxAct),
+		next:  1,
+	}
+}
+
+// OpenTaxActs opens the nomenclatural acts data.
+func openTaxActs(db *DB) *taxActs {
+	a := newTaxActs(db)
+	p := filepath.Join(db.path, actFile)
+	f, err := os.Open(p)
+	if err != nil {
+		return a
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for {
+		act := &jdh.TaxAct{}
+		if err := dec.Decode(act); err != nil {
+			if err == io.EOF {
+				break
+			}
+			log.Printf("db-taxacts: error: %v\n", err)
+			break
+		}
+		a.setNext(act.Id)
+		if err := a.validate(act); err != nil {
+			log.Printf("db-taxacts: error: %v\n", err)
+			continue
+		}
+		a.addAct(act)
+	}
+	return a
+}
+
+// SetNext sets the value of the next id.
+func (a *taxActs) setNext(id string) {
+	v, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return
+	}
+	if v >= a.next {
+		a.next = v + 1
+	}
+}
+
+// Validate validates that an act is valid in the database, and set some
+// canonical values. It returns an error if the act is not valid. The
+// parents of the act are not validated, as they can be removed from the
+// database after the act.
+func (a *taxActs) validate(act *jdh.TaxAct) error {
+	act.Id = strings.TrimSpace(act.Id)
+	act.Taxon = strings.TrimSpace(act.Taxon)
+	if (len(act.Id) == 0) || (len(act.Taxon) == 0) {
+		return errors.New("act without identification")
+	}
+	if _, ok := a.ids[act.Id]; ok {
+		return fmt.Errorf("act id %s already in use", act.Id)
+	}
+	if !a.db.t.isInDB(act.Taxon) {
+		return fmt.Errorf("taxon %s [associated with act %s] not in database", act.Taxon, act.Id)
+	}
+	act.Reference = strings.TrimSpace(act.Reference)
+	act.OldParent = strings.TrimSpace(act.OldParent)
+	act.NewParent = strings.TrimSpace(act.NewParent)
+	return nil
+}
+
+// AddAct adds a new act to the database. The acts of a taxon are kept
+// ordered by its date.
+func (a *taxActs) addAct(act *jdh.TaxAct) {
+	ls := append(a.taxId[act.Taxon], act)
+	for i := len(ls) - 1; (i > 0) && ls[i].Date.Before(ls[i-1].Date); i-- {
+		ls[i], ls[i-1] = ls[i-1], ls[i]
+	}
+	a.taxId[act.Taxon] = ls
+	a.ids[act.Id] = act
+}
+
+// Add adds an act to the database.
+func (a *taxActs) add(act *jdh.TaxAct) (string, error) {
+	id := strconv.FormatInt(a.next, 10)
+	act.Id = id
+	if err := a.validate(act); err != nil {
+		return "", err
+	}
+	a.addAct(act)
+	a.next++
+	a.changed = true
+	return id, nil
+}
+
+// Insert adds an act to the database, preserving its id.
+func (a *taxActs) insert(act *jdh.TaxAct) error {
+	a.setNext(act.Id)
+	if err := a.validate(act); err != nil {
+		return err
+	}
+	a.addAct(act)
+	a.changed = true
+	return nil
+}
+
+// Commit saves the acts into the hard disk.
+func (a *taxActs) commit(e chan error) {
+	if !a.changed {
+		e <- nil
+		return
+	}
+	p := filepath.Join(a.db.path, actFile)
+	f, err := createNew(p)
+	if err != nil {
+		e <- err
+		return
+	}
+	l := list.New()
+	for _, act := range a.ids {
+		l.PushBack(act)
+	}
+	enc := json.NewEncoder(f)
+	for _, v := range sortedList(l, actId) {
+		if err = enc.Encode(v); err != nil {
+			break
+		}
+	}
+	e <- closeNew(f, err)
+}
+
+// ActId returns the id of an act.
+func actId(v interface{}) string {
+	return v.(*jdh.TaxAct).Id
+}
+
+// Delete deletes an act, or all the acts of a taxon, from the database.
+func (a *taxActs) delete(vals []jdh.KeyValue) error {
+	for _, kv := range vals {
+		if len(kv.Value) == 0 {
+			continue
+		}
+		if kv.Key == jdh.KeyId {
+			if len(kv.Value[0]) == 0 {
+				return errors.New("act without identification")
+			}
+			act, ok := a.ids[kv.Value[0]]
+			if !ok {
+				return nil
+			}
+			a.delAct(act)
+			return nil
+		}
+		if kv.Key == jdh.ActTaxon {
+			if len(kv.Value[0]) == 0 {
+				return errors.New("taxon without identification")
+			}
+			a.delTaxon(kv.Value[0])
+			return nil
+		}
+	}
+	return errors.New("act-taxon without identification")
+}
+
+// DelTaxon removes all the acts of a particular taxon.
+func (a *taxActs) delTaxon(id string) {
+	ls, ok := a.taxId[id]
+	if !ok {
+		return
+	}
+	for _, act := range ls {
+		delete(a.ids, act.Id)
+	}
+	delete(a.taxId, id)
+	a.changed = true
+}
+
+// DelAct removes a particular act from the database.
+func (a *taxActs) delAct(act *jdh.TaxAct) {
+	delete(a.ids, act.Id)
+	ls := a.taxId[act.Taxon]
+	for i, ot := range ls {
+		if ot == act {
+			copy(ls[i:], ls[i+1:])
+			ls[len(ls)-1] = nil
+			ls = ls[:len(ls)-1]
+			break
+		}
+	}
+	if len(ls) == 0 {
+		delete(a.taxId, act.Taxon)
+	} else {
+		a.taxId[act.Taxon] = ls
+	}
+	a.changed = true
+}
+
+// Get returns an act with a given id.
+func (a *taxActs) get(id string) (*jdh.TaxAct, error) {
+	if len(id) == 0 {
+		return nil, errors.New("act without identification")
+	}
+	act, ok := a.ids[id]
+	if !ok {
+		return nil, nil
+	}
+	return act, nil
+}
+
+// List returns the list of the acts of a taxon.
+func (a *taxActs) list(vals []jdh.KeyValue) (*list.List, error) {
+	for _, kv := range vals {
+		if len(kv.Value) == 0 {
+			continue
+		}
+		if kv.Key == jdh.ActTaxon {
+			if len(kv.Value[0]) == 0 {
+				return nil, errors.New("taxon without identification")
+			}
+			l := list.New()
+			for _, act := range a.taxId[kv.Value[0]] {
+				l.PushBack(act)
+			}
+			return l, nil
+		}
+	}
+	return nil, errors.New("taxon without identification")
+}
+
+// UndoTaxon returns the operations that add again the acts of a taxon.
+func (a *taxActs) undoTaxon(id string) []*op {
+	var undo []*op
+	for _, act := range a.taxId[id] {
+		undo = append(undo, newOp(jdh.Add, jdh.TaxActs, act))
+	}
+	return undo
+}
+
+// RecordAct records the nomenclatural act of a set operation on a taxon,
+// if the parent or the validity of the taxon were changed by the
+// operation. The previous state of the taxon is old. It returns the
+// operation that reverts the act. It must be called with the database
+// locked.
+func (db *DB) recordAct(o *op, old *jdh.Taxon) (*op, error) {
+	tx, ok := db.t.ids[old.Id]
+	if !ok {
+		return nil, nil
+	}
+	tax := tx.data
+	if (tax.Parent == old.Parent) && (tax.IsValid == old.IsValid) {
+		return nil, nil
+	}
+	act := &jdh.TaxAct{
+		Taxon:     tax.Id,
+		Date:      time.Now(),
+		Reference: getVal(o.Kvs, jdh.KeyReference),
+		OldParent: old.Parent,
+		NewParent: tax.Parent,
+		OldValid:  old.IsValid,
+		NewValid:  tax.IsValid,
+	}
+	id, err := db.ta.add(act)
+	if err != nil {
+		return nil, err
+	}
+	undo := idOp(jdh.Delete, jdh.TaxActs, id)
+	ao := newOp(jdh.Add, jdh.TaxActs, act)
+	ao.Tx = o.Tx
+	if err := db.jour.write(ao); err != nil {
+		db.apply(undo)
+		return nil, err
+	}
+	return undo, nil
+}
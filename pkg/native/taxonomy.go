@@ -13,6 +13,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -45,15 +46,20 @@ const rootId = "0"
 // taxonomy file
 const taxFile = "taxonomy"
 
-// OpenTaxonomy opens taxonomy data.
-func openTaxonomy(db *DB) *taxonomy {
-	t := &taxonomy{
+// NewTaxonomy returns an empty taxonomy.
+func newTaxonomy(db *DB) *taxonomy {
+	return &taxonomy{
 		db:    db,
 		root:  &taxon{},
 		ids:   make(map[string]*taxon),
 		names: radix.New(),
 		next:  1,
 	}
+}
+
+// OpenTaxonomy opens taxonomy data.
+func openTaxonomy(db *DB) *taxonomy {
+	t := newTaxonomy(db)
 	p := filepath.Join(db.path, taxFile)
 	f, err := os.Open(p)
 	if err != nil {
@@ -103,7 +109,7 @@ func (t *taxonomy) validate(tax *jdh.Taxon) error {
 		return fmt.Errorf("taxon id %s already in use", tax.Id)
 	}
 	p := t.root
-	if tax.Rank > jdh.Species {
+	if !tax.Rank.IsValid() {
 		tax.Rank = jdh.Unranked
 	}
 	if len(tax.Parent) > 0 {
@@ -209,6 +215,17 @@ func (t *taxonomy) add(tax *jdh.Taxon) (string, error) {
 	return id, nil
 }
 
+// Insert adds a taxon to the database, preserving its id.
+func (t *taxonomy) insert(tax *jdh.Taxon) error {
+	t.setNext(tax.Id)
+	if err := t.validate(tax); err != nil {
+		return err
+	}
+	t.addTaxon(tax)
+	t.changed = true
+	return nil
+}
+
 // Commit saves the taxonomy into hard disk.
 func (t *taxonomy) commit(e chan error) {
 	if !t.changed {
@@ -216,26 +233,53 @@ func (t *taxonomy) commit(e chan error) {
 		return
 	}
 	p := filepath.Join(t.db.path, taxFile)
-	f, err := os.Create(p)
+	f, err := createNew(p)
 	if err != nil {
 		e <- err
 		return
 	}
-	defer f.Close()
 	enc := json.NewEncoder(f)
-	for _, d := range t.root.childs {
-		d.encode(enc)
+	for _, d := range sortedTaxa(t.root.childs) {
+		if err = d.encode(enc); err != nil {
+			break
+		}
 	}
-	t.changed = false
-	e <- nil
+	e <- closeNew(f, err)
 }
 
-// Encode encodes a taxon into a json blob in the database.
-func (tx *taxon) encode(enc *json.Encoder) {
-	enc.Encode(tx.data)
-	for _, d := range tx.childs {
-		d.encode(enc)
+// Encode encodes a taxon into a json blob in the database. Each taxon is
+// followed by its descendants.
+func (tx *taxon) encode(enc *json.Encoder) error {
+	if err := enc.Encode(tx.data); err != nil {
+		return err
+	}
+	for _, d := range sortedTaxa(tx.childs) {
+		if err := d.encode(enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SortedTaxa returns a copy of a list of taxons sorted by name, and then
+// by id.
+func sortedTaxa(ls []*taxon) []*taxon {
+	sorted := make([]*taxon, len(ls))
+	copy(sorted, ls)
+	sort.Sort(taxByName(sorted))
+	return sorted
+}
+
+// taxByName sorts taxons by its name, and then by its id.
+type taxByName []*taxon
+
+func (ls taxByName) Len() int      { return len(ls) }
+func (ls taxByName) Swap(i, j int) { ls[i], ls[j] = ls[j], ls[i] }
+func (ls taxByName) Less(i, j int) bool {
+	if ls[i].data.Name != ls[j].data.Name {
+		return ls[i].data.Name < ls[j].data.Name
 	}
+	return idLess(ls[i].data.Id, ls[j].data.Id)
 }
 
 // Delete deletes a taxon (and all its descendants) from the database.
@@ -275,6 +319,14 @@ func (t *taxonomy) delTaxon(tx *taxon) {
 	if t.db.rd != nil {
 		t.db.rd.delTaxon(tx.data.Id)
 	}
+	// removes the taxon from the trees
+	if t.db.tr != nil {
+		t.db.tr.delTaxonFromAll(tx.data.Id)
+	}
+	// removes the nomenclatural acts
+	if t.db.ta != nil {
+		t.db.ta.delTaxon(tx.data.Id)
+	}
 	tx.childs = nil
 	nmLow := strings.ToLower(tx.data.Name)
 	v := t.names.Lookup(nmLow).([]*taxon)
@@ -314,6 +366,19 @@ func delTaxFromList(ls []*taxon, tx *taxon) []*taxon {
 	return ls
 }
 
+// Count returns the number of taxons in the database. As the taxons are
+// also indexed by its extern ids, only the entries of its ids are
+// counted.
+func (t *taxonomy) count() int {
+	n := 0
+	for id, v := range t.ids {
+		if id == v.data.Id {
+			n++
+		}
+	}
+	return n
+}
+
 // Get returns a taxon with a given id.
 func (t *taxonomy) get(id string) (*jdh.Taxon, error) {
 	if len(id) == 0 {
@@ -416,6 +481,16 @@ func (t *taxonomy) list(vals []jdh.KeyValue) (*list.List, error) {
 			}
 			noVal = false
 			nameList = true
+		case jdh.TaxSimilar:
+			if len(kv.Value) == 0 {
+				return nil, errors.New("taxon without identification")
+			}
+			if len(strings.TrimSpace(kv.Value[0])) == 0 {
+				return nil, errors.New("taxon without identification")
+			}
+			l.PushBackList(t.similar(kv.Value[0]))
+			noVal = false
+			nameList = true
 		}
 		if !noVal {
 			break
@@ -756,3 +831,66 @@ func (t *taxonomy) isInDB(id string) bool {
 	}
 	return false
 }
+
+// Visit applies a function to a taxon and all of its descendants. Parents
+// are always visited before its descendants.
+func (tx *taxon) visit(f func(*taxon)) {
+	f(tx)
+	for _, d := range tx.childs {
+		d.visit(f)
+	}
+}
+
+// Restore sets a taxon to a previous state. If the taxon is not in the
+// database, it will be added.
+func (t *taxonomy) restore(tax *jdh.Taxon) error {
+	tx, ok := t.ids[tax.Id]
+	if !ok {
+		return t.insert(tax)
+	}
+	p := t.root
+	if len(tax.Parent) > 0 {
+		if p, ok = t.ids[tax.Parent]; !ok {
+			return fmt.Errorf("taxon %s parent [%s] not in database", tax.Name, tax.Parent)
+		}
+	}
+	if tx.data.Name != tax.Name {
+		nmLow := strings.ToLower(tx.data.Name)
+		v := t.names.Lookup(nmLow).([]*taxon)
+		v = delTaxFromList(v, tx)
+		if len(v) > 0 {
+			t.names.Set(nmLow, v)
+		} else {
+			t.names.Delete(nmLow)
+		}
+		nmLow = strings.ToLower(tax.Name)
+		vi := t.names.Lookup(nmLow)
+		var txLs []*taxon
+		if vi == nil {
+			txLs = []*taxon{tx}
+		} else {
+			txLs = append(vi.([]*taxon), tx)
+		}
+		t.names.Set(nmLow, txLs)
+	}
+	for _, e := range tx.data.Extern {
+		delete(t.ids, e)
+	}
+	ext := tax.Extern
+	tax.Extern = nil
+	for _, e := range ext {
+		if _, ok := t.ids[e]; ok {
+			continue
+		}
+		tax.Extern = append(tax.Extern, e)
+		t.ids[e] = tx
+	}
+	if tx.parent != p {
+		tx.parent.childs = delTaxFromList(tx.parent.childs, tx)
+		p.childs = append(p.childs, tx)
+		tx.parent = p
+	}
+	*tx.data = *tax
+	t.changed = true
+	return nil
+}
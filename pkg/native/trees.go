@@ -28,6 +28,10 @@ type trees struct {
 	changed bool                  // true if the database has changed
 	nxTree  int64                 // next valid tree id
 	nxNode  int64                 // next valid tree node id
+
+	// invalid nodes found when the database was opened, they are kept
+	// as they are, until they are removed by a repair of the database.
+	invalid []json.RawMessage
 }
 
 // Phylogeny holds a phylogenetic tree.
@@ -54,9 +58,9 @@ type node struct {
 const treFile = "trees"
 const nodFile = "nodes"
 
-// OpenTrees open phylogenetic tree data.
-func openTrees(db *DB) *trees {
-	tr := &trees{
+// NewTrees returns an empty table of phylogenetic trees.
+func newTrees(db *DB) *trees {
+	return &trees{
 		db:     db,
 		ids:    make(map[string]*phylogeny),
 		nodes:  make(map[string]*node),
@@ -64,19 +68,23 @@ func openTrees(db *DB) *trees {
 		nxTree: 1,
 		nxNode: 1,
 	}
-	if !tr.openTreFile() {
-		return tr
-	}
+}
+
+// OpenTrees open phylogenetic tree data.
+func openTrees(db *DB) *trees {
+	tr := newTrees(db)
+	tr.openTreFile()
+	// the nodes are read even without trees, so invalid nodes are kept.
 	tr.openNodFile()
 	return tr
 }
 
 // OpenTreFile read the phylogenetic tree data.
-func (tr *trees) openTreFile() bool {
+func (tr *trees) openTreFile() {
 	p := filepath.Join(tr.db.path, treFile)
 	f, err := os.Open(p)
 	if err != nil {
-		return false
+		return
 	}
 	defer f.Close()
 	dec := json.NewDecoder(f)
@@ -96,7 +104,6 @@ func (tr *trees) openTreFile() bool {
 		}
 		tr.addValPhy(phy)
 	}
-	return true
 }
 
 // SetNxPhy sets the value of the next valid tree id.
@@ -163,7 +170,8 @@ func (tr *trees) addValPhy(phy *jdh.Phylogeny) {
 	ph.elem = tr.ls.PushBack(ph)
 }
 
-// OpenNodFile open nodes file.
+// OpenNodFile open nodes file. Invalid nodes are not added to the trees,
+// but they are kept, so they are not lost in the next commit.
 func (tr *trees) openNodFile() {
 	p := filepath.Join(tr.db.path, nodFile)
 	f, err := os.Open(p)
@@ -173,17 +181,25 @@ func (tr *trees) openNodFile() {
 	defer f.Close()
 	dec := json.NewDecoder(f)
 	for {
-		nod := &jdh.Node{}
-		if err := dec.Decode(nod); err != nil {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
 			if err == io.EOF {
 				break
 			}
 			log.Printf("db-trees: error: %v\n", err)
 			break
 		}
+		nod := &jdh.Node{}
+		if err := json.Unmarshal(raw, nod); err != nil {
+			log.Printf("db-trees: error: %v\n", err)
+			tr.invalid = append(tr.invalid, raw)
+			continue
+		}
 		tr.setNxNode(nod.Id)
 		if err := tr.valNod(nod); err != nil {
 			log.Printf("db-trees: error: %v\n", err)
+			tr.invalid = append(tr.invalid, raw)
+			continue
 		}
 		tr.addValNode(nod)
 	}
@@ -288,6 +304,28 @@ func (tr *trees) addNode(nod *jdh.Node) (string, error) {
 	return id, nil
 }
 
+// InsertTree adds a tree to the database, preserving its id.
+func (tr *trees) insertTree(phy *jdh.Phylogeny) error {
+	tr.setNxPhy(phy.Id)
+	if err := tr.valPhy(phy); err != nil {
+		return err
+	}
+	tr.addValPhy(phy)
+	tr.changed = true
+	return nil
+}
+
+// InsertNode adds a node to the database, preserving its id.
+func (tr *trees) insertNode(nod *jdh.Node) error {
+	tr.setNxNode(nod.Id)
+	if err := tr.valNod(nod); err != nil {
+		return err
+	}
+	tr.addValNode(nod)
+	tr.changed = true
+	return nil
+}
+
 // Commit saves the trees into hard disk.
 func (tr *trees) commit(e chan error) {
 	if !tr.changed {
@@ -302,48 +340,72 @@ func (tr *trees) commit(e chan error) {
 		e <- err
 		return
 	}
-	tr.changed = false
 	e <- nil
 }
 
 // ComTree commits a phylogenetic tree.
 func (tr *trees) comTree() error {
 	p := filepath.Join(tr.db.path, treFile)
-	f, err := os.Create(p)
+	f, err := createNew(p)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 	enc := json.NewEncoder(f)
-	for e := tr.ls.Front(); e != nil; e = e.Next() {
-		ph := e.Value.(*phylogeny)
-		enc.Encode(ph.data)
+	for _, v := range sortedList(tr.ls, phyId) {
+		ph := v.(*phylogeny)
+		if err = enc.Encode(ph.data); err != nil {
+			break
+		}
 	}
-	return nil
+	return closeNew(f, err)
+}
+
+// PhyId returns the id of a phylogeny.
+func phyId(v interface{}) string {
+	return v.(*phylogeny).data.Id
 }
 
-// ComNode commits the nodes of a phylogeny.
+// ComNode commits the nodes of a phylogeny. The nodes of each tree are
+// stored from the root, keeping the order of the descendants of each node,
+// as it is part of the tree. Invalid nodes are stored at the end of the
+// file.
 func (tr *trees) comNode() error {
 	p := filepath.Join(tr.db.path, nodFile)
-	f, err := os.Create(p)
+	f, err := createNew(p)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 	enc := json.NewEncoder(f)
-	for e := tr.ls.Front(); e != nil; e = e.Next() {
-		ph := e.Value.(*phylogeny)
-		ph.root.encode(enc)
+	for _, v := range sortedList(tr.ls, phyId) {
+		ph := v.(*phylogeny)
+		if ph.root == nil {
+			continue
+		}
+		if err = ph.root.encode(enc); err != nil {
+			break
+		}
 	}
-	return nil
+	if err == nil {
+		for _, raw := range tr.invalid {
+			if err = enc.Encode(raw); err != nil {
+				break
+			}
+		}
+	}
+	return closeNew(f, err)
 }
 
 // Encode encodes a node into a json blob in the database.
-func (nd *node) encode(enc *json.Encoder) {
-	enc.Encode(nd.data)
+func (nd *node) encode(enc *json.Encoder) error {
+	if err := enc.Encode(nd.data); err != nil {
+		return err
+	}
 	for _, d := range nd.childs {
-		d.encode(enc)
+		if err := d.encode(enc); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // DeleteTree deletes a tree, or a taxon from a tree.
@@ -477,6 +539,9 @@ func (tr *trees) delTree(ph *phylogeny) {
 	ph.nodes = nil
 	ph.taxa = nil
 	delete(tr.ids, ph.data.Id)
+	for _, e := range ph.data.Extern {
+		delete(tr.ids, e)
+	}
 	ph.data = nil
 	tr.ls.Remove(ph.elem)
 	ph.elem = nil
@@ -552,6 +617,19 @@ func (tr *trees) colNode(nd *node) {
 	tr.delNode(nd)
 }
 
+// CountTrees returns the number of trees in the database. As the trees
+// are also indexed by its extern ids, only the entries of its ids are
+// counted.
+func (tr *trees) countTrees() int {
+	n := 0
+	for id, ph := range tr.ids {
+		if id == ph.data.Id {
+			n++
+		}
+	}
+	return n
+}
+
 // GetTree returns a tree with a given id.
 func (tr *trees) getTree(id string) (*jdh.Phylogeny, error) {
 	if len(id) == 0 {
@@ -1018,3 +1096,64 @@ func (nd *node) isValidAnc(on *node) bool {
 	}
 	return true
 }
+
+// Visit applies a function to a node and all of its descendants. Parents
+// are always visited before its descendants.
+func (nd *node) visit(f func(*node)) {
+	f(nd)
+	for _, d := range nd.childs {
+		d.visit(f)
+	}
+}
+
+// Restore sets a tree, and all of its nodes, to a previous state.
+func (tr *trees) restore(img *treeImage) error {
+	if img.Tree == nil {
+		return errors.New("tree without identification")
+	}
+	if ph, ok := tr.ids[img.Tree.Id]; ok {
+		tr.delTree(ph)
+	}
+	if err := tr.insertTree(img.Tree); err != nil {
+		return err
+	}
+	for _, nod := range img.Nodes {
+		if err := tr.insertNode(nod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UndoTree returns the operations that restore a tree to its current
+// state.
+func (tr *trees) undoTree(id string) []*op {
+	ph, ok := tr.ids[id]
+	if !ok {
+		return nil
+	}
+	img := &treeImage{Tree: ph.data}
+	if ph.root != nil {
+		ph.root.visit(func(nd *node) {
+			img.Nodes = append(img.Nodes, nd.data)
+		})
+	}
+	return []*op{newOp(restore, jdh.Trees, img)}
+}
+
+// UndoTaxonFromAll returns the operations that assign again a taxon to the
+// nodes of the trees.
+func (tr *trees) undoTaxonFromAll(tax string) []*op {
+	var undo []*op
+	for e := tr.ls.Front(); e != nil; e = e.Next() {
+		ph := e.Value.(*phylogeny)
+		nd, ok := ph.taxa[tax]
+		if !ok {
+			continue
+		}
+		o := idOp(jdh.Set, jdh.Nodes, nd.data.Id)
+		o.Kvs = append(o.Kvs, jdh.KeyValue{Key: jdh.NodTaxon, Value: []string{tax}})
+		undo = append(undo, o)
+	}
+	return undo
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// Tx is a transaction of the database. The operations of a transaction
+// are applied as soon as they are requested, but they are reverted if the
+// transaction is rolled back. Transactions are not isolated: their
+// operations are seen by any reader of the database, and by other
+// transactions, before the transaction is committed.
+type Tx struct {
+	db      *DB
+	id      string
+	owner   string            // user that started the transaction
+	queries []jdh.ChangeQuery // queries of the transaction
+	undo    [][]*op           // inverse of each operation of the transaction
+	last    time.Time         // time of the last operation
+}
+
+// txTimeout is the time that a transaction can be idle before it is
+// rolled back.
+const txTimeout = 30 * time.Minute
+
+// Begin starts a new transaction of a given owner (e.g. the user of a
+// server), that is the only one that can retrieve the transaction with Tx.
+// Transactions that are idle for too long are rolled back.
+func (db *DB) Begin(owner string) *Tx {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	tx := db.begin()
+	tx.owner = owner
+	return tx
+}
+
+// Begin starts a new transaction. It must be called with the database
+// locked.
+func (db *DB) begin() *Tx {
+	if db.txs == nil {
+		db.txs = make(map[string]*Tx)
+	}
+	db.expire()
+	tx := &Tx{
+		db:   db,
+		id:   newTxId(),
+		last: time.Now(),
+	}
+	for _, ok := db.txs[tx.id]; ok; _, ok = db.txs[tx.id] {
+		tx.id = newTxId()
+	}
+	db.txs[tx.id] = tx
+	return tx
+}
+
+// NewTxId returns a random transaction id, so the id of a transaction
+// can not be guessed from the ids of other transactions.
+func newTxId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// the system random generator should never fail.
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Expire rolls back the transactions that are idle for too long. It must
+// be called with the database locked.
+func (db *DB) expire() {
+	for _, tx := range db.txs {
+		if time.Since(tx.last) > txTimeout {
+			tx.rollback()
+		}
+	}
+}
+
+// Tx returns a transaction in progress with a given id, started by the
+// given owner.
+func (db *DB) Tx(id, owner string) (*Tx, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	tx, ok := db.txs[id]
+	if !ok || (tx.owner != owner) {
+		return nil, errors.New("transaction " + id + " not in progress")
+	}
+	return tx, nil
+}
+
+// Id returns the identifier of the transaction.
+func (tx *Tx) Id() string {
+	return tx.id
+}
+
+// Add adds a new element to the database as part of the transaction.
+func (tx *Tx) Add(table jdh.Table, dec *json.Decoder) (string, error) {
+	return tx.db.doAdd(tx, table, dec)
+}
+
+// Delete removes an element from the database as part of the transaction.
+func (tx *Tx) Delete(table jdh.Table, vals []jdh.KeyValue) error {
+	_, err := tx.db.do(tx, &op{Query: jdh.Delete, Table: table, Kvs: vals}, nil)
+	return err
+}
+
+// Set sets one or more values for a given element in the database as part
+// of the transaction.
+func (tx *Tx) Set(table jdh.Table, vals []jdh.KeyValue) error {
+	_, err := tx.db.do(tx, &op{Query: jdh.Set, Table: table, Kvs: vals}, nil)
+	return err
+}
+
+// Commit finishes the transaction, keeping all of its operations, and
+// stores the transaction as a single change in the history. The database
+// must be committed to save the operations into the hard disk.
+func (tx *Tx) Commit() error {
+	db := tx.db
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if _, ok := db.txs[tx.id]; !ok {
+		return errors.New("transaction " + tx.id + " not in progress")
+	}
+	if err := tx.commit(); err != nil {
+		return err
+	}
+	if len(tx.queries) == 0 {
+		return nil
+	}
+	if err := db.hist.record(tx.queries, tx.undo); err != nil {
+		log.Printf("db-history: error: %v\n", err)
+	}
+	db.notify(tx.queries, "")
+	return nil
+}
+
+// Commit finishes the transaction. It must be called with the database
+// locked.
+func (tx *Tx) commit() error {
+	if err := tx.db.jour.write(&op{Query: jdh.Commit, Tx: tx.id}); err != nil {
+		return err
+	}
+	delete(tx.db.txs, tx.id)
+	return nil
+}
+
+// Rollback finishes the transaction, reverting all of its operations.
+func (tx *Tx) Rollback() error {
+	db := tx.db
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if _, ok := db.txs[tx.id]; !ok {
+		return errors.New("transaction " + tx.id + " not in progress")
+	}
+	return tx.rollback()
+}
+
+// Rollback reverts the operations of a transaction. The operations that
+// revert the transaction are stored in the journal as part of the
+// transaction, so when the journal is replayed, the operations of the
+// transaction are applied and reverted in the same order as in the
+// database. It must be called with the database locked.
+func (tx *Tx) rollback() error {
+	db := tx.db
+	var err error
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		for _, u := range tx.undo[i] {
+			if e := db.apply(u); (e != nil) && (err == nil) {
+				err = e
+			}
+			// the operation is copied, as it can be stored in the
+			// history.
+			o := *u
+			o.Tx = tx.id
+			if e := db.jour.write(&o); (e != nil) && (err == nil) {
+				err = e
+			}
+		}
+	}
+	tx.queries = nil
+	tx.undo = nil
+	delete(db.txs, tx.id)
+	// without the rollback mark (e.g. after a crash) neither the
+	// operations of the transaction, nor its reversion, are replayed.
+	if e := db.jour.write(&op{Query: jdh.Rollback, Tx: tx.id}); (e != nil) && (err == nil) {
+		err = e
+	}
+	return err
+}
+
+// DoAdd decodes and adds a new element to the database.
+func (db *DB) doAdd(tx *Tx, table jdh.Table, dec *json.Decoder) (string, error) {
+	var blob json.RawMessage
+	if err := dec.Decode(&blob); err != nil {
+		return "", err
+	}
+	elem, err := decodeElem(table, blob)
+	if err != nil {
+		return "", err
+	}
+	return db.do(tx, &op{Query: jdh.Add, Table: table, Elem: blob}, elem)
+}
+
+// Do applies an operation on the database, and stores it in the journal.
+// If the operation is not part of a transaction, it is stored in the
+// history as a single change, and notified to the watches of the database.
+// The element is only used on additions.
+func (db *DB) do(tx *Tx, o *op, elem interface{}) (string, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if tx != nil {
+		if _, ok := db.txs[tx.id]; !ok {
+			return "", errors.New("transaction " + tx.id + " not in progress")
+		}
+		tx.last = time.Now()
+		return tx.exec(o, elem)
+	}
+	id, undo, err := db.exec(o, elem)
+	if err != nil {
+		return "", err
+	}
+	q := []jdh.ChangeQuery{changeQuery(o, id)}
+	if err := db.hist.record(q, [][]*op{undo}); err != nil {
+		log.Printf("db-history: error: %v\n", err)
+	}
+	db.notify(q, "")
+	return id, nil
+}
+
+// Exec applies an operation as part of the transaction, and keeps its
+// inverse. It must be called with the database locked.
+func (tx *Tx) exec(o *op, elem interface{}) (string, error) {
+	o.Tx = tx.id
+	id, undo, err := tx.db.exec(o, elem)
+	if err != nil {
+		return "", err
+	}
+	tx.queries = append(tx.queries, changeQuery(o, id))
+	tx.undo = append(tx.undo, undo)
+	return id, nil
+}
+
+// Exec applies an operation on the database, and stores it in the
+// journal. It returns the operations that revert the operation. If the
+// operation fails, any change done by the operation is reverted. If elem
+// is not nil, it is added as a new element, otherwise, the operation is
+// applied as stored in the journal. It must be called with the database
+// locked.
+func (db *DB) exec(o *op, elem interface{}) (string, []*op, error) {
+	undo := db.inverse(o)
+	// the previous state of a taxon, to record its nomenclatural acts
+	var old *jdh.Taxon
+	if (o.Query == jdh.Set) && (o.Table == jdh.Taxonomy) {
+		if tx, ok := db.t.ids[getVal(o.Kvs, jdh.KeyId)]; ok {
+			prev := *tx.data
+			old = &prev
+		}
+	}
+	id := ""
+	if elem != nil {
+		var err error
+		if id, err = db.add(elem); err != nil {
+			db.revert(undo)
+			return "", nil, err
+		}
+		// the id of a new element is only known after it is added
+		if o.Table != jdh.Nodes {
+			undo = []*op{idOp(jdh.Delete, o.Table, id)}
+		}
+		if o.Elem, err = json.Marshal(elem); err != nil {
+			db.revert(undo)
+			return "", nil, err
+		}
+	} else if err := db.apply(o); err != nil {
+		db.revert(undo)
+		return "", nil, err
+	}
+	if err := db.jour.write(o); err != nil {
+		db.revert(undo)
+		return "", nil, err
+	}
+	if old != nil {
+		au, err := db.recordAct(o, old)
+		if err != nil {
+			db.revert(undo)
+			return "", nil, err
+		}
+		if au != nil {
+			undo = append([]*op{au}, undo...)
+		}
+	}
+	return id, undo, nil
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// Restore is an operation that sets an element to a previous state. It is
+// only used to revert other operations.
+const restore jdh.Query = "restore"
+
+// TreeImage is the state of a phylogenetic tree, including its nodes.
+type treeImage struct {
+	Tree  *jdh.Phylogeny
+	Nodes []*jdh.Node
+}
+
+// NewOp returns a new operation with a copy of the given element.
+func newOp(query jdh.Query, table jdh.Table, elem interface{}) *op {
+	blob, err := json.Marshal(elem)
+	if err != nil {
+		// all database elements can be encoded.
+		panic(err)
+	}
+	return &op{Query: query, Table: table, Elem: blob}
+}
+
+// IdOp returns an operation on an element with the given id.
+func idOp(query jdh.Query, table jdh.Table, id string) *op {
+	return &op{Query: query, Table: table, Kvs: idVals(id)}
+}
+
+// IdVals returns a list of key values with only the given id.
+func idVals(id string) []jdh.KeyValue {
+	return []jdh.KeyValue{jdh.KeyValue{Key: jdh.KeyId, Value: []string{id}}}
+}
+
+// GetVal returns the first value of a key in a list of key values.
+func getVal(vals []jdh.KeyValue, key jdh.Key) string {
+	for _, kv := range vals {
+		if len(kv.Value) == 0 {
+			continue
+		}
+		if kv.Key == key {
+			return kv.Value[0]
+		}
+	}
+	return ""
+}
+
+// Inverse returns the operations that revert an operation. The returned
+// operations must be applied in order. It must be called before the
+// operation is applied.
+func (db *DB) inverse(o *op) []*op {
+	switch o.Query {
+	case jdh.Add:
+		return db.undoAdd(o)
+	case jdh.Delete:
+		return db.undoDelete(o)
+	case jdh.Set:
+		return db.undoSet(o)
+	case restore:
+		return db.undoRestore(o)
+	}
+	return nil
+}
+
+// UndoAdd returns the operations that revert an addition. Additions of
+// nodes are reverted with an image of the tree that contains the node,
+// other additions are reverted deleting the element, so the element must
+// have an id.
+func (db *DB) undoAdd(o *op) []*op {
+	if o.Table == jdh.Nodes {
+		nod := &jdh.Node{}
+		json.Unmarshal(o.Elem, nod)
+		return db.tr.undoTree(strings.TrimSpace(nod.Tree))
+	}
+	var elem struct {
+		Id string
+	}
+	json.Unmarshal(o.Elem, &elem)
+	if len(elem.Id) == 0 {
+		return nil
+	}
+	return []*op{idOp(jdh.Delete, o.Table, elem.Id)}
+}
+
+// UndoDelete returns the operations that revert a deletion.
+func (db *DB) undoDelete(o *op) []*op {
+	id := getVal(o.Kvs, jdh.KeyId)
+	switch o.Table {
+	case jdh.Datasets:
+		if sd, ok := db.d.ids[id]; ok {
+			return []*op{newOp(jdh.Add, jdh.Datasets, sd.data)}
+		}
+	case jdh.Nodes:
+		if len(id) == 0 {
+			id = getVal(o.Kvs, jdh.NodCollapse)
+		}
+		if nd, ok := db.tr.nodes[id]; ok {
+			return db.tr.undoTree(nd.data.Tree)
+		}
+	case jdh.RasDistros:
+		if len(id) > 0 {
+			if rd, ok := db.rd.ids[id]; ok {
+				return []*op{newOp(jdh.Add, jdh.RasDistros, rd.data)}
+			}
+			return nil
+		}
+		return db.rd.undoTaxon(getVal(o.Kvs, jdh.RDisTaxon))
+	case jdh.Specimens:
+		if len(id) > 0 {
+			if sp, ok := db.s.ids[id]; ok {
+				return []*op{newOp(jdh.Add, jdh.Specimens, sp.data)}
+			}
+			return nil
+		}
+		return db.s.undoTaxon(getVal(o.Kvs, jdh.SpeTaxon))
+	case jdh.TaxActs:
+		if len(id) > 0 {
+			if act, ok := db.ta.ids[id]; ok {
+				return []*op{newOp(jdh.Add, jdh.TaxActs, act)}
+			}
+			return nil
+		}
+		return db.ta.undoTaxon(getVal(o.Kvs, jdh.ActTaxon))
+	case jdh.Taxonomy:
+		tx, ok := db.t.ids[id]
+		if !ok {
+			return nil
+		}
+		var undo []*op
+		var rasUndo []*op
+		tx.visit(func(d *taxon) {
+			undo = append(undo, newOp(jdh.Add, jdh.Taxonomy, d.data))
+		})
+		var treUndo []*op
+		tx.visit(func(d *taxon) {
+			undo = append(undo, db.ta.undoTaxon(d.data.Id)...)
+			undo = append(undo, db.s.undoTaxon(d.data.Id)...)
+			rasUndo = append(rasUndo, db.rd.undoTaxon(d.data.Id)...)
+			treUndo = append(treUndo, db.tr.undoTaxonFromAll(d.data.Id)...)
+		})
+		undo = append(undo, rasUndo...)
+		return append(undo, treUndo...)
+	case jdh.Trees:
+		if len(id) == 0 {
+			return db.tr.undoTaxonFromAll(getVal(o.Kvs, jdh.TreTaxon))
+		}
+		if ph, ok := db.tr.ids[id]; ok {
+			return db.tr.undoTree(ph.data.Id)
+		}
+	}
+	return nil
+}
+
+// UndoSet returns the operations that revert a set operation.
+func (db *DB) undoSet(o *op) []*op {
+	id := getVal(o.Kvs, jdh.KeyId)
+	switch o.Table {
+	case jdh.Datasets:
+		if sd, ok := db.d.ids[id]; ok {
+			return []*op{newOp(restore, jdh.Datasets, sd.data)}
+		}
+	case jdh.Nodes:
+		if nd, ok := db.tr.nodes[id]; ok {
+			return db.tr.undoTree(nd.data.Tree)
+		}
+	case jdh.RasDistros:
+		if rd, ok := db.rd.ids[id]; ok {
+			return []*op{newOp(restore, jdh.RasDistros, rd.data)}
+		}
+	case jdh.Specimens:
+		if sp, ok := db.s.ids[id]; ok {
+			return []*op{newOp(restore, jdh.Specimens, sp.data)}
+		}
+	case jdh.Taxonomy:
+		tx, ok := db.t.ids[id]
+		if !ok {
+			return nil
+		}
+		undo := []*op{newOp(restore, jdh.Taxonomy, tx.data)}
+		// a synonym takes the place of its children
+		for _, d := range tx.childs {
+			undo = append(undo, newOp(restore, jdh.Taxonomy, d.data))
+		}
+		return undo
+	case jdh.Trees:
+		if ph, ok := db.tr.ids[id]; ok {
+			return db.tr.undoTree(ph.data.Id)
+		}
+	}
+	return nil
+}
+
+// UndoRestore returns the operations that revert a restore operation.
+func (db *DB) undoRestore(o *op) []*op {
+	if o.Table == jdh.Trees {
+		img := &treeImage{}
+		json.Unmarshal(o.Elem, img)
+		if img.Tree == nil {
+			return nil
+		}
+		if _, ok := db.tr.ids[img.Tree.Id]; !ok {
+			return []*op{idOp(jdh.Delete, jdh.Trees, img.Tree.Id)}
+		}
+		return db.tr.undoTree(img.Tree.Id)
+	}
+	var elem struct {
+		Id string
+	}
+	json.Unmarshal(o.Elem, &elem)
+	so := idOp(jdh.Set, o.Table, elem.Id)
+	undo := db.undoSet(so)
+	if undo == nil {
+		return []*op{idOp(jdh.Delete, o.Table, elem.Id)}
+	}
+	return undo
+}
+
+// Restore sets an element to a previous state.
+func (db *DB) restore(table jdh.Table, blob json.RawMessage) error {
+	if table == jdh.Trees {
+		img := &treeImage{}
+		if err := json.Unmarshal(blob, img); err != nil {
+			return err
+		}
+		return db.tr.restore(img)
+	}
+	elem, err := decodeElem(table, blob)
+	if err != nil {
+		return err
+	}
+	switch e := elem.(type) {
+	case *jdh.Dataset:
+		return db.d.restore(e)
+	case *jdh.Raster:
+		return db.rd.restore(e)
+	case *jdh.Specimen:
+		return db.s.restore(e)
+	case *jdh.Taxon:
+		return db.t.restore(e)
+	}
+	return errors.New("restore not implemented for table " + string(table))
+}
+
+// Revert applies the operations that revert an operation.
+func (db *DB) revert(undo []*op) error {
+	var err error
+	for _, u := range undo {
+		if e := db.apply(u); (e != nil) && (err == nil) {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"errors"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// watchSize is the number of events that a watch can hold before it is
+// dropped.
+const watchSize = 256
+
+// ErrWatchLost is the error of a watch that was dropped because its
+// events were not read.
+var ErrWatchLost = errors.New("watch lost: events not read")
+
+// Watch is a subscription to the changes of the database.
+type Watch struct {
+	db    *DB
+	table jdh.Table
+	c     chan jdh.Event
+	err   error // error of a dropped watch
+}
+
+// Watch returns a subscription to the changes made in a table of the
+// database. If table is empty, the changes of all the tables are
+// notified. Events are only sent for finished changes, i.e. operations
+// outside a transaction, committed transactions, and undos. If the events
+// are not read, the watch is dropped, and its channel closed.
+func (db *DB) Watch(table jdh.Table) *Watch {
+	w := &Watch{
+		db:    db,
+		table: table,
+		c:     make(chan jdh.Event, watchSize),
+	}
+	db.wlock.Lock()
+	defer db.wlock.Unlock()
+	if db.watches == nil {
+		db.watches = make(map[*Watch]bool)
+	}
+	db.watches[w] = true
+	return w
+}
+
+// Events returns the channel of the events of the watch. The channel is
+// closed when the watch is closed, or dropped.
+func (w *Watch) Events() <-chan jdh.Event {
+	return w.c
+}
+
+// Err returns ErrWatchLost if the watch was dropped.
+func (w *Watch) Err() error {
+	w.db.wlock.Lock()
+	defer w.db.wlock.Unlock()
+	return w.err
+}
+
+// Close closes the watch.
+func (w *Watch) Close() {
+	w.db.wlock.Lock()
+	defer w.db.wlock.Unlock()
+	if w.db.watches[w] {
+		delete(w.db.watches, w)
+		close(w.c)
+	}
+}
+
+// Notify sends the events of the queries of a change to the watches of the
+// database. If query is not empty, it is used as the query of the events.
+// It must be called with the database locked, so the events are sent in
+// the order of the changes.
+func (db *DB) notify(queries []jdh.ChangeQuery, query jdh.Query) {
+	db.wlock.Lock()
+	defer db.wlock.Unlock()
+	if len(db.watches) == 0 {
+		return
+	}
+	for _, q := range queries {
+		e := jdh.Event{
+			Table: q.Table,
+			Id:    getVal(q.Kvs, jdh.KeyId),
+			Query: q.Query,
+		}
+		if len(query) > 0 {
+			e.Query = query
+		}
+		for w := range db.watches {
+			if (len(w.table) > 0) && (w.table != e.Table) {
+				continue
+			}
+			select {
+			case w.c <- e:
+			default:
+				w.err = ErrWatchLost
+				delete(db.watches, w)
+				close(w.c)
+			}
+		}
+	}
+}
+
+// closeWatches closes all the watches of the database.
+func (db *DB) closeWatches() {
+	db.wlock.Lock()
+	defer db.wlock.Unlock()
+	for w := range db.watches {
+		delete(db.watches, w)
+		close(w.c)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// access file
+const accFile = "access"
+
+// Valid roles of a user.
+const (
+	// Reader can only read the database.
+	Reader = "reader"
+
+	// Editor can read and modify the database.
+	Editor = "editor"
+)
+
+// User is a user of the database.
+type User struct {
+	// name of the user.
+	Name string
+
+	// role of the user, either Reader or Editor.
+	Role string
+
+	// sha256 hash of the token of the user, in hexadecimal.
+	Key string
+}
+
+// access holds the access policy of a database. Clients with a valid
+// token have the role of its user. Clients without a token can only read
+// the database, except if they are in the local host.
+type access struct {
+	path  string
+	mod   time.Time        // modification time of the access file
+	size  int64            // size of the access file
+	users map[string]*User // map of key:user
+	lock  sync.Mutex
+	log   *accessLog // log of the errors of the access file
+}
+
+// newAccess returns the access policy of a database in a given path.
+func newAccess(path string, log *accessLog) *access {
+	return &access{path: filepath.Join(path, accFile), log: log}
+}
+
+// Auth returns the name of the user of a token, and true if the user can
+// modify the database. If there is no token, the name is empty. An invalid
+// token is an error.
+func (a *access) auth(ip net.IP, token string) (string, bool, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.reload()
+	if len(token) == 0 {
+		return "", (ip != nil) && ip.IsLoopback(), nil
+	}
+	u, ok := a.users[hashToken(token)]
+	if !ok {
+		return "", false, errors.New("invalid token")
+	}
+	return u.Name, u.Role == Editor, nil
+}
+
+// Reload reads the access file if it has been modified.
+func (a *access) reload() {
+	fi, err := os.Stat(a.path)
+	if err != nil {
+		a.users = nil
+		return
+	}
+	if fi.ModTime().Equal(a.mod) && (fi.Size() == a.size) {
+		return
+	}
+	ls, err := readUsers(a.path)
+	if err != nil {
+		a.log.error(err)
+		return
+	}
+	a.mod = fi.ModTime()
+	a.size = fi.Size()
+	a.users = make(map[string]*User)
+	for _, u := range ls {
+		a.users[u.Key] = u
+	}
+}
+
+// HashToken returns the key of a token.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// readUsers reads the users stored in an access file.
+func readUsers(path string) ([]*User, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var ls []*User
+	dec := json.NewDecoder(f)
+	for {
+		u := &User{}
+		if err := dec.Decode(u); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		ls = append(ls, u)
+	}
+	return ls, nil
+}
+
+// writeUsers writes the users of an access file. The file is only
+// readable by its owner.
+func writeUsers(path string, ls []*User) error {
+	f, err := os.OpenFile(path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, u := range ls {
+		if err = enc.Encode(u); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); (cerr != nil) && (err == nil) {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".new")
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
+
+// Users returns the users of the database stored in a given path.
+func Users(path string) ([]*User, error) {
+	return readUsers(filepath.Join(path, accFile))
+}
+
+// AddUser adds a user to the database stored in a given path, and returns
+// the token of the user. If the user already exists, it will receive a new
+// token, and the new role.
+func AddUser(path, name, role string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if len(name) == 0 {
+		return "", errors.New("user without name")
+	}
+	if (role != Reader) && (role != Editor) {
+		return "", errors.New("invalid role: " + role)
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	p := filepath.Join(path, accFile)
+	ls, err := readUsers(p)
+	if err != nil {
+		return "", err
+	}
+	var usr *User
+	for _, u := range ls {
+		if u.Name == name {
+			usr = u
+			break
+		}
+	}
+	if usr == nil {
+		usr = &User{Name: name}
+		ls = append(ls, usr)
+	}
+	usr.Role = role
+	usr.Key = hashToken(token)
+	if err := writeUsers(p, ls); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// DelUser removes a user from the database stored in a given path.
+func DelUser(path, name string) error {
+	name = strings.Join(strings.Fields(name), " ")
+	p := filepath.Join(path, accFile)
+	ls, err := readUsers(p)
+	if err != nil {
+		return err
+	}
+	for i, u := range ls {
+		if u.Name == name {
+			copy(ls[i:], ls[i+1:])
+			ls[len(ls)-1] = nil
+			return writeUsers(p, ls[:len(ls)-1])
+		}
+	}
+	return errors.New("user " + name + " not found")
+}
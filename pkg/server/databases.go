@@ -0,0 +1,275 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	ntv "github.com/js-arias/jdh/pkg/driver/native"
+	"github.com/js-arias/jdh/pkg/native"
+)
+
+// databases file. It stores the databases attached to the server, so they
+// are attached again when the server is restarted.
+const dbsFile = "databases"
+
+// attached is a database attached to the server.
+type attached struct {
+	path  string
+	db    *native.DB
+	users sync.WaitGroup // requests that use the database
+}
+
+// Database returns the database with a given name. If the name is empty,
+// it returns the main database. The returned function must be called when
+// the request finishes using the database, so a detached database is only
+// closed when all of its requests are answered.
+func (srv *server) database(name string) (*native.DB, func(), error) {
+	if len(name) == 0 {
+		return srv.db, func() {}, nil
+	}
+	srv.dlock.Lock()
+	defer srv.dlock.Unlock()
+	a, ok := srv.dbs[name]
+	if !ok {
+		return nil, nil, errors.New("database " + name + " not attached")
+	}
+	a.users.Add(1)
+	return a.db, a.users.Done, nil
+}
+
+// allDBs returns the main database, and the attached databases.
+func (srv *server) allDBs() []*native.DB {
+	srv.dlock.Lock()
+	defer srv.dlock.Unlock()
+	ls := []*native.DB{srv.db}
+	for _, a := range srv.dbs {
+		ls = append(ls, a.db)
+	}
+	return ls
+}
+
+// loadDBs attaches the databases stored in the databases file. Databases
+// that can not be opened are reported in the log, and skipped.
+func (srv *server) loadDBs() error {
+	ls, err := readDBs(filepath.Join(srv.path, dbsFile))
+	if err != nil {
+		return err
+	}
+	for _, d := range ls {
+		if err := srv.inRoot(d.Path); err != nil {
+			srv.log.error(errors.New("database " + d.Name + ": " + err.Error()))
+			continue
+		}
+		db, err := native.Open(d.Path)
+		if err != nil {
+			srv.log.error(errors.New("database " + d.Name + ": " + err.Error()))
+			continue
+		}
+		srv.dbs[d.Name] = &attached{path: d.Path, db: db}
+	}
+	return nil
+}
+
+// closeDBs closes the attached databases.
+func (srv *server) closeDBs() {
+	srv.closing.Wait()
+	srv.dlock.Lock()
+	defer srv.dlock.Unlock()
+	for name, a := range srv.dbs {
+		a.users.Wait()
+		if err := a.db.Close(); err != nil {
+			srv.log.error(errors.New("database " + name + ": " + err.Error()))
+		}
+		delete(srv.dbs, name)
+	}
+}
+
+// InRoot returns an error if a path is not inside the root directory of
+// the attached databases.
+func (srv *server) inRoot(path string) error {
+	if len(srv.root) == 0 {
+		return errors.New("the server does not accept attached databases")
+	}
+	if !filepath.IsAbs(path) {
+		return errors.New("database path must be absolute: " + path)
+	}
+	// links are resolved, so they can not point outside the root.
+	p, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(srv.root, p)
+	if (err != nil) || (rel == "..") || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.New("database " + path + " outside of the root directory of the server")
+	}
+	return nil
+}
+
+// Attach attaches the database stored in a given path, using the
+// indicated name. The path must be inside the root directory of the
+// attached databases.
+func (srv *server) attach(name, path string) error {
+	if !validDBName(name) {
+		return errors.New("invalid database name: " + name)
+	}
+	if err := srv.inRoot(path); err != nil {
+		return err
+	}
+	path = filepath.Clean(path)
+	srv.dlock.Lock()
+	defer srv.dlock.Unlock()
+	if _, ok := srv.dbs[name]; ok {
+		return errors.New("database " + name + " already attached")
+	}
+	if main, err := filepath.Abs(srv.path); (err == nil) && (main == path) {
+		return errors.New("database " + path + " is the main database")
+	}
+	for n, a := range srv.dbs {
+		if a.path == path {
+			return errors.New("database " + path + " already attached as " + n)
+		}
+	}
+	db, err := native.Open(path)
+	if err != nil {
+		return err
+	}
+	srv.dbs[name] = &attached{path: path, db: db}
+	if err := srv.writeDBs(); err != nil {
+		delete(srv.dbs, name)
+		db.Close()
+		return err
+	}
+	return nil
+}
+
+// Detach detaches a database. Uncommitted operations are kept in the
+// journal of the database, and transactions in progress are lost. The
+// database is closed when the requests in progress that use it are
+// answered.
+func (srv *server) detach(name string) error {
+	srv.dlock.Lock()
+	defer srv.dlock.Unlock()
+	a, ok := srv.dbs[name]
+	if !ok {
+		return errors.New("database " + name + " not attached")
+	}
+	delete(srv.dbs, name)
+	if err := srv.writeDBs(); err != nil {
+		srv.dbs[name] = a
+		return err
+	}
+	srv.closing.Add(1)
+	go func() {
+		defer srv.closing.Done()
+		a.users.Wait()
+		if err := a.db.Close(); err != nil {
+			srv.log.error(errors.New("database " + name + ": " + err.Error()))
+		}
+	}()
+	return nil
+}
+
+// listDBs returns the attached databases, sorted by name.
+func (srv *server) listDBs() []*ntv.Database {
+	srv.dlock.Lock()
+	defer srv.dlock.Unlock()
+	return srv.dbList()
+}
+
+// dbList returns the attached databases, sorted by name. It must be
+// called with the lock of the databases.
+func (srv *server) dbList() []*ntv.Database {
+	ls := make([]*ntv.Database, 0, len(srv.dbs))
+	for name, a := range srv.dbs {
+		ls = append(ls, &ntv.Database{Name: name, Path: a.path})
+	}
+	sort.Sort(dbsByName(ls))
+	return ls
+}
+
+// dbsByName sorts databases by its name.
+type dbsByName []*ntv.Database
+
+func (ls dbsByName) Len() int           { return len(ls) }
+func (ls dbsByName) Less(i, j int) bool { return ls[i].Name < ls[j].Name }
+func (ls dbsByName) Swap(i, j int)      { ls[i], ls[j] = ls[j], ls[i] }
+
+// writeDBs writes the databases file. It must be called with the lock of
+// the databases.
+func (srv *server) writeDBs() error {
+	p := filepath.Join(srv.path, dbsFile)
+	f, err := os.OpenFile(p+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, d := range srv.dbList() {
+		if err = enc.Encode(d); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); (cerr != nil) && (err == nil) {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(p + ".new")
+		return err
+	}
+	return os.Rename(p+".new", p)
+}
+
+// readDBs reads the databases stored in a databases file.
+func readDBs(path string) ([]*ntv.Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var ls []*ntv.Database
+	dec := json.NewDecoder(f)
+	for {
+		d := &ntv.Database{}
+		if err := dec.Decode(d); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		ls = append(ls, d)
+	}
+	return ls, nil
+}
+
+// validDBName returns true if a database name is valid. A valid name is
+// made of letters, digits, and the characters '-', '_' and '.'.
+func validDBName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	return strings.IndexFunc(name, func(r rune) bool {
+		switch {
+		case (r >= 'a') && (r <= 'z'), (r >= 'A') && (r <= 'Z'):
+		case (r >= '0') && (r <= '9'):
+		case (r == '-') || (r == '_') || (r == '.'):
+		default:
+			return true
+		}
+		return false
+	}) < 0
+}
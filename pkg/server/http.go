@@ -0,0 +1,316 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	ntv "github.com/js-arias/jdh/pkg/driver/native"
+	"github.com/js-arias/jdh/pkg/jdh"
+	"github.com/js-arias/jdh/pkg/native"
+)
+
+// The HTTP interface of the server maps the tables of the database into
+// paths, and the queries into methods:
+//
+//	GET    /<table>/<id>    get an element
+//	GET    /<table>?<keys>  list the elements of a table
+//	POST   /<table>         add the element in the body
+//	PATCH  /<table>/<id>    set the values of the object in the body
+//	DELETE /<table>/<id>    delete an element
+//	POST   /begin           start a transaction
+//	POST   /commit          commit the database
+//	POST   /rollback        rollback a transaction
+//	POST   /undo?id=<id>    undo a change
+//	GET    /stats           status of the server
+//	GET    /databases       list the attached databases
+//
+// Elements are encoded in JSON, as in the native protocol. The body of a
+// PATCH is a JSON object of key:value pairs, in which the value can be a
+// string, an array of strings, or null. The query parameters are used as
+// keys of the query, except "token", that is the token of the user (it can
+// also be given in an "Authorization: Bearer <token>" header), "tx", that
+// is the transaction of the query, and "db", that is the name of an
+// attached database (by default, the main database of the server is used).
+//
+// As any web page can send requests to the local host, clients without a
+// token can only read the database, even from the local host. If an error
+// happens after the start of a list, the JSON array is closed, and the
+// error is sent in the Jdh-Error trailer.
+
+// jsonType is the content type of the answers.
+const jsonType = "application/json"
+
+// errTrailer is the trailer with the error of a list.
+const errTrailer = "Jdh-Error"
+
+// ServeHTTP answers a request of the HTTP interface.
+func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	q := r.URL.Query()
+	token := q.Get("token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimSpace(h[len("Bearer "):])
+	}
+	req := &ntv.Request{Tx: q.Get("tx"), DB: q.Get("db")}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		if (k == "token") || (k == "tx") || (k == "db") {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		req.Kvs = append(req.Kvs, jdh.KeyValue{Key: jdh.Key(k), Value: q[k]})
+	}
+	sr := &request{Request: req, remote: remote, start: time.Now()}
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	id := ""
+	if len(path) > 2 {
+		srv.httpError(w, sr, "", http.StatusNotFound, "invalid path: "+r.URL.Path)
+		return
+	}
+	if len(path) == 2 {
+		id = path[1]
+	}
+	user, edit, err := srv.acc.auth(net.ParseIP(remote), token)
+	if err != nil {
+		srv.httpError(w, sr, "", http.StatusForbidden, "forbidden: "+err.Error())
+		return
+	}
+	// a web page can not read the token of the user, so requests
+	// without a token can not modify the database.
+	if len(token) == 0 {
+		edit = false
+	}
+	if (r.Method != "GET") && !edit {
+		srv.httpError(w, sr, user, http.StatusForbidden, "forbidden")
+		return
+	}
+	db, release, err := srv.database(req.DB)
+	if err != nil {
+		srv.httpError(w, sr, user, http.StatusNotFound, err.Error())
+		return
+	}
+	defer release()
+	if (r.Method == "POST") && (len(id) == 0) {
+		switch jdh.Query(path[0]) {
+		case jdh.Begin:
+			req.Query = jdh.Begin
+			tx := db.Begin(txOwner(user, remote))
+			srv.httpAnswer(w, sr, user, http.StatusCreated, tx.Id())
+			return
+		case jdh.Commit:
+			req.Query = jdh.Commit
+			if err := srv.commit(db, req.Tx, txOwner(user, remote)); err != nil {
+				srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+				return
+			}
+			srv.httpAnswer(w, sr, user, http.StatusOK, "")
+			return
+		case jdh.Rollback:
+			req.Query = jdh.Rollback
+			tx, err := db.Tx(req.Tx, txOwner(user, remote))
+			if err == nil {
+				err = tx.Rollback()
+			}
+			if err != nil {
+				srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+				return
+			}
+			srv.httpAnswer(w, sr, user, http.StatusOK, "")
+			return
+		case jdh.Undo:
+			req.Query = jdh.Undo
+			if err := db.Undo(q.Get(string(jdh.KeyId))); err != nil {
+				srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+				return
+			}
+			srv.httpAnswer(w, sr, user, http.StatusOK, "")
+			return
+		}
+	}
+	req.Table = jdh.Table(path[0])
+	switch {
+	case (r.Method == "GET") && (req.Table == jdh.Stats):
+		req.Query = jdh.Get
+		w.Header().Set("Content-Type", jsonType)
+		json.NewEncoder(w).Encode(srv.status(db))
+		srv.logReq(sr, user, 1, ntv.Success("ok"))
+	case (r.Method == "GET") && (req.Table == ntv.Databases):
+		req.Query = jdh.List
+		ls := srv.listDBs()
+		w.Header().Set("Content-Type", jsonType)
+		json.NewEncoder(w).Encode(ls)
+		srv.logReq(sr, user, len(ls), ntv.Success("ok"))
+	case (r.Method == "GET") && (len(id) > 0):
+		req.Query = jdh.Get
+		req.Kvs = []jdh.KeyValue{{Key: jdh.KeyId, Value: []string{id}}}
+		var buf bytes.Buffer
+		if err := db.EncodeGet(req.Table, id, &buf); err != nil {
+			srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+			return
+		}
+		if isNull(buf.Bytes()) {
+			srv.httpError(w, sr, user, http.StatusNotFound, "element "+id+" not found")
+			return
+		}
+		w.Header().Set("Content-Type", jsonType)
+		buf.WriteTo(w)
+		srv.logReq(sr, user, 1, ntv.Success("ok"))
+	case r.Method == "GET":
+		req.Query = jdh.List
+		srv.httpList(w, db, sr, user)
+	case (r.Method == "POST") && (len(id) == 0):
+		req.Query = jdh.Add
+		ed, err := srv.editor(db, req.Tx, txOwner(user, remote))
+		if err != nil {
+			srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+			return
+		}
+		nid, err := ed.Add(req.Table, json.NewDecoder(r.Body))
+		if err != nil {
+			srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+			return
+		}
+		srv.httpAnswer(w, sr, user, http.StatusCreated, nid)
+	case (r.Method == "PATCH") && (len(id) > 0):
+		req.Query = jdh.Set
+		kvs, err := decodeKvs(r.Body)
+		if err != nil {
+			srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Kvs = append([]jdh.KeyValue{{Key: jdh.KeyId, Value: []string{id}}}, kvs...)
+		ed, err := srv.editor(db, req.Tx, txOwner(user, remote))
+		if err == nil {
+			err = ed.Set(req.Table, req.Kvs)
+		}
+		if err != nil {
+			srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+			return
+		}
+		srv.httpAnswer(w, sr, user, http.StatusOK, id)
+	case (r.Method == "DELETE") && (len(id) > 0):
+		req.Query = jdh.Delete
+		req.Kvs = append([]jdh.KeyValue{{Key: jdh.KeyId, Value: []string{id}}}, req.Kvs...)
+		ed, err := srv.editor(db, req.Tx, txOwner(user, remote))
+		if err == nil {
+			err = ed.Delete(req.Table, req.Kvs)
+		}
+		if err != nil {
+			srv.httpError(w, sr, user, http.StatusBadRequest, err.Error())
+			return
+		}
+		srv.httpAnswer(w, sr, user, http.StatusOK, id)
+	default:
+		w.Header().Set("Allow", "GET, POST, PATCH, DELETE")
+		srv.httpError(w, sr, user, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed")
+	}
+}
+
+// HttpList sends the elements of a list query as a JSON array.
+func (srv *server) httpList(w http.ResponseWriter, db *native.DB, r *request, user string) {
+	started, empty := false, true
+	n, err := pages(db, r.Table, r.Kvs, func(b []byte) error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", jsonType)
+			w.Header().Set("Trailer", errTrailer)
+			io.WriteString(w, "[")
+		}
+		// each element is encoded in a single line.
+		b = bytes.TrimRight(b, "\n")
+		if len(b) == 0 {
+			return nil
+		}
+		if !empty {
+			io.WriteString(w, ",")
+		}
+		empty = false
+		_, err := w.Write(bytes.Replace(b, []byte("\n"), []byte(","), -1))
+		return err
+	})
+	if !started {
+		srv.httpError(w, r, user, http.StatusBadRequest, err.Error())
+		return
+	}
+	io.WriteString(w, "]\n")
+	if err != nil {
+		w.Header().Set(errTrailer, err.Error())
+		srv.logReq(r, user, n, ntv.ErrAnswer(err.Error()))
+		return
+	}
+	srv.logReq(r, user, n, ntv.Success("ok"))
+}
+
+// DecodeKvs decodes a JSON object of key:value pairs, keeping the order
+// of the keys.
+func decodeKvs(r io.Reader) ([]jdh.KeyValue, error) {
+	dec := json.NewDecoder(r)
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if d, ok := t.(json.Delim); !ok || (d != '{') {
+		return nil, errors.New("expecting a JSON object")
+	}
+	var kvs []jdh.KeyValue
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		kv := jdh.KeyValue{Key: jdh.Key(t.(string))}
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		switch val := v.(type) {
+		case nil:
+		case string:
+			kv.Value = []string{val}
+		case []interface{}:
+			for _, e := range val {
+				s, ok := e.(string)
+				if !ok {
+					return nil, errors.New("invalid value for key " + string(kv.Key))
+				}
+				kv.Value = append(kv.Value, s)
+			}
+		default:
+			return nil, errors.New("invalid value for key " + string(kv.Key))
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs, nil
+}
+
+// HttpAnswer sends the answer of a successful query. The id, if any, is
+// sent as a JSON object.
+func (srv *server) httpAnswer(w http.ResponseWriter, r *request, user string, code int, id string) {
+	w.Header().Set("Content-Type", jsonType)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+	srv.logReq(r, user, 0, ntv.Success(id))
+}
+
+// HttpError sends an error as a JSON object.
+func (srv *server) httpError(w http.ResponseWriter, r *request, user string, code int, msg string) {
+	w.Header().Set("Content-Type", jsonType)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+	srv.logReq(r, user, 0, ntv.ErrAnswer(msg))
+}
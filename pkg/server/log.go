@@ -0,0 +1,162 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// An Entry is an entry of the access log of the server. The log is stored
+// as JSON lines, one entry for each request. The errors of the server that
+// are not part of a request (e.g. a failed automatic commit) are stored as
+// entries with only the time and the error.
+type Entry struct {
+	// time at which the request was received.
+	Time time.Time
+
+	// address of the client, and name of its user, if any.
+	Remote string `json:",omitempty"`
+	User   string `json:",omitempty"`
+
+	// the request.
+	DB    string         `json:",omitempty"`
+	Query jdh.Query      `json:",omitempty"`
+	Table jdh.Table      `json:",omitempty"`
+	Tx    string         `json:",omitempty"`
+	Kvs   []jdh.KeyValue `json:",omitempty"`
+
+	// time used to answer the request, in nanoseconds.
+	Duration time.Duration `json:",omitempty"`
+
+	// number of elements returned by a get or a list request.
+	Count int `json:",omitempty"`
+
+	// result of the request (e.g. the id of an added element), or the
+	// error, if the request fails.
+	Result string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+// Default values of the log rotation.
+const (
+	// LogSize is the default size, in bytes, at which a log file is
+	// rotated.
+	LogSize = 10 << 20
+
+	// logKeep is the number of rotated log files that are kept.
+	logKeep = 5
+)
+
+// accessLog is the access log of the server. If it is written into a
+// file, the file is rotated when it reaches its maximum size: the file is
+// renamed with the extension ".1", the previous ".1" file is renamed ".2"
+// and so on, and the oldest file is removed.
+type accessLog struct {
+	path string // if empty, the log is written to the standard output
+	max  int64  // maximum size of the file
+	f    *os.File
+	w    io.Writer
+	size int64 // size of the current file
+	lock sync.Mutex
+}
+
+// openLog opens an access log in a given path. If path is empty, the log
+// will be written to the standard output.
+func openLog(path string, max int64) (*accessLog, error) {
+	l := &accessLog{path: path, max: max, w: os.Stdout}
+	if len(path) == 0 {
+		return l, nil
+	}
+	if l.max <= 0 {
+		l.max = LogSize
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Open opens the log file.
+func (l *accessLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.w, l.size = f, f, fi.Size()
+	return nil
+}
+
+// Write writes an entry into the log.
+func (l *accessLog) write(e *Entry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		b = errEntry(err)
+	}
+	b = append(b, '\n')
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if (l.f != nil) && (l.size > 0) && (l.size+int64(len(b)) > l.max) {
+		if err := l.rotate(); err != nil {
+			b = append(append(errEntry(err), '\n'), b...)
+		}
+	}
+	n, err := l.w.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		// the log can not be used, so the error is reported in the
+		// standard error.
+		os.Stderr.Write(append(errEntry(err), '\n'))
+	}
+}
+
+// Error writes an error of the server into the log.
+func (l *accessLog) error(err error) {
+	l.write(&Entry{Time: time.Now(), Error: err.Error()})
+}
+
+// ErrEntry returns the encoding of an entry with an error.
+func errEntry(err error) []byte {
+	b, _ := json.Marshal(&Entry{Time: time.Now(), Error: err.Error()})
+	return b
+}
+
+// Rotate renames the current log file, and opens a new one.
+func (l *accessLog) rotate() error {
+	l.f.Close()
+	os.Remove(l.path + "." + strconv.Itoa(logKeep))
+	for i := logKeep - 1; i > 0; i-- {
+		os.Rename(l.path+"."+strconv.Itoa(i), l.path+"."+strconv.Itoa(i+1))
+	}
+	err := os.Rename(l.path, l.path+".1")
+	if oerr := l.open(); oerr != nil {
+		return oerr
+	}
+	return err
+}
+
+// Close closes the log.
+func (l *accessLog) close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f, l.w = nil, ioutil.Discard
+	return err
+}
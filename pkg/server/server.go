@@ -6,11 +6,15 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
-	"os"
-	"strings"
+	"net/http"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -23,72 +27,379 @@ import (
 type server struct {
 	ln   net.Listener
 	conn chan net.Conn
+	reqs chan *request // requests from connections and sessions
 	end  chan struct{}
-	db   *native.DB
+	once sync.Once  // closes end only once
+	db   *native.DB // main database
+	path string     // path of the main database
+	acc  *access
+	log  *accessLog
+
+	// attached databases
+	dbs     map[string]*attached
+	dlock   sync.Mutex
+	root    string         // directory of the attached databases
+	closing sync.WaitGroup // detached databases that are being closed
+
+	// statistics of the server
+	start  time.Time           // time at which the server was started
+	counts map[jdh.Query]int64 // number of requests by query
+	errs   int64               // number of failed requests
+	slock  sync.Mutex          // lock of the statistics
+}
+
+// Config is the configuration of a server.
+type Config struct {
+	// port in which the server will be listening. If empty, the default
+	// jdh port will be used.
+	Port string
+
+	// path of the main database. Other databases can be attached to the
+	// server while it is running.
+	Path string
+
+	// certificate and key files. If defined, the server will only accept
+	// TLS connections.
+	Cert string
+	Key  string
+
+	// address in which the HTTP interface of the server will be
+	// listening. If empty, the HTTP interface is not served.
+	HTTP string
+
+	// directory of the databases that can be attached to the server.
+	// Only databases inside this directory can be attached. If empty,
+	// no database can be attached.
+	Root string
+
+	// if not nil, the server is stopped when the channel is closed.
+	Stop <-chan struct{}
+
+	// if true, the database is committed when the server is stopped.
+	Commit bool
+
+	// interval between automatic commits of the database. If 0, the
+	// database is only committed when a client requests it.
+	AutoCommit time.Duration
+
+	// file of the access log. If empty, the log is written to the
+	// standard output.
+	Log string
+
+	// size, in bytes, at which the log file is rotated. If 0, LogSize
+	// will be used.
+	LogSize int64
 }
 
 // Listen creates a server of a database in the local host.
 func Listen(port, path string) error {
+	return Serve(&Config{Port: port, Path: path})
+}
+
+// Serve creates a server of a database with a given configuration. The
+// server runs until a client sends a close request, or the Stop channel of
+// the configuration is closed. When the server is stopped, it stops
+// accepting connections, and waits until all the requests in progress are
+// answered.
+func Serve(cfg *Config) error {
+	port := cfg.Port
+	if len(port) == 0 {
+		port = ntv.Port
+	}
+	var tlsCfg *tls.Config
+	if (len(cfg.Cert) > 0) || (len(cfg.Key) > 0) {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return err
+		}
+		tlsCfg = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	root := ""
+	if len(cfg.Root) > 0 {
+		p, err := filepath.Abs(cfg.Root)
+		if err == nil {
+			p, err = filepath.EvalSymlinks(p)
+		}
+		if err != nil {
+			return err
+		}
+		root = p
+	}
+	alog, err := openLog(cfg.Log, cfg.LogSize)
+	if err != nil {
+		return err
+	}
+	defer alog.close()
+	db, err := native.Open(cfg.Path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 	srv := &server{
 		conn: make(chan net.Conn, 10),
+		reqs: make(chan *request, 10),
 		end:  make(chan struct{}),
-		db:   native.Open(path),
+		db:   db,
+		path: cfg.Path,
+		acc:  newAccess(cfg.Path, alog),
+		log:  alog,
+		dbs:  make(map[string]*attached),
+		root: root,
+
+		start:  time.Now(),
+		counts: make(map[jdh.Query]int64),
 	}
-	if len(port) == 0 {
-		port = ntv.Port
+	if err := srv.loadDBs(); err != nil {
+		return err
+	}
+	defer srv.closeDBs()
+	if tlsCfg != nil {
+		srv.ln, err = tls.Listen("tcp", port, tlsCfg)
+	} else {
+		srv.ln, err = net.Listen("tcp", port)
 	}
-	var err error
-	srv.ln, err = net.Listen("tcp", port)
 	if err != nil {
 		return err
 	}
 	defer srv.ln.Close()
 
-	go func() {
-		for {
-			c, err := srv.ln.Accept()
-			if err != nil {
-				fmt.Fprintf(os.Stdout, "error [%s] %v\n", time.Now().Format("2006-Jan-2 15:04:05 -0700"), err)
-				continue
+	var hs *http.Server
+	if len(cfg.HTTP) > 0 {
+		var hl net.Listener
+		if tlsCfg != nil {
+			hl, err = tls.Listen("tcp", cfg.HTTP, tlsCfg)
+		} else {
+			hl, err = net.Listen("tcp", cfg.HTTP)
+		}
+		if err != nil {
+			return err
+		}
+		hs = &http.Server{Handler: srv}
+		go hs.Serve(hl)
+	}
+
+	go srv.accept()
+	var tick <-chan time.Time
+	if cfg.AutoCommit > 0 {
+		t := time.NewTicker(cfg.AutoCommit)
+		defer t.Stop()
+		tick = t.C
+	}
+	var done sync.WaitGroup
+	for {
+		select {
+		case c := <-srv.conn:
+			done.Add(1)
+			go func() {
+				defer done.Done()
+				srv.handleConn(c, &done)
+			}()
+		case r := <-srv.reqs:
+			srv.handle(r, &done)
+		case <-tick:
+			for _, db := range srv.allDBs() {
+				if err := db.Commit(); err != nil {
+					srv.log.error(fmt.Errorf("auto-commit: %v", err))
+				}
+			}
+		case <-cfg.Stop:
+			srv.stop()
+		case <-srv.end:
+			srv.ln.Close()
+			if hs != nil {
+				hs.Shutdown(context.Background())
+			}
+			done.Wait()
+			// connections accepted but not handled are closed.
+			for len(srv.conn) > 0 {
+				(<-srv.conn).Close()
 			}
+			for len(srv.reqs) > 0 {
+				(<-srv.reqs).finish()
+			}
+			if !cfg.Commit {
+				return nil
+			}
+			var cerr error
+			for _, db := range srv.allDBs() {
+				if err := db.Commit(); err != nil {
+					srv.log.error(err)
+					cerr = err
+				}
+			}
+			return cerr
+		}
+	}
+}
+
+// Accept accepts the connections of the server, until the server is
+// stopped.
+func (srv *server) accept() {
+	var delay time.Duration
+	for {
+		c, err := srv.ln.Accept()
+		if err != nil {
 			select {
-			case srv.conn <- c:
 			case <-srv.end:
 				return
+			default:
+			}
+			srv.log.error(err)
+			// on temporary errors, e.g. too many open files, waits
+			// before a new try.
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > time.Second {
+				delay = time.Second
 			}
+			time.Sleep(delay)
+			continue
 		}
-	}()
-	var done sync.WaitGroup
-	for {
+		delay = 0
 		select {
-		case c := <-srv.conn:
-			srv.handleConn(c, &done)
+		case srv.conn <- c:
 		case <-srv.end:
-			done.Wait()
-			return nil
+			c.Close()
+			return
 		}
 	}
 }
 
-// handleConn handles the connection
+// Stop stops the server.
+func (srv *server) stop() {
+	srv.once.Do(func() { close(srv.end) })
+}
+
+// request is a request received by the server.
+type request struct {
+	*ntv.Request
+	remote string        // address of the client
+	start  time.Time     // time at which the request was received
+	w      io.Writer     // writer of the answer
+	dec    *json.Decoder // decoder of the element of an addition
+	finish func()        // called when the answer is complete
+
+	// if not nil, it is called by the handlers that answer in their own
+	// goroutine, so the answer can wait for a slow client.
+	wait func()
+}
+
+// reqTimeout is the time in which a new connection must send its request
+// (including the TLS handshake, and the element of an addition).
+const reqTimeout = 30 * time.Second
+
+// handleConn reads the request of a new connection, and sends it to be
+// handled by the server. As it waits for the client, it must be called
+// in its own goroutine.
 func (srv *server) handleConn(conn net.Conn, done *sync.WaitGroup) {
-	remote := strings.Split(conn.RemoteAddr().String(), ":")[0]
+	remote := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	start := time.Now()
+	conn.SetDeadline(start.Add(reqTimeout))
+	// if the server is stopped while the request is read, the
+	// connection is closed.
+	read := make(chan struct{})
+	defer close(read)
+	go func() {
+		select {
+		case <-srv.end:
+			conn.Close()
+		case <-read:
+		}
+	}()
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			return
+		}
+	}
 	dec := json.NewDecoder(conn)
-	enc := json.NewEncoder(conn)
 	req := &ntv.Request{}
 	if err := dec.Decode(req); err != nil {
 		ans := ntv.ErrAnswer(err.Error())
-		enc.Encode(ans)
-		log(remote, req, ans)
+		json.NewEncoder(conn).Encode(ans)
+		srv.logReq(&request{Request: req, remote: remote, start: start}, "", 0, ans)
 		conn.Close()
 		return
 	}
+	if req.Query == ntv.Session {
+		conn.SetDeadline(time.Time{})
+		srv.session(conn, dec, remote)
+		return
+	}
+	if req.Query == ntv.Watch {
+		conn.SetDeadline(time.Time{})
+		srv.watch(conn, &request{Request: req, remote: remote, start: start}, done)
+		return
+	}
+	// the read deadline is kept, as the element of an addition is read
+	// when the request is handled.
+	conn.SetWriteDeadline(time.Time{})
+	r := &request{
+		Request: req,
+		remote:  remote,
+		start:   start,
+		w:       conn,
+		dec:     dec,
+		finish:  func() { conn.Close() },
+	}
+	select {
+	case srv.reqs <- r:
+	case <-srv.end:
+		conn.Close()
+	}
+}
+
+// LocalEditor returns true if a client is an editor in the local host.
+// Only local editors can close the server, and attach or detach
+// databases.
+func localEditor(remote string, edit bool) bool {
+	ip := net.ParseIP(remote)
+	return edit && (ip != nil) && ip.IsLoopback()
+}
+
+// handle answers a request.
+func (srv *server) handle(r *request, done *sync.WaitGroup) {
+	req, remote := r.Request, r.remote
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	dec := r.dec
+	enc := json.NewEncoder(r.w)
+	user, edit, err := srv.acc.auth(net.ParseIP(remote), req.Token)
+	if err != nil {
+		ans := ntv.ErrAnswer("forbidden: " + err.Error())
+		enc.Encode(ans)
+		srv.logReq(r, "", 0, ans)
+		r.finish()
+		return
+	}
 	table := req.Table
+	db, release, err := srv.database(req.DB)
+	if err != nil {
+		ans := ntv.ErrAnswer(err.Error())
+		enc.Encode(ans)
+		srv.logReq(r, user, 0, ans)
+		r.finish()
+		return
+	}
+	finish := r.finish
+	r.finish = func() {
+		release()
+		finish()
+	}
 	switch req.Query {
 	case jdh.Add:
 		var ans *ntv.Answer
-		if remote == "127.0.0.1" {
-			if id, err := srv.db.Add(table, dec); err != nil {
+		if edit {
+			if ed, err := srv.editor(db, req.Tx, txOwner(user, remote)); err != nil {
+				ans = ntv.ErrAnswer(err.Error())
+			} else if id, err := ed.Add(table, dec); err != nil {
 				ans = ntv.ErrAnswer(err.Error())
 			} else {
 				ans = ntv.Success(id)
@@ -97,21 +408,63 @@ func (srv *server) handleConn(conn net.Conn, done *sync.WaitGroup) {
 			ans = ntv.ErrAnswer("forbidden")
 		}
 		enc.Encode(ans)
-		log(remote, req, ans)
-	case jdh.Close:
+		srv.logReq(r, user, 0, ans)
+	case ntv.Attach, ntv.Detach:
+		var ans *ntv.Answer
+		if localEditor(remote, edit) {
+			name, path := "", ""
+			for _, kv := range req.Kvs {
+				if len(kv.Value) == 0 {
+					continue
+				}
+				switch kv.Key {
+				case jdh.KeyId:
+					name = kv.Value[0]
+				case ntv.KeyPath:
+					path = kv.Value[0]
+				}
+			}
+			var err error
+			if req.Query == ntv.Attach {
+				err = srv.attach(name, path)
+			} else {
+				err = srv.detach(name)
+			}
+			if err != nil {
+				ans = ntv.ErrAnswer(err.Error())
+			} else {
+				ans = ntv.Success("ok")
+			}
+		} else {
+			ans = ntv.ErrAnswer("forbidden")
+		}
+		enc.Encode(ans)
+		srv.logReq(r, user, 0, ans)
+	case jdh.Begin:
 		var ans *ntv.Answer
-		if remote == "127.0.0.1" {
-			ans = ntv.Success("ok")
+		if edit {
+			tx := db.Begin(txOwner(user, remote))
+			ans = ntv.Success(tx.Id())
 		} else {
 			ans = ntv.ErrAnswer("forbidden")
 		}
 		enc.Encode(ans)
-		log(remote, req, ans)
-		close(srv.end)
+		srv.logReq(r, user, 0, ans)
+	case jdh.Close:
+		if !localEditor(remote, edit) {
+			ans := ntv.ErrAnswer("forbidden")
+			enc.Encode(ans)
+			srv.logReq(r, user, 0, ans)
+			break
+		}
+		ans := ntv.Success("ok")
+		enc.Encode(ans)
+		srv.logReq(r, user, 0, ans)
+		srv.stop()
 	case jdh.Commit:
 		var ans *ntv.Answer
-		if remote == "127.0.0.1" {
-			if err := srv.db.Commit(); err != nil {
+		if edit {
+			if err := srv.commit(db, req.Tx, txOwner(user, remote)); err != nil {
 				ans = ntv.ErrAnswer(err.Error())
 			} else {
 				ans = ntv.Success("ok")
@@ -120,14 +473,16 @@ func (srv *server) handleConn(conn net.Conn, done *sync.WaitGroup) {
 			ans = ntv.ErrAnswer("forbidden")
 		}
 		enc.Encode(ans)
-		log(remote, req, ans)
+		srv.logReq(r, user, 0, ans)
 	case jdh.Delete:
 		var ans *ntv.Answer
-		if remote == "127.0.0.1" {
+		if edit {
 			if len(req.Kvs) == 0 {
 				ans = ntv.ErrAnswer("expecting arguments")
 			} else {
-				if err := srv.db.Delete(table, req.Kvs); err != nil {
+				if ed, err := srv.editor(db, req.Tx, txOwner(user, remote)); err != nil {
+					ans = ntv.ErrAnswer(err.Error())
+				} else if err := ed.Delete(table, req.Kvs); err != nil {
 					ans = ntv.ErrAnswer(err.Error())
 				} else {
 					ans = ntv.Success("ok")
@@ -137,12 +492,15 @@ func (srv *server) handleConn(conn net.Conn, done *sync.WaitGroup) {
 			ans = ntv.ErrAnswer("forbidden")
 		}
 		enc.Encode(ans)
-		log(remote, req, ans)
+		srv.logReq(r, user, 0, ans)
 	case jdh.Get:
 		done.Add(1)
 		go func() {
-			defer conn.Close()
+			defer r.finish()
 			defer done.Done()
+			if r.wait != nil {
+				r.wait()
+			}
 			id := ""
 			for _, kv := range req.Kvs {
 				if len(kv.Value) == 0 {
@@ -153,46 +511,76 @@ func (srv *server) handleConn(conn net.Conn, done *sync.WaitGroup) {
 					break
 				}
 			}
-			v, err := srv.db.Get(table, id)
+			var buf bytes.Buffer
+			var err error
+			if table == jdh.Stats {
+				err = json.NewEncoder(&buf).Encode(srv.status(db))
+			} else {
+				err = db.EncodeGet(table, id, &buf)
+			}
 			if err != nil {
 				ans := ntv.ErrAnswer(err.Error())
 				enc.Encode(ans)
-				log(remote, req, ans)
+				srv.logReq(r, user, 0, ans)
 				return
 			}
 			ans := ntv.Success("ok")
 			enc.Encode(ans)
-			enc.Encode(v)
-			log(remote, req, ans)
+			n := 1
+			if isNull(buf.Bytes()) {
+				n = 0
+			}
+			buf.WriteTo(r.w)
+			srv.logReq(r, user, n, ans)
 		}()
 		return
 	case jdh.List:
 		done.Add(1)
 		go func() {
-			defer conn.Close()
+			defer r.finish()
 			defer done.Done()
-			l, err := srv.db.List(table, req.Kvs)
-			if err != nil {
-				ans := ntv.ErrAnswer(err.Error())
-				enc.Encode(ans)
-				log(remote, req, ans)
-				return
+			if r.wait != nil {
+				r.wait()
 			}
-			ans := ntv.Success("ok")
-			enc.Encode(ans)
-			for e := l.Front(); e != nil; e = e.Next() {
-				enc.Encode(e.Value)
+			var ans *ntv.Answer
+			n := 0
+			if table == ntv.Databases {
+				ans = ntv.Success("ok")
+				enc.Encode(ans)
+				for _, d := range srv.listDBs() {
+					enc.Encode(d)
+					n++
+				}
+			} else {
+				ans, n = srv.list(db, r.w, req)
 			}
-			log(remote, req, ans)
+			srv.logReq(r, user, n, ans)
 		}()
 		return
+	case jdh.Rollback:
+		var ans *ntv.Answer
+		if edit {
+			if tx, err := db.Tx(req.Tx, txOwner(user, remote)); err != nil {
+				ans = ntv.ErrAnswer(err.Error())
+			} else if err := tx.Rollback(); err != nil {
+				ans = ntv.ErrAnswer(err.Error())
+			} else {
+				ans = ntv.Success("ok")
+			}
+		} else {
+			ans = ntv.ErrAnswer("forbidden")
+		}
+		enc.Encode(ans)
+		srv.logReq(r, user, 0, ans)
 	case jdh.Set:
 		var ans *ntv.Answer
-		if remote == "127.0.0.1" {
+		if edit {
 			if len(req.Kvs) == 0 {
 				ans = ntv.ErrAnswer("expecting arguments")
 			} else {
-				if err := srv.db.Set(table, req.Kvs); err != nil {
+				if ed, err := srv.editor(db, req.Tx, txOwner(user, remote)); err != nil {
+					ans = ntv.ErrAnswer(err.Error())
+				} else if err := ed.Set(table, req.Kvs); err != nil {
 					ans = ntv.ErrAnswer(err.Error())
 				} else {
 					ans = ntv.Success("ok")
@@ -202,26 +590,184 @@ func (srv *server) handleConn(conn net.Conn, done *sync.WaitGroup) {
 			ans = ntv.ErrAnswer("forbidden")
 		}
 		enc.Encode(ans)
-		log(remote, req, ans)
+		srv.logReq(r, user, 0, ans)
+	case jdh.Undo:
+		var ans *ntv.Answer
+		if edit {
+			id := ""
+			for _, kv := range req.Kvs {
+				if len(kv.Value) == 0 {
+					continue
+				}
+				if kv.Key == jdh.KeyId {
+					id = kv.Value[0]
+					break
+				}
+			}
+			if err := db.Undo(id); err != nil {
+				ans = ntv.ErrAnswer(err.Error())
+			} else {
+				ans = ntv.Success("ok")
+			}
+		} else {
+			ans = ntv.ErrAnswer("forbidden")
+		}
+		enc.Encode(ans)
+		srv.logReq(r, user, 0, ans)
+	case ntv.Watch:
+		ans := ntv.ErrAnswer("watch requires its own connection")
+		enc.Encode(ans)
+		srv.logReq(r, user, 0, ans)
 	default:
 		ans := ntv.ErrAnswer("not implemented")
 		enc.Encode(ans)
-		log(remote, req, ans)
+		srv.logReq(r, user, 0, ans)
+	}
+	r.finish()
+}
+
+// pageSize is the number of elements of a list sent in a single step.
+const pageSize = 256
+
+// List sends the elements of a list query, and returns the answer, and the
+// number of elements sent.
+func (srv *server) list(db *native.DB, w io.Writer, req *ntv.Request) (*ntv.Answer, int) {
+	ans := ntv.Success("ok")
+	started := false
+	n, err := pages(db, req.Table, req.Kvs, func(b []byte) error {
+		if !started {
+			started = true
+			json.NewEncoder(w).Encode(ans)
+		}
+		_, err := w.Write(b)
+		return err
+	})
+	if (err != nil) && !started {
+		ans = ntv.ErrAnswer(err.Error())
+		json.NewEncoder(w).Encode(ans)
+	}
+	return ans, n
+}
+
+// Pages retrieves the elements of a list query in pages, and calls page
+// with the encoded elements of each page. The list is retrieved only once,
+// and the database is only locked during the encoding of each page, so the
+// database is not blocked by a large list, or a slow connection. Page is
+// called at least one time, even if the list is empty. It returns the
+// number of elements retrieved.
+func pages(db *native.DB, table jdh.Table, vals []jdh.KeyValue, page func(b []byte) error) (int, error) {
+	cur, err := db.Cursor(table, vals)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	for sent := 0; ; {
+		buf.Reset()
+		c, err := cur.Encode(&buf, pageSize)
+		if err != nil {
+			return sent, err
+		}
+		if err := page(buf.Bytes()); err != nil {
+			return sent, err
+		}
+		sent += c
+		if cur.Len() == 0 {
+			return sent, nil
+		}
+	}
+}
+
+// editor is a type that modifies the database.
+type editor interface {
+	Add(table jdh.Table, dec *json.Decoder) (string, error)
+	Delete(table jdh.Table, vals []jdh.KeyValue) error
+	Set(table jdh.Table, vals []jdh.KeyValue) error
+}
+
+// Editor returns the transaction of a database with the given id, or the
+// database, if there is no transaction.
+func (srv *server) editor(db *native.DB, id, owner string) (editor, error) {
+	if len(id) == 0 {
+		return db, nil
+	}
+	return db.Tx(id, owner)
+}
+
+// TxOwner returns the owner of the transactions started by a user. As
+// requests without a token are only accepted as edits from the local host,
+// its transactions are owned by the remote address.
+func txOwner(user, remote string) string {
+	if len(user) == 0 {
+		return remote
+	}
+	return user
+}
+
+// Commit finishes a transaction, if any, and then commits the database.
+// If the transaction is committed, but the database can not be written,
+// the error reports that the operations of the transaction were kept.
+func (srv *server) commit(db *native.DB, id, owner string) error {
+	if len(id) > 0 {
+		tx, err := db.Tx(id, owner)
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := db.Commit(); err != nil {
+			return fmt.Errorf("transaction %s committed, but the database was not written: %v", id, err)
+		}
+		return nil
 	}
-	conn.Close()
+	return db.Commit()
 }
 
-func log(remote string, req *ntv.Request, ans *ntv.Answer) {
-	msg := remote
-	msg += fmt.Sprintf(" [%s]", time.Now().Format("2006-01-02 15:04:05 -0700"))
-	msg += fmt.Sprintf(" \"%s", req.Query)
-	if len(req.Table) > 0 {
-		msg += fmt.Sprintf(" %s", req.Table)
+// LogReq writes a request in the access log. N is the number of elements
+// returned by the request.
+func (srv *server) logReq(r *request, user string, n int, ans *ntv.Answer) {
+	e := &Entry{
+		Time:     r.start,
+		Remote:   r.remote,
+		User:     user,
+		DB:       r.DB,
+		Query:    r.Query,
+		Table:    r.Table,
+		Tx:       r.Tx,
+		Kvs:      r.Kvs,
+		Duration: time.Since(r.start),
+		Count:    n,
 	}
-	for _, kv := range req.Kvs {
-		msg += fmt.Sprintf(" %s=%s", kv.Key, kv.Value)
+	if msg, err := ans.GetMessage(); err != nil {
+		e.Error = err.Error()
+	} else if msg != "ok" {
+		e.Result = msg
 	}
-	msg += `"`
-	msg += fmt.Sprintf(" %s", ans.Message)
-	fmt.Fprintf(os.Stdout, "%s\n", msg)
+	srv.slock.Lock()
+	srv.counts[e.Query]++
+	if len(e.Error) > 0 {
+		srv.errs++
+	}
+	srv.slock.Unlock()
+	srv.log.write(e)
+}
+
+// Status returns the status of the server, and a database of the server.
+func (srv *server) status(db *native.DB) *jdh.Status {
+	st := db.Status()
+	st.Start = srv.start
+	st.Requests = make(map[jdh.Query]int64)
+	srv.slock.Lock()
+	defer srv.slock.Unlock()
+	for q, n := range srv.counts {
+		st.Requests[q] = n
+	}
+	st.Errors = srv.errs
+	return st
+}
+
+// IsNull returns true if an encoded element is null.
+func isNull(b []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(b), []byte("null"))
 }
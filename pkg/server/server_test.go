@@ -0,0 +1,309 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ntv "github.com/js-arias/jdh/pkg/driver/native"
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// startServer starts a server with a given configuration, in a free port
+// of the local host. It returns the address of the server, and a function
+// that stops the server, and returns the error of Serve.
+func startServer(t *testing.T, cfg *Config) (string, func() error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	if len(cfg.Path) == 0 {
+		cfg.Path = t.TempDir()
+	}
+	if len(cfg.Log) == 0 {
+		cfg.Log = filepath.Join(t.TempDir(), "access.log")
+	}
+	cfg.Port = addr
+	stop := make(chan struct{})
+	cfg.Stop = stop
+	errc := make(chan error, 1)
+	go func() { errc <- Serve(cfg) }()
+	for i := 0; ; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			c.Close()
+			break
+		}
+		select {
+		case err := <-errc:
+			t.Fatalf("server: %v", err)
+		default:
+		}
+		if i > 100 {
+			t.Fatalf("server not started: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return addr, func() error {
+		close(stop)
+		return <-errc
+	}
+}
+
+// TestConcurrent runs reads of the server at the same time as writes,
+// transactions and commits from other clients, so it should be run with
+// the race detector.
+func TestConcurrent(t *testing.T) {
+	addr, stop := startServer(t, &Config{})
+	// the clients are not closed, as closing a client of the native
+	// driver stops the server.
+	open := func() jdh.DB {
+		db, err := jdh.Open("native", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	db := open()
+	gen, err := db.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: "Aus", Rank: jdh.Genus, IsValid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for i := 0; i < 20; i++ {
+		id, err := db.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: fmt.Sprintf("Aus sp%d", i), Rank: jdh.Species, Parent: gen, IsValid: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	const steps = 30
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	run := func(f func(db jdh.DB, i int) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db, err := jdh.Open("native", addr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for i := 0; i < steps; i++ {
+				if err := f(db, i); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	run(func(db jdh.DB, i int) error {
+		sc, err := db.Get(jdh.Taxonomy, ids[i%len(ids)])
+		if err != nil {
+			return err
+		}
+		return sc.Scan(&jdh.Taxon{})
+	})
+	run(func(db jdh.DB, i int) error {
+		vals := new(jdh.Values)
+		vals.Add(jdh.TaxChildren, gen)
+		l, err := db.List(jdh.Taxonomy, vals)
+		if err != nil {
+			return err
+		}
+		for {
+			if err := l.Scan(&jdh.Taxon{}); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	})
+	run(func(db jdh.DB, i int) error {
+		_, err := db.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: fmt.Sprintf("Aus new%d", i), Rank: jdh.Species, Parent: gen, IsValid: true})
+		return err
+	})
+	run(func(db jdh.DB, i int) error {
+		vals := new(jdh.Values)
+		vals.Add(jdh.KeyId, ids[i%len(ids)])
+		vals.Add(jdh.KeyComment, fmt.Sprintf("comment %d", i))
+		_, err := db.Exec(jdh.Set, jdh.Taxonomy, vals)
+		return err
+	})
+	run(func(db jdh.DB, i int) error {
+		if _, err := db.Exec(jdh.Begin, "", nil); err != nil {
+			return err
+		}
+		vals := new(jdh.Values)
+		vals.Add(jdh.KeyId, ids[(i+10)%len(ids)])
+		vals.Add(jdh.TaxAuthority, fmt.Sprintf("Author %d", i))
+		if _, err := db.Exec(jdh.Set, jdh.Taxonomy, vals); err != nil {
+			return err
+		}
+		_, err := db.Exec(jdh.Rollback, "", nil)
+		return err
+	})
+	run(func(db jdh.DB, i int) error {
+		if i%5 != 0 {
+			return nil
+		}
+		// commits are accepted while a transaction is in progress
+		_, err := db.Exec(jdh.Commit, "", nil)
+		return err
+	})
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	db = open()
+	vals := new(jdh.Values)
+	vals.Add(jdh.TaxChildren, gen)
+	l, err := db.List(jdh.Taxonomy, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for l.Scan(&jdh.Taxon{}) == nil {
+		n++
+	}
+	if want := len(ids) + steps; n != want {
+		t.Errorf("children of %s: got %d, want %d", gen, n, want)
+	}
+	if err := stop(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestSessionList checks that an answer larger than the window of a
+// session can be read while other requests are answered in the same
+// session, and that an answer discarded by the client does not block the
+// session.
+func TestSessionList(t *testing.T) {
+	addr, stop := startServer(t, &Config{})
+	db, err := jdh.Open("native", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := db.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: "Aus", Rank: jdh.Genus, IsValid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := strings.Repeat("x", 8192)
+	const taxa = 300
+	for i := 0; i < taxa; i++ {
+		if _, err := db.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: fmt.Sprintf("Aus sp%d", i), Rank: jdh.Species, Parent: gen, IsValid: true, Comment: comment}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vals := new(jdh.Values)
+	vals.Add(jdh.TaxChildren, gen)
+
+	l, err := db.List(jdh.Taxonomy, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		tax := &jdh.Taxon{}
+		if err := l.Scan(tax); err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+		n++
+		if n%50 != 0 {
+			continue
+		}
+		sc, err := db.Get(jdh.Taxonomy, tax.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sc.Scan(&jdh.Taxon{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != taxa {
+		t.Errorf("children of %s: got %d, want %d", gen, n, taxa)
+	}
+
+	l, err = db.List(jdh.Taxonomy, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Scan(&jdh.Taxon{}); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, err := db.Get(jdh.Taxonomy, gen); err != nil {
+		t.Errorf("get after a discarded list: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestAttach checks that only databases inside the root directory of the
+// server can be attached.
+func TestAttach(t *testing.T) {
+	root := t.TempDir()
+	addr, stop := startServer(t, &Config{Root: root})
+	db, err := jdh.Open("native", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attach := func(name, path string) error {
+		vals := new(jdh.Values)
+		vals.Add(jdh.KeyId, name)
+		vals.Add(ntv.KeyPath, path)
+		_, err := db.Exec(ntv.Attach, "", vals)
+		return err
+	}
+	if err := attach("outside", t.TempDir()); err == nil {
+		t.Errorf("attach outside of the root: expecting error")
+	}
+	if err := attach("parent", filepath.Join(root, "..")); err == nil {
+		t.Errorf("attach the parent of the root: expecting error")
+	}
+	inside := filepath.Join(root, "birds")
+	if err := os.Mkdir(inside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := attach("birds", inside); err != nil {
+		t.Fatalf("attach inside the root: %v", err)
+	}
+	birds, err := jdh.Open("native", addr+"?db=birds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := birds.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: "Aves", Rank: jdh.Class, IsValid: true}); err != nil {
+		t.Errorf("add to an attached database: %v", err)
+	}
+	vals := new(jdh.Values)
+	vals.Add(jdh.KeyId, "birds")
+	if _, err := db.Exec(ntv.Detach, "", vals); err != nil {
+		t.Errorf("detach: %v", err)
+	}
+	if _, err := birds.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: "Reptilia", Rank: jdh.Class, IsValid: true}); err == nil {
+		t.Errorf("add to a detached database: expecting error")
+	}
+	if err := stop(); err != nil {
+		t.Error(err)
+	}
+}
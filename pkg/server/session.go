@@ -0,0 +1,228 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+
+	ntv "github.com/js-arias/jdh/pkg/driver/native"
+)
+
+// sessionQueue is the number of frames of a session that can wait to be
+// sent to the client.
+const sessionQueue = 64
+
+// session is a connection in which a client sends several requests. The
+// requests are answered by the main loop of the server, as the requests
+// of single connections, and the answers are sent in frames, by the
+// writer of the session.
+type session struct {
+	conn net.Conn
+	out  chan []byte   // frames waiting to be sent
+	quit chan struct{} // closed when the session is finished
+	once sync.Once     // closes the session only once
+
+	lock   sync.Mutex
+	cond   *sync.Cond
+	credit map[uint64]int // bytes that can be sent, by answer
+	closed bool
+}
+
+// errSession is the error of a write in a closed session.
+var errSession = errors.New("session closed")
+
+// Session accepts a session on a connection, and reads its requests until
+// the client closes the connection, or the server is closed.
+func (srv *server) session(conn net.Conn, dec *json.Decoder, remote string) {
+	s := &session{
+		conn:   conn,
+		out:    make(chan []byte, sessionQueue),
+		quit:   make(chan struct{}),
+		credit: make(map[uint64]int),
+	}
+	s.cond = sync.NewCond(&s.lock)
+	if err := json.NewEncoder(conn).Encode(ntv.Success("ok")); err != nil {
+		conn.Close()
+		return
+	}
+	go func() {
+		select {
+		case <-srv.end:
+			s.close()
+		case <-s.quit:
+		}
+	}()
+	go s.write()
+	go func() {
+		defer s.close()
+		for {
+			req := &ntv.Request{}
+			if err := dec.Decode(req); err != nil {
+				return
+			}
+			if req.Query == ntv.Credit {
+				s.addCredit(req.Id, req.Size)
+				continue
+			}
+			s.lock.Lock()
+			s.credit[req.Id] = ntv.Window
+			s.lock.Unlock()
+			st := &stream{s: s, id: req.Id}
+			r := &request{
+				Request: req,
+				remote:  remote,
+				w:       st,
+				dec:     json.NewDecoder(bytes.NewReader(req.Elem)),
+				finish:  st.end,
+				wait:    st.wait,
+			}
+			select {
+			case srv.reqs <- r:
+			case <-srv.end:
+				return
+			}
+		}
+	}()
+}
+
+// Write sends the frames of the session to the client.
+func (s *session) write() {
+	for {
+		select {
+		case b := <-s.out:
+			if _, err := s.conn.Write(b); err != nil {
+				s.close()
+				return
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Close finishes the session.
+func (s *session) close() {
+	s.once.Do(func() {
+		s.lock.Lock()
+		s.closed = true
+		s.cond.Broadcast()
+		s.lock.Unlock()
+		close(s.quit)
+		s.conn.Close()
+	})
+}
+
+// AddCredit adds to the number of bytes of an answer that can be sent to
+// the client. If n is not positive, the client has discarded the answer.
+// Credits of finished answers are ignored.
+func (s *session) addCredit(id uint64, n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.credit[id]; !ok {
+		return
+	}
+	if n <= 0 {
+		delete(s.credit, id)
+	} else {
+		s.credit[id] += n
+	}
+	s.cond.Broadcast()
+}
+
+// Acquire waits until the client accepts more bytes of an answer, and
+// then, takes n bytes from its credit.
+func (s *session) acquire(id uint64, n int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for {
+		if s.closed {
+			return errSession
+		}
+		c, ok := s.credit[id]
+		if !ok {
+			return errors.New("answer discarded by the client")
+		}
+		if c > 0 {
+			break
+		}
+		s.cond.Wait()
+	}
+	s.credit[id] -= n
+	return nil
+}
+
+// Send adds a frame to the queue of the session. If wait is false, and
+// the queue is full, the client is too slow, and the session is closed,
+// so the caller is never blocked.
+func (s *session) send(b []byte, wait bool) error {
+	if wait {
+		select {
+		case s.out <- b:
+			return nil
+		case <-s.quit:
+			return errSession
+		}
+	}
+	select {
+	case s.out <- b:
+		return nil
+	case <-s.quit:
+		return errSession
+	default:
+	}
+	s.close()
+	return errors.New("session closed: client too slow")
+}
+
+// stream writes the answer of a request of a session. By default, the
+// writes of a stream never block, as they are done by the main loop of
+// the server.
+type stream struct {
+	s     *session
+	id    uint64
+	block bool // if true, writes wait for the client
+}
+
+// Wait sets the stream to wait for the client, so the answer is sent
+// only when the client is ready to read it. It must be used by handlers
+// that answer in their own goroutine.
+func (st *stream) wait() {
+	st.block = true
+}
+
+// Write sends a part of the answer.
+func (st *stream) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if st.block {
+		if err := st.s.acquire(st.id, len(p)); err != nil {
+			return 0, err
+		}
+	}
+	if err := st.s.send(frame(&ntv.Frame{Id: st.id, Size: len(p)}, p), st.block); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// End sends the end of the answer.
+func (st *stream) end() {
+	st.s.lock.Lock()
+	delete(st.s.credit, st.id)
+	st.s.lock.Unlock()
+	st.s.send(frame(&ntv.Frame{Id: st.id, End: true}, nil), st.block)
+}
+
+// Frame returns a frame, and its data, ready to be sent.
+func frame(f *ntv.Frame, data []byte) []byte {
+	b, _ := json.Marshal(f)
+	b = append(b, '\n')
+	return append(b, data...)
+}
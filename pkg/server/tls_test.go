@@ -0,0 +1,168 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/js-arias/jdh/pkg/jdh"
+)
+
+// selfSigned writes a self-signed certificate for the local host, and its
+// key, in a directory. It returns the names of the certificate and key
+// files.
+func selfSigned(t *testing.T, dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := filepath.Join(dir, name+".crt")
+	if err := ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	kf := filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(kf, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, kf
+}
+
+// TestTLS checks a round trip with a TLS server, and that the server is
+// rejected if its certificate is not signed by a valid authority.
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := selfSigned(t, dir, "server")
+	other, _ := selfSigned(t, dir, "other")
+	addr, stop := startServer(t, &Config{Cert: cert, Key: key})
+
+	db, err := jdh.Open("native", "tls://"+addr+"?ca="+cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := db.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: "Aus", Rank: jdh.Genus, IsValid: true})
+	if err != nil {
+		t.Fatalf("add on TLS connection: %v", err)
+	}
+	sc, err := db.Get(jdh.Taxonomy, id)
+	if err != nil {
+		t.Fatalf("get on TLS connection: %v", err)
+	}
+	tax := &jdh.Taxon{}
+	if err := sc.Scan(tax); err != nil {
+		t.Fatalf("get on TLS connection: %v", err)
+	}
+	if tax.Name != "Aus" {
+		t.Errorf("taxon %s: got name %q, want %q", id, tax.Name, "Aus")
+	}
+
+	bad, err := jdh.Open("native", "tls://"+addr+"?ca="+other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bad.Get(jdh.Taxonomy, id)
+	if err == nil {
+		t.Errorf("get with an invalid certificate authority: expecting error")
+	} else if !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("get with an invalid certificate authority: unexpected error: %v", err)
+	}
+
+	plain, err := jdh.Open("native", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.Get(jdh.Taxonomy, id); err == nil {
+		t.Errorf("get without TLS: expecting error")
+	}
+
+	if err := stop(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestIdleConn checks that a connection that does not send its request
+// (nor does the TLS handshake) does not block other clients, nor the stop
+// of the server.
+func TestIdleConn(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := selfSigned(t, dir, "server")
+	tests := []struct {
+		desc string
+		cfg  *Config
+		url  string
+	}{
+		{"plain", &Config{}, ""},
+		{"TLS", &Config{Cert: cert, Key: key}, "tls://"},
+	}
+	for _, test := range tests {
+		addr, stop := startServer(t, test.cfg)
+		idle, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idle.Close()
+		url := addr
+		if len(test.url) > 0 {
+			url = test.url + addr + "?ca=" + cert
+		}
+		db, err := jdh.Open("native", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		errc := make(chan error, 1)
+		go func() {
+			_, err := db.Exec(jdh.Add, jdh.Taxonomy, &jdh.Taxon{Name: "Aus", Rank: jdh.Genus, IsValid: true})
+			errc <- err
+		}()
+		select {
+		case err := <-errc:
+			if err != nil {
+				t.Errorf("%s: add: %v", test.desc, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: add blocked by an idle connection", test.desc)
+		}
+		go func() { errc <- stop() }()
+		select {
+		case err := <-errc:
+			if err != nil {
+				t.Errorf("%s: stop: %v", test.desc, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: stop blocked by an idle connection", test.desc)
+		}
+	}
+}
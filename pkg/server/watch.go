@@ -0,0 +1,104 @@
+// Copyright (c) 2014, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	ntv "github.com/js-arias/jdh/pkg/driver/native"
+)
+
+// watchTimeout is the time in which the client of a watch must read an
+// event, otherwise, the watch is finished.
+const watchTimeout = 30 * time.Second
+
+// Watch answers a watch request. The events of the database are sent
+// until the client closes the connection, the database is detached, the
+// server is stopped, or the client does not read an event in time. The
+// request is written in the access log when the watch is finished, with
+// the number of events sent.
+func (srv *server) watch(conn net.Conn, r *request, done *sync.WaitGroup) {
+	enc := json.NewEncoder(conn)
+	user, _, err := srv.acc.auth(net.ParseIP(r.remote), r.Token)
+	if err != nil {
+		ans := ntv.ErrAnswer("forbidden: " + err.Error())
+		enc.Encode(ans)
+		srv.logReq(r, "", 0, ans)
+		conn.Close()
+		return
+	}
+	db, release, err := srv.database(r.DB)
+	if err != nil {
+		ans := ntv.ErrAnswer(err.Error())
+		enc.Encode(ans)
+		srv.logReq(r, user, 0, ans)
+		conn.Close()
+		return
+	}
+	// the watch is closed when the database is closed, so it does not
+	// keep the database in use.
+	w := db.Watch(r.Table)
+	release()
+	ans := ntv.Success("ok")
+	if err := enc.Encode(ans); err != nil {
+		w.Close()
+		conn.Close()
+		srv.logReq(r, user, 0, ntv.ErrAnswer(err.Error()))
+		return
+	}
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		defer conn.Close()
+		defer w.Close()
+
+		// the client does not send anything after the request, so the
+		// read only returns when the connection is closed.
+		closed := make(chan struct{})
+		go func() {
+			io.Copy(ioutil.Discard, conn)
+			close(closed)
+		}()
+		// a write blocked by the client is interrupted when the
+		// server is stopped.
+		go func() {
+			select {
+			case <-srv.end:
+				conn.Close()
+			case <-closed:
+			}
+		}()
+		n := 0
+		for {
+			select {
+			case e, ok := <-w.Events():
+				if !ok {
+					if err := w.Err(); err != nil {
+						ans = ntv.ErrAnswer(err.Error())
+					}
+					srv.logReq(r, user, n, ans)
+					return
+				}
+				conn.SetWriteDeadline(time.Now().Add(watchTimeout))
+				if err := enc.Encode(e); err != nil {
+					srv.logReq(r, user, n, ans)
+					return
+				}
+				n++
+			case <-closed:
+				srv.logReq(r, user, n, ans)
+				return
+			case <-srv.end:
+				srv.logReq(r, user, n, ans)
+				return
+			}
+		}
+	}()
+}